@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"node_management_application/services"
+)
+
+// runApply implements the "apply" subcommand: it sends a manifest to a running
+// server, prints the plan and then applies it
+func runApply(args []string) int {
+	flags := flag.NewFlagSet("apply", flag.ContinueOnError)
+	file := flags.String("f", "", "path to the manifest file (\"-\" reads from stdin)")
+	server := flags.String("server", envOrDefault("NODE_API_URL", "http://localhost:8080"), "base URL of the node management API")
+	token := flags.String("token", os.Getenv("NODE_API_TOKEN"), "bearer token returned by /login")
+	prune := flags.Bool("prune", false, "delete nodes that are not declared in the manifest")
+	planOnly := flags.Bool("plan", false, "print the plan without applying it")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *file == "" || *token == "" {
+		fmt.Fprintln(os.Stderr, "usage: node_management_application apply -f manifest.yaml -token TOKEN [-server URL] [-prune] [-plan]")
+		return 2
+	}
+
+	var manifest []byte
+	var err error
+	if *file == "-" {
+		manifest, err = io.ReadAll(os.Stdin)
+	} else {
+		manifest, err = os.ReadFile(*file)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read manifest: %v\n", err)
+		return 1
+	}
+
+	// Always show the plan first
+	planned, err := postManifest(*server, *token, manifest, *prune, true)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Print(planned.Plan.String())
+	if *planOnly || len(planned.Plan.Actions) == 0 {
+		return 0
+	}
+
+	applied, err := postManifest(*server, *token, manifest, *prune, false)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	for _, msg := range applied.Errors {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", msg)
+	}
+	fmt.Println("Apply complete.")
+	return 0
+}
+
+// postManifest calls POST /apply and decodes the result
+func postManifest(server, token string, manifest []byte, prune, dryRun bool) (*services.ApplyResult, error) {
+	query := url.Values{}
+	query.Set("prune", fmt.Sprint(prune))
+	query.Set("dry_run", fmt.Sprint(dryRun))
+
+	req, err := http.NewRequest(http.MethodPost, strings.TrimRight(server, "/")+"/api/v1/apply?"+query.Encode(), bytes.NewReader(manifest))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/yaml")
+
+	client := &http.Client{Timeout: 2 * time.Minute}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("apply failed (%s): %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var result services.ApplyResult
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("invalid response: %v", err)
+	}
+	if result.Plan == nil {
+		result.Plan = &services.Plan{}
+	}
+	return &result, nil
+}
+
+// envOrDefault returns the environment variable or the fallback when it is unset
+func envOrDefault(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,43 @@
+package audit
+
+import (
+	"encoding/json"
+	"os"
+
+	"node_management_application/config"
+	"node_management_application/events"
+	"node_management_application/logging"
+)
+
+var logger = logging.For("audit")
+
+// unaudited are the event types too frequent to be worth a record; everything else is a change
+// of a node, user or their access
+var unaudited = map[events.Type]bool{
+	events.NodeHealthChanged: true,
+	events.OperationProgress: true,
+}
+
+// Init opens the audit log and subscribes it to the event bus. The audit log is an append-only
+// file with one JSON event per line; AUDIT_LOG_FILE=off disables it.
+func Init() error {
+	if config.AuditLogFile == "off" {
+		return nil
+	}
+
+	file, err := os.OpenFile(config.AuditLogFile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(file)
+
+	events.Subscribe("audit", func(event events.Event) {
+		if unaudited[event.Type] {
+			return
+		}
+		if err := encoder.Encode(event); err != nil {
+			logger.Error("Failed to write audit record", "event_id", event.ID, "type", event.Type, "error", err)
+		}
+	})
+	return nil
+}
@@ -0,0 +1,129 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+)
+
+// ListSubnets returns every subnet
+func (c *Client) ListSubnets(ctx context.Context) ([]Subnet, error) {
+	var subnets []Subnet
+	if _, err := c.call(ctx, &request{method: http.MethodGet, path: "/subnets"}, &subnets); err != nil {
+		return nil, err
+	}
+	return subnets, nil
+}
+
+// CreateSubnet adds a subnet; admin only
+func (c *Client) CreateSubnet(ctx context.Context, subnet Subnet) (*Subnet, error) {
+	req, err := jsonRequest(http.MethodPost, "/admin/subnets", subnet)
+	if err != nil {
+		return nil, err
+	}
+
+	var created Subnet
+	if _, err := c.call(ctx, req, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// DeleteSubnet deletes a subnet; admin only
+func (c *Client) DeleteSubnet(ctx context.Context, id uint) error {
+	_, err := c.call(ctx, &request{method: http.MethodDelete, path: "/admin/subnets/" + formatID(id)}, nil)
+	return err
+}
+
+// SubnetUtilization reports the usage of a subnet; admin only
+func (c *Client) SubnetUtilization(ctx context.Context, id uint) (*SubnetUtilization, error) {
+	var report SubnetUtilization
+	if _, err := c.call(ctx, &request{method: http.MethodGet, path: "/admin/subnets/" + formatID(id) + "/utilization"}, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// AllSubnetUtilization reports the usage of every subnet; admin only
+func (c *Client) AllSubnetUtilization(ctx context.Context) ([]SubnetUtilization, error) {
+	var reports []SubnetUtilization
+	if _, err := c.call(ctx, &request{method: http.MethodGet, path: "/admin/subnets/utilization"}, &reports); err != nil {
+		return nil, err
+	}
+	return reports, nil
+}
+
+// ListPortRanges returns the port pools; admin only
+func (c *Client) ListPortRanges(ctx context.Context) ([]PortRange, error) {
+	var ranges []PortRange
+	if _, err := c.call(ctx, &request{method: http.MethodGet, path: "/admin/port-ranges"}, &ranges); err != nil {
+		return nil, err
+	}
+	return ranges, nil
+}
+
+// CreatePortRange adds a port pool; admin only
+func (c *Client) CreatePortRange(ctx context.Context, portRange PortRange) (*PortRange, error) {
+	req, err := jsonRequest(http.MethodPost, "/admin/port-ranges", portRange)
+	if err != nil {
+		return nil, err
+	}
+
+	var created PortRange
+	if _, err := c.call(ctx, req, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// DeletePortRange deletes a port pool; admin only
+func (c *Client) DeletePortRange(ctx context.Context, id uint) error {
+	_, err := c.call(ctx, &request{method: http.MethodDelete, path: "/admin/port-ranges/" + formatID(id)}, nil)
+	return err
+}
+
+// ListPortReservations returns the reserved addresses; admin only
+func (c *Client) ListPortReservations(ctx context.Context) ([]PortReservation, error) {
+	var reservations []PortReservation
+	if _, err := c.call(ctx, &request{method: http.MethodGet, path: "/admin/port-reservations"}, &reservations); err != nil {
+		return nil, err
+	}
+	return reservations, nil
+}
+
+// WebSocketStats returns the WebSocket connection statistics; admin only
+func (c *Client) WebSocketStats(ctx context.Context) (*WebSocketStats, error) {
+	var stats WebSocketStats
+	if _, err := c.call(ctx, &request{method: http.MethodGet, path: "/admin/websocket/stats"}, &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// LogLevels returns the log levels in effect; admin only
+func (c *Client) LogLevels(ctx context.Context) (*LogLevels, error) {
+	var levels LogLevels
+	if _, err := c.call(ctx, &request{method: http.MethodGet, path: "/admin/log-level"}, &levels); err != nil {
+		return nil, err
+	}
+	return &levels, nil
+}
+
+// SetLogLevel changes the log level of a component, or of the application when component
+// is empty; admin only
+func (c *Client) SetLogLevel(ctx context.Context, component, level string) (*LogLevels, error) {
+	req, err := jsonRequest(http.MethodPut, "/admin/log-level", map[string]string{"component": component, "level": level})
+	if err != nil {
+		return nil, err
+	}
+
+	var levels LogLevels
+	if _, err := c.call(ctx, req, &levels); err != nil {
+		return nil, err
+	}
+	return &levels, nil
+}
+
+func formatID(id uint) string {
+	return strconv.FormatUint(uint64(id), 10)
+}
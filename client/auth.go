@@ -0,0 +1,91 @@
+package client
+
+import (
+	"context"
+	"net/http"
+)
+
+// LoginResult is the user and token returned by a login
+type LoginResult struct {
+	User  User   `json:"user"`
+	Token string `json:"token"`
+}
+
+// Register creates a user account; it does not log in
+func (c *Client) Register(ctx context.Context, name, email, password string) (*User, error) {
+	req, err := jsonRequest(http.MethodPost, "/register", map[string]string{"name": name, "email": email, "password": password})
+	if err != nil {
+		return nil, err
+	}
+	req.public = true
+
+	var result struct {
+		User User `json:"user"`
+	}
+	if _, err := c.call(ctx, req, &result); err != nil {
+		return nil, err
+	}
+	return &result.User, nil
+}
+
+// Login authenticates with the given credentials. The client keeps them to log in again
+// when the token expires.
+func (c *Client) Login(ctx context.Context, email, password string) (*LoginResult, error) {
+	c.mu.Lock()
+	c.email = email
+	c.password = password
+	c.mu.Unlock()
+
+	c.loginMu.Lock()
+	defer c.loginMu.Unlock()
+	return c.authenticate(ctx)
+}
+
+// login replaces an expired or missing token. Calls waiting on another login reuse its token.
+func (c *Client) login(ctx context.Context) error {
+	c.mu.Lock()
+	stale := c.token
+	c.mu.Unlock()
+
+	c.loginMu.Lock()
+	defer c.loginMu.Unlock()
+
+	c.mu.Lock()
+	current := c.token
+	c.mu.Unlock()
+	if current != "" && current != stale {
+		return nil
+	}
+
+	_, err := c.authenticate(ctx)
+	return err
+}
+
+// authenticate exchanges the stored credentials for a token; the caller holds loginMu
+func (c *Client) authenticate(ctx context.Context) (*LoginResult, error) {
+	c.mu.Lock()
+	credentials := map[string]string{"email": c.email, "password": c.password}
+	c.mu.Unlock()
+
+	req, err := jsonRequest(http.MethodPost, "/login", credentials)
+	if err != nil {
+		return nil, err
+	}
+	req.public = true
+
+	var result LoginResult
+	if _, err := c.call(ctx, req, &result); err != nil {
+		return nil, err
+	}
+	c.setToken(result.Token)
+	return &result, nil
+}
+
+// Profile returns the authenticated user
+func (c *Client) Profile(ctx context.Context) (*User, error) {
+	var user User
+	if _, err := c.call(ctx, &request{method: http.MethodGet, path: "/users/profile"}, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
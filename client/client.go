@@ -0,0 +1,361 @@
+// Package client is a Go client for the node management API.
+//
+// A client logs in with credentials and logs in again when its token expires, retries
+// idempotent requests on transient failures and returns API errors as *Error:
+//
+//	c := client.New("http://localhost:8080", client.WithCredentials("me@example.com", "secret"))
+//	nodes, err := c.ListNodes(ctx)
+//	if errors.Is(err, client.ErrUnauthorized) { ... }
+package client
+
+import (
+	"bytes"
+	"context"
+	cryptorand "crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// APIPrefix is the path of the API version this client speaks
+const APIPrefix = "/api/v1"
+
+// idempotencyKeyHeader makes the server replay the first response to a retried request
+const idempotencyKeyHeader = "Idempotency-Key"
+
+// tokenRefreshMargin is how long before its expiry a token is replaced
+const tokenRefreshMargin = time.Minute
+
+// Client calls the node management API. It is safe for concurrent use.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	userAgent  string
+	retry      RetryPolicy
+
+	loginMu sync.Mutex // Serializes logins so concurrent calls share one new token
+
+	mu       sync.Mutex // Guards the token and credentials
+	token    string
+	expires  time.Time
+	email    string
+	password string
+}
+
+// RetryPolicy controls how idempotent requests are retried on network errors and on
+// 429, 502, 503 and 504 responses. The delay doubles from BaseDelay up to MaxDelay, with jitter.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// DefaultRetryPolicy makes up to 4 attempts over roughly 3 seconds
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 4, BaseDelay: 200 * time.Millisecond, MaxDelay: 2 * time.Second}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient sets the HTTP client used for requests
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) { c.httpClient = httpClient }
+}
+
+// WithToken authenticates with an existing token. Without credentials it cannot be renewed.
+func WithToken(token string) Option {
+	return func(c *Client) { c.setToken(token) }
+}
+
+// WithCredentials makes the client log in on first use and whenever its token expires
+func WithCredentials(email, password string) Option {
+	return func(c *Client) {
+		c.email = email
+		c.password = password
+	}
+}
+
+// WithRetryPolicy replaces DefaultRetryPolicy; MaxAttempts of 1 disables retries
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *Client) { c.retry = policy }
+}
+
+// WithUserAgent sets the User-Agent header of every request
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) { c.userAgent = userAgent }
+}
+
+// New returns a client for the server at baseURL, e.g. http://localhost:8080
+func New(baseURL string, options ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 2 * time.Minute},
+		userAgent:  "node-management-go-client",
+		retry:      DefaultRetryPolicy,
+	}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+// Token returns the current token, logging in first when needed
+func (c *Client) Token(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	token, expires, canLogin := c.token, c.expires, c.email != ""
+	c.mu.Unlock()
+
+	if token != "" && (expires.IsZero() || time.Until(expires) > tokenRefreshMargin || !canLogin) {
+		return token, nil
+	}
+	if !canLogin {
+		return "", nil
+	}
+	if err := c.login(ctx); err != nil {
+		return "", err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.token, nil
+}
+
+// setToken stores a token and reads its expiry so it can be replaced in time
+func (c *Client) setToken(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.token = token
+	c.expires = tokenExpiry(token)
+}
+
+// invalidateToken drops a token the server rejected, unless another call already replaced it
+func (c *Client) invalidateToken(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token == token {
+		c.token = ""
+		c.expires = time.Time{}
+	}
+}
+
+// tokenExpiry reads the exp claim of a JWT without verifying it; the server does that
+func tokenExpiry(token string) time.Time {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}
+	}
+	var claims struct {
+		ExpiresAt int64 `json:"exp"`
+	}
+	if json.Unmarshal(payload, &claims) != nil || claims.ExpiresAt == 0 {
+		return time.Time{}
+	}
+	return time.Unix(claims.ExpiresAt, 0)
+}
+
+// request describes one API call
+type request struct {
+	method      string
+	path        string // Relative to APIPrefix
+	query       url.Values
+	header      http.Header
+	body        []byte
+	contentType string
+	public      bool // Sent without a token
+	noRetry     bool // Never retried, even when the method is idempotent
+}
+
+// jsonRequest builds a request with a JSON body
+func jsonRequest(method, path string, body interface{}) (*request, error) {
+	req := &request{method: method, path: path}
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		req.body = data
+		req.contentType = "application/json"
+	}
+	return req, nil
+}
+
+// call sends a request and decodes a JSON response into out, when given
+func (c *Client) call(ctx context.Context, req *request, out interface{}) (*http.Response, error) {
+	resp, body, err := c.send(ctx, req)
+	if err != nil {
+		return resp, err
+	}
+	if out != nil && len(body) > 0 {
+		if err := json.Unmarshal(body, out); err != nil {
+			return resp, fmt.Errorf("decode %s %s response: %w", req.method, req.path, err)
+		}
+	}
+	return resp, nil
+}
+
+// send performs a request with authentication, one login retry on 401 and backoff retries.
+// It returns the response with its body read, or an *Error for an unsuccessful status.
+func (c *Client) send(ctx context.Context, req *request) (*http.Response, []byte, error) {
+	attempts := 1
+	// A request with an Idempotency-Key is replayed rather than repeated by the server
+	retryable := isIdempotent(req.method) || req.header.Get(idempotencyKeyHeader) != ""
+	if !req.noRetry && retryable && c.retry.MaxAttempts > 1 {
+		attempts = c.retry.MaxAttempts
+	}
+	relogged := false
+
+	for attempt := 1; ; attempt++ {
+		token := ""
+		if !req.public {
+			var err error
+			if token, err = c.Token(ctx); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		resp, body, err := c.roundTrip(ctx, req, token)
+		switch {
+		case err != nil:
+			if attempt >= attempts || ctx.Err() != nil {
+				return nil, nil, err
+			}
+		case resp.StatusCode == http.StatusUnauthorized && token != "" && !relogged && c.canLogin():
+			// The token expired or was revoked early; log in again once
+			c.invalidateToken(token)
+			relogged = true
+			attempt--
+			continue
+		case resp.StatusCode >= 400:
+			apiErr := newError(resp, body)
+			// The first attempt of a request with the same key may still be running
+			if attempt >= attempts || !(isRetryableStatus(resp.StatusCode) || apiErr.Code == CodeIdempotencyKeyInUse) {
+				return resp, body, apiErr
+			}
+		default:
+			return resp, body, nil
+		}
+
+		if err := sleep(ctx, c.backoff(attempt, resp)); err != nil {
+			return nil, nil, err
+		}
+	}
+}
+
+// roundTrip sends a single attempt and reads the whole response body
+func (c *Client) roundTrip(ctx context.Context, req *request, token string) (*http.Response, []byte, error) {
+	target := c.baseURL + APIPrefix + req.path
+	if len(req.query) > 0 {
+		target += "?" + req.query.Encode()
+	}
+
+	var body io.Reader
+	if req.body != nil {
+		body = bytes.NewReader(req.body)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, target, body)
+	if err != nil {
+		return nil, nil, err
+	}
+	for key, values := range req.header {
+		httpReq.Header[key] = values
+	}
+	if req.contentType != "" {
+		httpReq.Header.Set("Content-Type", req.contentType)
+	}
+	if httpReq.Header.Get("Accept") == "" {
+		httpReq.Header.Set("Accept", "application/json")
+	}
+	httpReq.Header.Set("User-Agent", c.userAgent)
+	if token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp, data, nil
+}
+
+func (c *Client) canLogin() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.email != ""
+}
+
+// backoff returns the delay before the next attempt, honouring Retry-After when present
+func (c *Client) backoff(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+
+	delay := c.retry.BaseDelay << (attempt - 1)
+	if delay > c.retry.MaxDelay || delay <= 0 {
+		delay = c.retry.MaxDelay
+	}
+	// Full jitter spreads out clients retrying after the same outage
+	return time.Duration(rand.Int63n(int64(delay) + 1))
+}
+
+func sleep(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+func isRetryableStatus(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// withIdempotencyKey gives a request a fresh Idempotency-Key so it can be retried safely
+func withIdempotencyKey(req *request) *request {
+	key := make([]byte, 16)
+	cryptorand.Read(key)
+	if req.header == nil {
+		req.header = http.Header{}
+	}
+	req.header.Set(idempotencyKeyHeader, hex.EncodeToString(key))
+	return req
+}
+
+// etag builds the entity tag the server assigns to a node version
+func etag(id uint, version uint) string {
+	return fmt.Sprintf("\"%d-%d\"", id, version)
+}
@@ -0,0 +1,342 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"node_management_application/client"
+	"node_management_application/events"
+	"node_management_application/testutil"
+	"node_management_application/websocket"
+)
+
+const (
+	testEmail    = "ada@example.com"
+	testPassword = "correct horse battery"
+)
+
+// fastRetries keeps the backoff of the tests short
+var fastRetries = client.RetryPolicy{MaxAttempts: 4, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+// server runs the API on a test database. Requests pass through fail first, which can
+// answer them with an injected status instead.
+type server struct {
+	url string
+
+	mu       sync.Mutex
+	failures map[string]int // Failures still to inject, by "METHOD /path"
+	requests map[string]int // Requests received, by "METHOD /path"
+}
+
+func newServer(t *testing.T) *server {
+	t.Helper()
+	testutil.UseDatabase(t)
+
+	app := testutil.NewApp()
+	if err := app.Build(); err != nil {
+		t.Fatalf("build app: %v", err)
+	}
+	t.Cleanup(events.Subscribe("websocket", websocket.BroadcastEvent))
+
+	s := &server{failures: make(map[string]int), requests: make(map[string]int)}
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status := s.fail(r.Method + " " + r.URL.Path); status != 0 {
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.WriteHeader(status)
+			w.Write([]byte(`{"status":503,"title":"Service Unavailable","code":"internal_error"}`))
+			return
+		}
+		app.ServeHTTP(w, r)
+	}))
+	t.Cleanup(httpServer.Close)
+	s.url = httpServer.URL
+
+	if _, err := client.New(s.url).Register(context.Background(), "Ada", testEmail, testPassword); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	return s
+}
+
+// failNext answers the next count requests to route with status 503
+func (s *server) failNext(route string, count int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[route] = count
+}
+
+// fail counts a request and returns the status to answer it with, or 0 to serve it
+func (s *server) fail(route string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests[route]++
+	if s.failures[route] == 0 {
+		return 0
+	}
+	s.failures[route]--
+	return http.StatusServiceUnavailable
+}
+
+// received returns the number of requests to route
+func (s *server) received(route string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[route]
+}
+
+func (s *server) client(options ...client.Option) *client.Client {
+	options = append([]client.Option{client.WithCredentials(testEmail, testPassword), client.WithRetryPolicy(fastRetries)}, options...)
+	return client.New(s.url, options...)
+}
+
+func TestLogin(t *testing.T) {
+	s := newServer(t)
+	ctx := context.Background()
+
+	result, err := client.New(s.url).Login(ctx, testEmail, testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Token == "" || result.User.Email != testEmail {
+		t.Fatalf("login returned %+v", result)
+	}
+
+	_, err = client.New(s.url).Login(ctx, testEmail, "wrong password")
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || apiErr.Code != client.CodeInvalidCredentials || !errors.Is(err, client.ErrUnauthorized) {
+		t.Fatalf("login with a wrong password returned %v", err)
+	}
+}
+
+func TestLoginOnFirstUse(t *testing.T) {
+	s := newServer(t)
+
+	user, err := s.client().Profile(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Email != testEmail {
+		t.Fatalf("profile is %+v", user)
+	}
+	if got := s.received("POST /api/v1/login"); got != 1 {
+		t.Fatalf("logged in %d times, want 1", got)
+	}
+}
+
+func TestLoginAgainOnUnauthorized(t *testing.T) {
+	s := newServer(t)
+	ctx := context.Background()
+
+	// A token without an expiry is used until the server rejects it
+	c := s.client(client.WithToken("revoked"))
+	if _, err := c.ListNodes(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.received("GET /api/v1/nodes"); got != 2 {
+		t.Fatalf("sent %d requests, want the rejected one and its repeat", got)
+	}
+	if got := s.received("POST /api/v1/login"); got != 1 {
+		t.Fatalf("logged in %d times, want 1", got)
+	}
+
+	// Without credentials the rejection is returned
+	_, err := client.New(s.url, client.WithToken("revoked")).ListNodes(ctx)
+	if !errors.Is(err, client.ErrUnauthorized) {
+		t.Fatalf("ListNodes with a rejected token returned %v", err)
+	}
+}
+
+func TestNodes(t *testing.T) {
+	s := newServer(t)
+	ctx := context.Background()
+	c := s.client()
+
+	node, err := c.CreateNode(ctx, client.NodeSpec{Name: "alpha", IP: "127.0.0.1", Port: 18080, Location: "lab"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node.ID == 0 || node.Name != "alpha" || node.Port != 18080 {
+		t.Fatalf("created %+v", node)
+	}
+
+	got, err := c.GetNode(ctx, node.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "alpha" || got.Version != node.Version {
+		t.Fatalf("got %+v, want %+v", got, node)
+	}
+
+	updated, err := c.UpdateNode(ctx, node.ID, node.Version, client.NodeUpdate{Name: "beta", IP: "127.0.0.1", Port: 18081, Location: "lab"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Name != "beta" || updated.Port != 18081 || updated.Version == node.Version {
+		t.Fatalf("updated %+v", updated)
+	}
+
+	location := "rack 2"
+	patched, err := c.PatchNode(ctx, node.ID, updated.Version, client.NodePatch{Location: &location}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if patched.Location != location || patched.Name != "beta" {
+		t.Fatalf("patched %+v", patched)
+	}
+
+	nodes, err := c.ListNodes(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 1 || nodes[0].ID != node.ID {
+		t.Fatalf("listed %+v", nodes)
+	}
+
+	if err := c.DeleteNode(ctx, node.ID, patched.Version); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetNode(ctx, node.ID); !errors.Is(err, client.ErrNotFound) {
+		t.Fatalf("GetNode after delete returned %v", err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	s := newServer(t)
+	ctx := context.Background()
+	c := s.client()
+
+	_, err := c.GetNode(ctx, 999)
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("GetNode of a missing node returned %T %v", err, err)
+	}
+	if apiErr.StatusCode != http.StatusNotFound || apiErr.Code != client.CodeNotFound || !errors.Is(err, client.ErrNotFound) {
+		t.Fatalf("GetNode of a missing node returned %+v", apiErr)
+	}
+
+	_, err = c.CreateNode(ctx, client.NodeSpec{Name: "", IP: "127.0.0.1", Port: 18080})
+	if !errors.As(err, &apiErr) || apiErr.Code != client.CodeValidationFailed || len(apiErr.Fields) == 0 || !errors.Is(err, client.ErrInvalidRequest) {
+		t.Fatalf("CreateNode without a name returned %v", err)
+	}
+
+	node, err := c.CreateNode(ctx, client.NodeSpec{Name: "alpha", IP: "127.0.0.1", Port: 18080})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.UpdateNode(ctx, node.ID, node.Version, client.NodeUpdate{Name: "beta", IP: "127.0.0.1", Port: 18080}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The node changed since node.Version
+	_, err = c.UpdateNode(ctx, node.ID, node.Version, client.NodeUpdate{Name: "gamma", IP: "127.0.0.1", Port: 18080})
+	if !errors.Is(err, client.ErrVersionConflict) || !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusPreconditionFailed {
+		t.Fatalf("UpdateNode with a stale version returned %v", err)
+	}
+	if errors.Is(err, client.ErrNotFound) {
+		t.Fatalf("%v matches ErrNotFound", err)
+	}
+}
+
+func TestRetryIdempotentRequests(t *testing.T) {
+	s := newServer(t)
+	ctx := context.Background()
+	c := s.client()
+
+	s.failNext("GET /api/v1/nodes", 2)
+	if _, err := c.ListNodes(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.received("GET /api/v1/nodes"); got != 3 {
+		t.Fatalf("sent %d requests, want 3", got)
+	}
+
+	// Attempts stop at MaxAttempts
+	s.failNext("GET /api/v1/nodes", fastRetries.MaxAttempts)
+	_, err := c.ListNodes(ctx)
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable || !errors.Is(err, client.ErrServer) {
+		t.Fatalf("ListNodes returned %v, want the last 503", err)
+	}
+	if got := s.received("GET /api/v1/nodes"); got != 3+fastRetries.MaxAttempts {
+		t.Fatalf("sent %d requests, want %d", got, 3+fastRetries.MaxAttempts)
+	}
+
+	// Creating a node carries an Idempotency-Key, so it is retried without creating two
+	s.failNext("POST /api/v1/nodes", 1)
+	if _, err := c.CreateNode(ctx, client.NodeSpec{Name: "alpha", IP: "127.0.0.1", Port: 18080}); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.received("POST /api/v1/nodes"); got != 2 {
+		t.Fatalf("sent %d requests, want 2", got)
+	}
+	nodes, err := c.ListNodes(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 1 {
+		t.Fatalf("created %d nodes, want 1", len(nodes))
+	}
+}
+
+func TestNoRetryForOtherRequests(t *testing.T) {
+	s := newServer(t)
+
+	s.failNext("POST /api/v1/register", 1)
+	_, err := s.client().Register(context.Background(), "Bob", "bob@example.com", testPassword)
+	if !errors.Is(err, client.ErrServer) {
+		t.Fatalf("Register returned %v, want the 503", err)
+	}
+	if got := s.received("POST /api/v1/register"); got != 2 {
+		// One registration in newServer and this one
+		t.Fatalf("sent %d registrations, want 2", got)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	s := newServer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	c := s.client()
+
+	subscription, err := c.Subscribe(ctx, client.EventOptions{Types: []string{"node.created"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer subscription.Close()
+
+	// The server applies the subscription after the connection is open, so nodes are
+	// created until one of them is seen
+	created := make(map[uint]bool)
+	for i := 0; ; i++ {
+		node, err := c.CreateNode(ctx, client.NodeSpec{Name: fmt.Sprintf("node-%d", i), IP: "127.0.0.1", Port: 18080 + i})
+		if err != nil {
+			t.Fatal(err)
+		}
+		created[node.ID] = true
+		if err := c.DeleteNode(ctx, node.ID, 0); err != nil {
+			t.Fatal(err)
+		}
+
+		select {
+		case event, ok := <-subscription.Events():
+			if !ok {
+				t.Fatalf("subscription ended: %v", subscription.Err())
+			}
+			if event.Type != "node.created" || !created[event.NodeID] {
+				t.Fatalf("received %+v, want node.created of one of %v", event, created)
+			}
+			if subscription.LastID() != event.ID {
+				t.Fatalf("LastID is %d, want %d", subscription.LastID(), event.ID)
+			}
+			return
+		case <-time.After(200 * time.Millisecond):
+		case <-ctx.Done():
+			t.Fatal("no event received")
+		}
+	}
+}
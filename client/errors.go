@@ -0,0 +1,133 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Stable error codes of the API, as found in Error.Code
+const (
+	CodeInvalidRequest       = "invalid_request"
+	CodeValidationFailed     = "validation_failed"
+	CodeUnauthorized         = "unauthorized"
+	CodeInvalidCredentials   = "invalid_credentials"
+	CodeForbidden            = "forbidden"
+	CodeNotFound             = "not_found"
+	CodeConflict             = "conflict"
+	CodeEmailTaken           = "email_taken"
+	CodePortConflict         = "port_conflict"
+	CodePortInUse            = "port_in_use"
+	CodeNoFreePort           = "no_free_port"
+	CodeSubnetExhausted      = "subnet_exhausted"
+	CodeNodeRunning          = "node_running"
+	CodeNodeNotRunning       = "node_not_running"
+	CodeVersionConflict      = "version_conflict"
+	CodeUnsupportedMediaType = "unsupported_media_type"
+	CodeImportRejected       = "import_rejected"
+	CodeResyncRequired       = "resync_required"
+	CodeIdempotencyKeyReused = "idempotency_key_reused"
+	CodeIdempotencyKeyInUse  = "idempotency_key_in_use"
+	CodeOperationFinished    = "operation_finished"
+	CodeTeamHasNodes         = "team_has_nodes"
+	CodeLastTeamAdmin        = "last_team_admin"
+	CodeUserHasNodes         = "user_has_nodes"
+	CodeQuotaExceeded        = "quota_exceeded"
+	CodeInternal             = "internal_error"
+)
+
+// Sentinel errors an *Error matches with errors.Is, grouping the codes callers usually branch on
+var (
+	ErrInvalidRequest  = errors.New("invalid request")
+	ErrUnauthorized    = errors.New("unauthorized")
+	ErrForbidden       = errors.New("forbidden")
+	ErrNotFound        = errors.New("not found")
+	ErrConflict        = errors.New("conflict")
+	ErrVersionConflict = errors.New("version conflict")
+	ErrQuotaExceeded   = errors.New("quota exceeded")
+	ErrServer          = errors.New("server error")
+)
+
+// FieldError explains why one field of a request was rejected
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error is an RFC 7807 problem returned by the API
+type Error struct {
+	StatusCode    int          `json:"status"`
+	Type          string       `json:"type"`
+	Title         string       `json:"title"`
+	Detail        string       `json:"detail"`
+	Instance      string       `json:"instance"`
+	Code          string       `json:"code"`
+	CorrelationID string       `json:"correlation_id"`
+	Fields        []FieldError `json:"errors"`
+
+	// Raw is the complete problem document, including extension members such as "plan" or "result"
+	Raw json.RawMessage `json:"-"`
+}
+
+func (e *Error) Error() string {
+	message := e.Detail
+	if message == "" {
+		message = e.Title
+	}
+	if e.Code != "" {
+		message = e.Code + ": " + message
+	}
+	if e.CorrelationID != "" {
+		message += " (correlation ID " + e.CorrelationID + ")"
+	}
+	return fmt.Sprintf("node API: %d %s", e.StatusCode, message)
+}
+
+// Is matches the sentinel errors by status code and error code
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrInvalidRequest:
+		return e.StatusCode == http.StatusBadRequest || e.StatusCode == http.StatusUnprocessableEntity || e.StatusCode == http.StatusUnsupportedMediaType
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	case ErrVersionConflict:
+		return e.Code == CodeVersionConflict
+	case ErrQuotaExceeded:
+		return e.Code == CodeQuotaExceeded
+	case ErrServer:
+		return e.StatusCode >= 500
+	}
+	return false
+}
+
+// Extension decodes an extension member of the problem, e.g. "plan" of a failed apply
+func (e *Error) Extension(name string, out interface{}) error {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(e.Raw, &members); err != nil {
+		return err
+	}
+	member, ok := members[name]
+	if !ok {
+		return fmt.Errorf("problem has no %q member", name)
+	}
+	return json.Unmarshal(member, out)
+}
+
+// newError builds an *Error from an unsuccessful response, problem document or not
+func newError(resp *http.Response, body []byte) *Error {
+	apiErr := &Error{StatusCode: resp.StatusCode, Title: http.StatusText(resp.StatusCode), Raw: body}
+	if strings.Contains(resp.Header.Get("Content-Type"), "json") && json.Unmarshal(body, apiErr) == nil {
+		apiErr.StatusCode = resp.StatusCode
+	} else if text := strings.TrimSpace(string(body)); text != "" {
+		apiErr.Detail = text
+	}
+	return apiErr
+}
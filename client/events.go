@@ -0,0 +1,251 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// eventQueueSize is the number of received events buffered before the reader waits for the caller
+const eventQueueSize = 64
+
+// EventOptions selects the events of a subscription. Nodes and Groups together select the
+// nodes, and when both are empty every node is selected. Empty Types means every type.
+type EventOptions struct {
+	Nodes  []uint
+	Groups []string // Groups set by manifests
+	Types  []string
+
+	// ResumeAfter replays the events published after this ID before live ones; 0 starts live
+	// unless Replay is set
+	ResumeAfter uint64
+
+	// Replay resumes even when ResumeAfter is 0, replaying every event the server still holds
+	Replay bool
+
+	// OnResync is called when the server no longer holds the events missed while disconnected.
+	// The subscription continues live from latestID; the caller should reload its state.
+	OnResync func(latestID uint64)
+}
+
+// Subscription receives events over a WebSocket. When the connection drops it reconnects
+// with backoff and resumes after the last event received, so no event is missed or repeated.
+type Subscription struct {
+	client  *Client
+	options EventOptions
+	nodes   map[uint]bool
+	types   map[string]bool
+
+	events chan Event
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu   sync.Mutex
+	conn *websocket.Conn
+	last uint64
+	err  error
+}
+
+// Subscribe opens an event subscription. It fails when the first connection cannot be made;
+// later disconnections are retried until ctx is done or Close is called.
+func (c *Client) Subscribe(ctx context.Context, options EventOptions) (*Subscription, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	s := &Subscription{
+		client:  c,
+		options: options,
+		nodes:   make(map[uint]bool),
+		types:   make(map[string]bool),
+		events:  make(chan Event, eventQueueSize),
+		cancel:  cancel,
+		done:    make(chan struct{}),
+		last:    options.ResumeAfter,
+	}
+	for _, id := range options.Nodes {
+		s.nodes[id] = true
+	}
+	for _, eventType := range options.Types {
+		s.types[eventType] = true
+	}
+
+	conn, err := s.connect(ctx, options.Replay || options.ResumeAfter != 0)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	go s.run(ctx, conn)
+	return s, nil
+}
+
+// Events returns the channel events are delivered on. It is closed when the subscription ends.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Err returns the error that ended the subscription, or nil while it runs and after Close
+func (s *Subscription) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// LastID returns the ID of the last event received, to resume from in a later subscription
+func (s *Subscription) LastID() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.last
+}
+
+// Close ends the subscription and waits for it to stop
+func (s *Subscription) Close() error {
+	s.cancel()
+	s.mu.Lock()
+	if s.conn != nil {
+		s.conn.Close()
+	}
+	s.mu.Unlock()
+	<-s.done
+	return nil
+}
+
+// run reads from the connection and reconnects until the context ends or reconnecting fails for good
+func (s *Subscription) run(ctx context.Context, conn *websocket.Conn) {
+	defer close(s.done)
+	defer close(s.events)
+
+	for attempt := 1; ; {
+		if conn != nil {
+			s.read(ctx, conn)
+			attempt = 1
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err := sleep(ctx, s.client.backoff(attempt, nil)); err != nil {
+			return
+		}
+		var err error
+		if conn, err = s.connect(ctx, true); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			var apiErr *Error
+			if errors.As(err, &apiErr) && !isRetryableStatus(apiErr.StatusCode) && apiErr.StatusCode < 500 {
+				s.mu.Lock()
+				s.err = err
+				s.mu.Unlock()
+				return
+			}
+			attempt++
+		}
+	}
+}
+
+// connect dials the WebSocket, resuming after the last event when asked, and subscribes
+func (s *Subscription) connect(ctx context.Context, resume bool) (*websocket.Conn, error) {
+	target := strings.Replace(s.client.baseURL, "http", "ws", 1) + APIPrefix + "/ws"
+	if resume {
+		target += "?since=" + strconv.FormatUint(s.LastID(), 10)
+	}
+
+	var conn *websocket.Conn
+	for relogged := false; ; relogged = true {
+		token, err := s.client.Token(ctx)
+		if err != nil {
+			return nil, err
+		}
+		header := http.Header{"User-Agent": {s.client.userAgent}}
+		if token != "" {
+			header.Set("Authorization", "Bearer "+token)
+		}
+
+		var resp *http.Response
+		conn, resp, err = websocket.DefaultDialer.DialContext(ctx, target, header)
+		if err == nil {
+			break
+		}
+		if resp == nil {
+			return nil, err
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusUnauthorized && token != "" && !relogged && s.client.canLogin() {
+			s.client.invalidateToken(token)
+			continue
+		}
+		return nil, newError(resp, body)
+	}
+
+	// The server filters live events by the subscription; replayed ones are filtered here too
+	if len(s.options.Nodes) > 0 || len(s.options.Groups) > 0 || len(s.options.Types) > 0 {
+		subscribe := map[string]interface{}{"action": "subscribe", "nodes": s.options.Nodes, "groups": s.options.Groups, "events": s.options.Types}
+		if err := conn.WriteJSON(subscribe); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	s.mu.Lock()
+	s.conn = conn
+	s.mu.Unlock()
+	if ctx.Err() != nil {
+		conn.Close()
+		return nil, ctx.Err()
+	}
+	return conn, nil
+}
+
+// read delivers the events of one connection until it fails
+func (s *Subscription) read(ctx context.Context, conn *websocket.Conn) {
+	defer conn.Close()
+
+	for {
+		var msg message
+		if err := conn.ReadJSON(&msg); err != nil {
+			return
+		}
+
+		switch {
+		case msg.ID != 0:
+			if !s.wants(msg.Event) {
+				s.setLast(msg.ID)
+				continue
+			}
+			select {
+			case s.events <- msg.Event:
+				s.setLast(msg.ID)
+			case <-ctx.Done():
+				return
+			}
+		case msg.Type == "resync_required":
+			s.setLast(msg.LatestID)
+			if s.options.OnResync != nil {
+				s.options.OnResync(msg.LatestID)
+			}
+		}
+	}
+}
+
+// wants reports whether an event matches the options of the subscription. Events carry no
+// group, so with Groups set the server alone selects the nodes.
+func (s *Subscription) wants(event Event) bool {
+	if len(s.nodes) > 0 && len(s.options.Groups) == 0 && !s.nodes[event.NodeID] {
+		return false
+	}
+	return len(s.types) == 0 || s.types[event.Type]
+}
+
+func (s *Subscription) setLast(id uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id > s.last {
+		s.last = id
+	}
+}
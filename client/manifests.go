@@ -0,0 +1,41 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+)
+
+// Apply converges the user's nodes on a YAML or JSON manifest. Prune deletes the nodes the
+// manifest leaves out, and dryRun only plans. When applying fails part way, the returned result
+// holds the plan so the caller can see what was done.
+func (c *Client) Apply(ctx context.Context, manifest []byte, prune, dryRun bool) (*ApplyResult, error) {
+	req := &request{
+		method:      http.MethodPost,
+		path:        "/apply",
+		query:       url.Values{},
+		body:        manifest,
+		contentType: "application/yaml",
+	}
+	if prune {
+		req.query.Set("prune", "true")
+	}
+	if dryRun {
+		req.query.Set("dry_run", "true")
+	}
+
+	var result ApplyResult
+	_, err := c.call(ctx, req, &result)
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		var plan Plan
+		if apiErr.Extension("plan", &plan) == nil && plan.Actions != nil {
+			return &ApplyResult{Plan: &plan}, err
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
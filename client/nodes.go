@@ -0,0 +1,200 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+)
+
+// Formats of node import and export documents
+const (
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+	FormatCSV  = "csv"
+)
+
+// nodeAction is the response of starting and stopping a node
+type nodeAction struct {
+	Message string `json:"message"`
+	Node    Node   `json:"node"`
+}
+
+// ListNodes returns the nodes of the authenticated user
+func (c *Client) ListNodes(ctx context.Context) ([]Node, error) {
+	var nodes []Node
+	if _, err := c.call(ctx, &request{method: http.MethodGet, path: "/nodes"}, &nodes); err != nil {
+		return nil, err
+	}
+	return nodes, nil
+}
+
+// GetNode returns a node
+func (c *Client) GetNode(ctx context.Context, id uint) (*Node, error) {
+	var node Node
+	if _, err := c.call(ctx, &request{method: http.MethodGet, path: nodePath(id)}, &node); err != nil {
+		return nil, err
+	}
+	return &node, nil
+}
+
+// CreateNode creates a node. Retries carry the same Idempotency-Key, so a retried
+// request never creates a second node.
+func (c *Client) CreateNode(ctx context.Context, spec NodeSpec) (*Node, error) {
+	req, err := jsonRequest(http.MethodPost, "/nodes", spec)
+	if err != nil {
+		return nil, err
+	}
+
+	var node Node
+	if _, err := c.call(ctx, withIdempotencyKey(req), &node); err != nil {
+		return nil, err
+	}
+	return &node, nil
+}
+
+// UpdateNode replaces the editable fields of a node. A non-zero version makes the update
+// conditional: it fails with ErrVersionConflict when the node changed since that version.
+// Moving a running node to another address fails with CodeNodeRunning; PatchNode can restart it.
+func (c *Client) UpdateNode(ctx context.Context, id uint, version uint, update NodeUpdate) (*Node, error) {
+	req, err := jsonRequest(http.MethodPut, nodePath(id), update)
+	if err != nil {
+		return nil, err
+	}
+	req.header = ifMatch(id, version)
+
+	var node Node
+	if _, err := c.call(ctx, req, &node); err != nil {
+		return nil, err
+	}
+	return &node, nil
+}
+
+// PatchNode changes the non-nil fields of the patch. Moving a running node to another
+// address requires restart. A non-zero version makes the patch conditional, like UpdateNode.
+func (c *Client) PatchNode(ctx context.Context, id uint, version uint, patch NodePatch, restart bool) (*Node, error) {
+	req, err := jsonRequest(http.MethodPatch, nodePath(id), patch)
+	if err != nil {
+		return nil, err
+	}
+	req.contentType = "application/merge-patch+json"
+	req.header = ifMatch(id, version)
+	if restart {
+		req.query = url.Values{"restart": {"true"}}
+	}
+
+	var node Node
+	if _, err := c.call(ctx, req, &node); err != nil {
+		return nil, err
+	}
+	return &node, nil
+}
+
+// DeleteNode deletes a node; a non-zero version makes the deletion conditional
+func (c *Client) DeleteNode(ctx context.Context, id uint, version uint) error {
+	_, err := c.call(ctx, &request{method: http.MethodDelete, path: nodePath(id), header: ifMatch(id, version)}, nil)
+	return err
+}
+
+// StartNode starts the node's server and returns the node as running
+func (c *Client) StartNode(ctx context.Context, id uint) (*Node, error) {
+	var result nodeAction
+	if _, err := c.call(ctx, withIdempotencyKey(&request{method: http.MethodPost, path: nodePath(id) + "/start"}), &result); err != nil {
+		return nil, err
+	}
+	return &result.Node, nil
+}
+
+// StopNode stops the node's server and returns the node as stopped
+func (c *Client) StopNode(ctx context.Context, id uint) (*Node, error) {
+	var result nodeAction
+	if _, err := c.call(ctx, withIdempotencyKey(&request{method: http.MethodPost, path: nodePath(id) + "/stop"}), &result); err != nil {
+		return nil, err
+	}
+	return &result.Node, nil
+}
+
+// CheckNodeHealth runs a health check now. An unhealthy node is a result, not an error.
+func (c *Client) CheckNodeHealth(ctx context.Context, id uint) (*NodeHealth, error) {
+	var health NodeHealth
+	if _, err := c.call(ctx, &request{method: http.MethodGet, path: nodePath(id) + "/health"}, &health); err != nil {
+		return nil, err
+	}
+	return &health, nil
+}
+
+// NodeStats returns the traffic statistics of the node's server
+func (c *Client) NodeStats(ctx context.Context, id uint) (*NodeStats, error) {
+	var stats NodeStats
+	if _, err := c.call(ctx, &request{method: http.MethodGet, path: nodePath(id) + "/stats"}, &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// ImportNodes creates every node of a JSON, YAML or CSV document, or none of them.
+// When the server rejects the document, the per-row errors are in the returned result
+// together with an error matching ErrInvalidRequest.
+func (c *Client) ImportNodes(ctx context.Context, format string, document []byte, dryRun bool) (*ImportResult, error) {
+	req := &request{
+		method:      http.MethodPost,
+		path:        "/nodes/import",
+		query:       url.Values{"format": {format}},
+		body:        document,
+		contentType: contentTypeFor(format),
+	}
+	if dryRun {
+		req.query.Set("dry_run", "true")
+	}
+
+	var result ImportResult
+	_, err := c.call(ctx, req, &result)
+	var apiErr *Error
+	if errors.As(err, &apiErr) && apiErr.Code == CodeImportRejected {
+		if apiErr.Extension("result", &result) == nil {
+			return &result, err
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ExportNodes downloads all nodes of the authenticated user as a JSON, YAML or CSV document
+func (c *Client) ExportNodes(ctx context.Context, format string) ([]byte, error) {
+	req := &request{
+		method: http.MethodGet,
+		path:   "/nodes/export",
+		query:  url.Values{"format": {format}},
+		header: http.Header{"Accept": {contentTypeFor(format)}},
+	}
+	_, body, err := c.send(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+func nodePath(id uint) string {
+	return "/nodes/" + formatID(id)
+}
+
+// ifMatch returns the precondition header of a version, or none for version 0
+func ifMatch(id uint, version uint) http.Header {
+	if version == 0 {
+		return nil
+	}
+	return http.Header{"If-Match": {etag(id, version)}}
+}
+
+func contentTypeFor(format string) string {
+	switch format {
+	case FormatYAML:
+		return "application/yaml"
+	case FormatCSV:
+		return "text/csv"
+	default:
+		return "application/json"
+	}
+}
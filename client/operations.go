@@ -0,0 +1,139 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Operation statuses; succeeded, failed and cancelled are final
+const (
+	OperationPending   = "pending"
+	OperationRunning   = "running"
+	OperationSucceeded = "succeeded"
+	OperationFailed    = "failed"
+	OperationCancelled = "cancelled"
+)
+
+// Operation is a node action running in the background. Result holds the outcome of a
+// succeeded operation, and ErrorCode and Error the reason a failed one gave up.
+type Operation struct {
+	ID              uint            `json:"id"`
+	UserID          uint            `json:"user_id"`
+	NodeID          uint            `json:"node_id,omitempty"`
+	Type            string          `json:"type"`
+	Status          string          `json:"status"`
+	Progress        int             `json:"progress"`
+	Message         string          `json:"message,omitempty"`
+	Result          json.RawMessage `json:"result,omitempty"`
+	ErrorCode       string          `json:"error_code,omitempty"`
+	Error           string          `json:"error,omitempty"`
+	CancelRequested bool            `json:"cancel_requested"`
+	CreatedAt       time.Time       `json:"created_at"`
+	UpdatedAt       time.Time       `json:"updated_at"`
+	StartedAt       *time.Time      `json:"started_at,omitempty"`
+	FinishedAt      *time.Time      `json:"finished_at,omitempty"`
+}
+
+// Finished reports whether the operation reached a final status
+func (o *Operation) Finished() bool {
+	return o.Status == OperationSucceeded || o.Status == OperationFailed || o.Status == OperationCancelled
+}
+
+// DecodeResult decodes the result of a succeeded operation into out
+func (o *Operation) DecodeResult(out interface{}) error {
+	return json.Unmarshal(o.Result, out)
+}
+
+// OperationFilter narrows ListOperations; zero fields match everything
+type OperationFilter struct {
+	NodeID uint
+	Status string
+	Limit  int
+}
+
+// operationPollInterval is how often WaitOperation asks for the status of an operation
+const operationPollInterval = 500 * time.Millisecond
+
+// StartNodeAsync asks for the node's server to be started in the background and returns the operation
+func (c *Client) StartNodeAsync(ctx context.Context, id uint) (*Operation, error) {
+	return c.submitNodeAction(ctx, id, "start")
+}
+
+// StopNodeAsync asks for the node's server to be stopped in the background and returns the operation
+func (c *Client) StopNodeAsync(ctx context.Context, id uint) (*Operation, error) {
+	return c.submitNodeAction(ctx, id, "stop")
+}
+
+func (c *Client) submitNodeAction(ctx context.Context, id uint, action string) (*Operation, error) {
+	req := &request{method: http.MethodPost, path: nodePath(id) + "/" + action, query: url.Values{"async": {"true"}}}
+
+	var operation Operation
+	if _, err := c.call(ctx, withIdempotencyKey(req), &operation); err != nil {
+		return nil, err
+	}
+	return &operation, nil
+}
+
+// ListOperations returns the operations of the authenticated user, newest first
+func (c *Client) ListOperations(ctx context.Context, filter OperationFilter) ([]Operation, error) {
+	query := url.Values{}
+	if filter.NodeID != 0 {
+		query.Set("node_id", formatID(filter.NodeID))
+	}
+	if filter.Status != "" {
+		query.Set("status", filter.Status)
+	}
+	if filter.Limit > 0 {
+		query.Set("limit", strconv.Itoa(filter.Limit))
+	}
+
+	var operations []Operation
+	if _, err := c.call(ctx, &request{method: http.MethodGet, path: "/operations", query: query}, &operations); err != nil {
+		return nil, err
+	}
+	return operations, nil
+}
+
+// GetOperation returns the current state of an operation
+func (c *Client) GetOperation(ctx context.Context, id uint) (*Operation, error) {
+	var operation Operation
+	if _, err := c.call(ctx, &request{method: http.MethodGet, path: operationPath(id)}, &operation); err != nil {
+		return nil, err
+	}
+	return &operation, nil
+}
+
+// CancelOperation asks for an operation to stop. The returned operation may still be running
+// while it winds down; an operation that already finished fails with CodeOperationFinished.
+func (c *Client) CancelOperation(ctx context.Context, id uint) (*Operation, error) {
+	var operation Operation
+	if _, err := c.call(ctx, &request{method: http.MethodPost, path: operationPath(id) + "/cancel"}, &operation); err != nil {
+		return nil, err
+	}
+	return &operation, nil
+}
+
+// WaitOperation polls an operation until it finishes or ctx is done, and returns its final state.
+// A failed or cancelled operation is returned without an error; check its Status.
+func (c *Client) WaitOperation(ctx context.Context, id uint) (*Operation, error) {
+	for {
+		operation, err := c.GetOperation(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if operation.Finished() {
+			return operation, nil
+		}
+		if err := sleep(ctx, operationPollInterval); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func operationPath(id uint) string {
+	return "/operations/" + formatID(id)
+}
@@ -0,0 +1,114 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Quota scopes; the default quota applies to every user and team without a quota of their own
+const (
+	QuotaScopeDefault = "default"
+	QuotaScopeUser    = "user"
+	QuotaScopeTeam    = "team"
+)
+
+// Quota limits the nodes of a user or team. A nil limit is unlimited, and an empty
+// AllowedPorts allows every port.
+type Quota struct {
+	ID                       uint      `json:"id"`
+	Scope                    string    `json:"scope"`
+	SubjectID                uint      `json:"subject_id"`
+	MaxNodes                 *int      `json:"max_nodes"`
+	MaxRunningNodes          *int      `json:"max_running_nodes"`
+	MaxHealthChecksPerMinute *int      `json:"max_health_checks_per_minute"`
+	AllowedPorts             string    `json:"allowed_ports"`
+	UpdatedAt                time.Time `json:"updated_at"`
+}
+
+// QuotaLimits are the limits SetQuota puts in place of the previous ones. AllowedPorts lists
+// ports and ranges such as "8000-8099,9000".
+type QuotaLimits struct {
+	MaxNodes                 *int   `json:"max_nodes"`
+	MaxRunningNodes          *int   `json:"max_running_nodes"`
+	MaxHealthChecksPerMinute *int   `json:"max_health_checks_per_minute"`
+	AllowedPorts             string `json:"allowed_ports"`
+}
+
+// QuotaUsage is what a user or team uses next to the quota in effect for it, which is nil
+// when no quota applies
+type QuotaUsage struct {
+	Scope                  string `json:"scope"`
+	SubjectID              uint   `json:"subject_id"`
+	Name                   string `json:"name,omitempty"`
+	Quota                  *Quota `json:"quota"`
+	Nodes                  int    `json:"nodes"`
+	RunningNodes           int    `json:"running_nodes"`
+	HealthChecksLastMinute int    `json:"health_checks_last_minute"`
+}
+
+// Usage is the quota usage of the authenticated user and of each of their teams
+type Usage struct {
+	User  QuotaUsage   `json:"user"`
+	Teams []QuotaUsage `json:"teams"`
+}
+
+// QuotaViolation is the "quota" member of a CodeQuotaExceeded error, read with
+// Error.Extension. Limit names the field of the quota that was exceeded.
+type QuotaViolation struct {
+	Limit        string `json:"limit"`
+	Scope        string `json:"scope"`
+	SubjectID    uint   `json:"subject_id"`
+	Max          *int   `json:"max,omitempty"`
+	Port         int    `json:"port,omitempty"`
+	AllowedPorts string `json:"allowed_ports,omitempty"`
+}
+
+// Usage returns the quotas of the authenticated user and their teams with what they use
+func (c *Client) Usage(ctx context.Context) (*Usage, error) {
+	var usage Usage
+	if _, err := c.call(ctx, &request{method: http.MethodGet, path: "/usage"}, &usage); err != nil {
+		return nil, err
+	}
+	return &usage, nil
+}
+
+// ListQuotas returns the default, user and team quotas; admin only
+func (c *Client) ListQuotas(ctx context.Context) ([]Quota, error) {
+	var quotas []Quota
+	if _, err := c.call(ctx, &request{method: http.MethodGet, path: "/admin/quotas"}, &quotas); err != nil {
+		return nil, err
+	}
+	return quotas, nil
+}
+
+// SetQuota replaces the quota of a user or team, or the default quota when scope is
+// QuotaScopeDefault and subjectID is ignored; admin only
+func (c *Client) SetQuota(ctx context.Context, scope string, subjectID uint, limits QuotaLimits) (*Quota, error) {
+	req, err := jsonRequest(http.MethodPut, quotaPath(scope, subjectID), limits)
+	if err != nil {
+		return nil, err
+	}
+
+	var quota Quota
+	if _, err := c.call(ctx, req, &quota); err != nil {
+		return nil, err
+	}
+	return &quota, nil
+}
+
+// DeleteQuota removes the quota of a user or team, or the default quota; admin only
+func (c *Client) DeleteQuota(ctx context.Context, scope string, subjectID uint) error {
+	_, err := c.call(ctx, &request{method: http.MethodDelete, path: quotaPath(scope, subjectID)}, nil)
+	return err
+}
+
+func quotaPath(scope string, subjectID uint) string {
+	switch scope {
+	case QuotaScopeUser:
+		return "/admin/quotas/users/" + formatID(subjectID)
+	case QuotaScopeTeam:
+		return "/admin/quotas/teams/" + formatID(subjectID)
+	}
+	return "/admin/quotas/default"
+}
@@ -0,0 +1,159 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Access levels of a node, each including the ones before it
+const (
+	AccessView    = "view"
+	AccessOperate = "operate"
+	AccessAdmin   = "admin"
+)
+
+// Team member roles; team admins manage the team and administer its nodes
+const (
+	TeamRoleAdmin  = "admin"
+	TeamRoleMember = "member"
+)
+
+// Team is a group of users sharing the nodes it owns
+type Team struct {
+	ID        uint         `json:"id"`
+	Name      string       `json:"name"`
+	CreatedAt time.Time    `json:"created_at"`
+	Members   []TeamMember `json:"members,omitempty"`
+}
+
+// TeamMember is the membership of a user in a team
+type TeamMember struct {
+	TeamID    uint      `json:"team_id"`
+	UserID    uint      `json:"user_id"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NodeGrant is the access a user was given to a node they do not own
+type NodeGrant struct {
+	NodeID    uint      `json:"node_id"`
+	UserID    uint      `json:"user_id"`
+	Level     string    `json:"level"`
+	GrantedBy uint      `json:"granted_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NodeTransfer names the new owner of a node, its new team, or both. A TeamID of 0 takes
+// the node out of its team.
+type NodeTransfer struct {
+	UserID *uint `json:"user_id,omitempty"`
+	TeamID *uint `json:"team_id,omitempty"`
+}
+
+// ListTeams returns the teams of the authenticated user
+func (c *Client) ListTeams(ctx context.Context) ([]Team, error) {
+	var teams []Team
+	if _, err := c.call(ctx, &request{method: http.MethodGet, path: "/teams"}, &teams); err != nil {
+		return nil, err
+	}
+	return teams, nil
+}
+
+// GetTeam returns a team with its members
+func (c *Client) GetTeam(ctx context.Context, id uint) (*Team, error) {
+	var team Team
+	if _, err := c.call(ctx, &request{method: http.MethodGet, path: teamPath(id)}, &team); err != nil {
+		return nil, err
+	}
+	return &team, nil
+}
+
+// CreateTeam creates a team with the authenticated user as its admin
+func (c *Client) CreateTeam(ctx context.Context, name string) (*Team, error) {
+	req, err := jsonRequest(http.MethodPost, "/teams", map[string]string{"name": name})
+	if err != nil {
+		return nil, err
+	}
+
+	var team Team
+	if _, err := c.call(ctx, withIdempotencyKey(req), &team); err != nil {
+		return nil, err
+	}
+	return &team, nil
+}
+
+// DeleteTeam deletes a team; it fails with CodeTeamHasNodes while the team owns nodes
+func (c *Client) DeleteTeam(ctx context.Context, id uint) error {
+	_, err := c.call(ctx, &request{method: http.MethodDelete, path: teamPath(id)}, nil)
+	return err
+}
+
+// SetTeamMember adds a user to a team or changes their role
+func (c *Client) SetTeamMember(ctx context.Context, teamID uint, userID uint, role string) (*TeamMember, error) {
+	req, err := jsonRequest(http.MethodPut, teamPath(teamID)+"/members/"+formatID(userID), map[string]string{"role": role})
+	if err != nil {
+		return nil, err
+	}
+
+	var member TeamMember
+	if _, err := c.call(ctx, req, &member); err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+// RemoveTeamMember removes a user from a team; members may remove themselves to leave it
+func (c *Client) RemoveTeamMember(ctx context.Context, teamID uint, userID uint) error {
+	_, err := c.call(ctx, &request{method: http.MethodDelete, path: teamPath(teamID) + "/members/" + formatID(userID)}, nil)
+	return err
+}
+
+// ListNodeGrants returns the users a node is shared with
+func (c *Client) ListNodeGrants(ctx context.Context, nodeID uint) ([]NodeGrant, error) {
+	var grants []NodeGrant
+	if _, err := c.call(ctx, &request{method: http.MethodGet, path: nodePath(nodeID) + "/grants"}, &grants); err != nil {
+		return nil, err
+	}
+	return grants, nil
+}
+
+// GrantNodeAccess shares a node with a user at the access level, replacing any earlier grant
+func (c *Client) GrantNodeAccess(ctx context.Context, nodeID uint, userID uint, level string) (*NodeGrant, error) {
+	req, err := jsonRequest(http.MethodPut, nodePath(nodeID)+"/grants/"+formatID(userID), map[string]string{"level": level})
+	if err != nil {
+		return nil, err
+	}
+
+	var grant NodeGrant
+	if _, err := c.call(ctx, req, &grant); err != nil {
+		return nil, err
+	}
+	return &grant, nil
+}
+
+// RevokeNodeAccess stops sharing a node with a user
+func (c *Client) RevokeNodeAccess(ctx context.Context, nodeID uint, userID uint) error {
+	_, err := c.call(ctx, &request{method: http.MethodDelete, path: nodePath(nodeID) + "/grants/" + formatID(userID)}, nil)
+	return err
+}
+
+// TransferNode hands a node to another owner or team and returns it. A non-zero version makes
+// the transfer conditional, like UpdateNode.
+func (c *Client) TransferNode(ctx context.Context, id uint, version uint, transfer NodeTransfer) (*Node, error) {
+	req, err := jsonRequest(http.MethodPost, nodePath(id)+"/transfer", transfer)
+	if err != nil {
+		return nil, err
+	}
+	req.header = ifMatch(id, version)
+
+	var node Node
+	if _, err := c.call(ctx, req, &node); err != nil {
+		return nil, err
+	}
+	return &node, nil
+}
+
+func teamPath(id uint) string {
+	return "/teams/" + formatID(id)
+}
@@ -0,0 +1,222 @@
+package client
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Node is a node server. The API encodes nodes with their Go field names.
+type Node struct {
+	ID            uint      `json:"ID"`
+	UserID        uint      `json:"UserID"`
+	TeamID        *uint     `json:"TeamID"`
+	Name          string    `json:"Name"`
+	IP            string    `json:"IP"`
+	Status        string    `json:"Status"`
+	HealthStatus  string    `json:"HealthStatus"`
+	Location      string    `json:"Location"`
+	Port          int       `json:"Port"`
+	LastChecked   time.Time `json:"LastChecked"`
+	Version       uint      `json:"Version"`
+	Group         string    `json:"Group"`         // Group the node's manifest puts it in
+	CheckInterval int       `json:"CheckInterval"` // Seconds between health checks; 0 is every monitor round
+	StartAt       string    `json:"StartAt"`       // Daily UTC time, as HH:MM, the node is started at
+	StopAt        string    `json:"StopAt"`        // Daily UTC time, as HH:MM, the node is stopped at
+}
+
+// ETag returns the entity tag of this version of the node, for conditional updates
+func (n *Node) ETag() string {
+	return etag(n.ID, n.Version)
+}
+
+// NodeSpec describes a node to create. AutoPort takes a port from the configured pool,
+// and SubnetID without an IP allocates a free address of that subnet.
+type NodeSpec struct {
+	Name     string `json:"Name"`
+	IP       string `json:"IP,omitempty"`
+	Port     int    `json:"Port,omitempty"`
+	Location string `json:"Location,omitempty"`
+	AutoPort bool   `json:"auto_port,omitempty"`
+	SubnetID uint   `json:"subnet_id,omitempty"`
+}
+
+// NodeUpdate holds every editable field of a node
+type NodeUpdate struct {
+	Name     string `json:"name"`
+	IP       string `json:"ip"`
+	Port     int    `json:"port"`
+	Location string `json:"location"`
+}
+
+// NodePatch holds the fields to change with a merge patch; nil fields are left alone
+type NodePatch struct {
+	Name     *string `json:"name,omitempty"`
+	IP       *string `json:"ip,omitempty"`
+	Port     *int    `json:"port,omitempty"`
+	Location *string `json:"location,omitempty"`
+}
+
+// NodeHealth is the result of a health check
+type NodeHealth struct {
+	NodeID       uint      `json:"node_id"`
+	HealthStatus string    `json:"health_status"`
+	LastChecked  time.Time `json:"last_checked"`
+	CheckError   string    `json:"check_error,omitempty"`
+}
+
+// NodeStats is the traffic of a node's server
+type NodeStats struct {
+	NodeID      uint              `json:"node_id"`
+	Mode        string            `json:"mode"`
+	Since       time.Time         `json:"since"`
+	Requests    uint64            `json:"requests"`
+	StatusCodes map[string]uint64 `json:"status_codes"`
+	BytesIn     uint64            `json:"bytes_in"`
+	BytesOut    uint64            `json:"bytes_out"`
+	Latency     struct {
+		Buckets []struct {
+			LE    float64 `json:"le"`
+			Count uint64  `json:"count"`
+		} `json:"buckets"`
+		Count      uint64  `json:"count"`
+		SumSeconds float64 `json:"sum_seconds"`
+	} `json:"latency"`
+}
+
+// NodeRecord is a node in import and export documents
+type NodeRecord struct {
+	Name     string `json:"name"`
+	IP       string `json:"ip"`
+	Port     int    `json:"port"`
+	Location string `json:"location,omitempty"`
+}
+
+// ImportResult summarizes a bulk import
+type ImportResult struct {
+	DryRun   bool   `json:"dry_run"`
+	Imported int    `json:"imported"`
+	Nodes    []Node `json:"nodes,omitempty"`
+	Errors   []struct {
+		Row   int    `json:"row"`
+		Name  string `json:"name,omitempty"`
+		Error string `json:"error"`
+	} `json:"errors,omitempty"`
+}
+
+// User is a user account. The API encodes users with their Go field names.
+type User struct {
+	ID      uint   `json:"ID"`
+	Name    string `json:"Name"`
+	Email   string `json:"Email"`
+	IsAdmin bool   `json:"IsAdmin"`
+}
+
+// UserUpdate holds the editable fields of a user
+type UserUpdate struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// UserPatch holds the fields to change with a merge patch; nil fields are left alone
+type UserPatch struct {
+	Name  *string `json:"name,omitempty"`
+	Email *string `json:"email,omitempty"`
+}
+
+// Subnet is an address range node IPs are allocated from
+type Subnet struct {
+	ID          uint   `json:"id"`
+	Name        string `json:"name"`
+	CIDR        string `json:"cidr"`
+	Description string `json:"description"`
+}
+
+// SubnetUtilization is the usage report of a subnet
+type SubnetUtilization struct {
+	Subnet             Subnet  `json:"subnet"`
+	TotalAddresses     uint64  `json:"total_addresses"`
+	UsedAddresses      int     `json:"used_addresses"`
+	FreeAddresses      uint64  `json:"free_addresses"`
+	UtilizationPercent float64 `json:"utilization_percent"`
+	Addresses          []struct {
+		IP    string `json:"ip"`
+		Nodes []uint `json:"nodes"`
+	} `json:"addresses"`
+}
+
+// PortRange is a pool of ports handed out for an IP, or every IP when IP is empty
+type PortRange struct {
+	ID        uint   `json:"id"`
+	IP        string `json:"ip"`
+	StartPort int    `json:"start_port"`
+	EndPort   int    `json:"end_port"`
+}
+
+// PortReservation ties an address to the node that owns it
+type PortReservation struct {
+	ID        uint      `json:"id"`
+	NodeID    uint      `json:"node_id"`
+	IP        string    `json:"ip"`
+	Port      int       `json:"port"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WebSocketStats describes the WebSocket connections of the server
+type WebSocketStats struct {
+	ConnectedClients   int    `json:"connected_clients"`
+	TotalConnections   uint64 `json:"total_connections"`
+	MessagesSent       uint64 `json:"messages_sent"`
+	MessagesDropped    uint64 `json:"messages_dropped"`
+	SlowDisconnects    uint64 `json:"slow_client_disconnects"`
+	TimeoutDisconnects uint64 `json:"timeout_disconnects"`
+}
+
+// LogLevels are the log levels in effect on the server
+type LogLevels struct {
+	Level      string            `json:"level"`
+	Components map[string]string `json:"components"`
+}
+
+// Plan is the list of actions that converge the nodes on a manifest
+type Plan struct {
+	Actions []struct {
+		Action  string `json:"action"`
+		Node    string `json:"node"`
+		NodeID  uint   `json:"node_id,omitempty"`
+		Changes []struct {
+			Field string      `json:"field"`
+			From  interface{} `json:"from"`
+			To    interface{} `json:"to"`
+		} `json:"changes,omitempty"`
+	} `json:"actions"`
+	Summary map[string]int `json:"summary"`
+}
+
+// ApplyResult reports the plan of a manifest and whether it was applied
+type ApplyResult struct {
+	Plan    *Plan    `json:"plan"`
+	Applied bool     `json:"applied"`
+	Errors  []string `json:"errors,omitempty"`
+}
+
+// Event is a node or user event. Data holds the type specific payload; decode it with DecodeData.
+type Event struct {
+	ID        uint64          `json:"id"`
+	Type      string          `json:"type"`
+	Version   int             `json:"version"`
+	Timestamp time.Time       `json:"timestamp"`
+	UserID    uint            `json:"user_id,omitempty"`
+	NodeID    uint            `json:"node_id,omitempty"`
+	Data      json.RawMessage `json:"data,omitempty"`
+}
+
+// DecodeData decodes the payload of the event into out
+func (e *Event) DecodeData(out interface{}) error {
+	return json.Unmarshal(e.Data, out)
+}
+
+// message is a message received on the event WebSocket: an event or a control message
+type message struct {
+	Event
+	LatestID uint64 `json:"latest_id"`
+}
@@ -0,0 +1,55 @@
+package client
+
+import (
+	"context"
+	"net/http"
+)
+
+// ListUsers returns every user
+func (c *Client) ListUsers(ctx context.Context) ([]User, error) {
+	var users []User
+	if _, err := c.call(ctx, &request{method: http.MethodGet, path: "/users"}, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// UpdateUser replaces the name and email of a user
+func (c *Client) UpdateUser(ctx context.Context, id uint, update UserUpdate) (*User, error) {
+	req, err := jsonRequest(http.MethodPut, userPath(id), update)
+	if err != nil {
+		return nil, err
+	}
+
+	var user User
+	if _, err := c.call(ctx, req, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// PatchUser changes the non-nil fields of the patch
+func (c *Client) PatchUser(ctx context.Context, id uint, patch UserPatch) (*User, error) {
+	req, err := jsonRequest(http.MethodPatch, userPath(id), patch)
+	if err != nil {
+		return nil, err
+	}
+	req.contentType = "application/merge-patch+json"
+
+	var user User
+	if _, err := c.call(ctx, req, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// DeleteUser deletes a user; it fails with CodeUserHasNodes while the user owns nodes and with
+// CodeLastTeamAdmin while they are the only admin of a team
+func (c *Client) DeleteUser(ctx context.Context, id uint) error {
+	_, err := c.call(ctx, &request{method: http.MethodDelete, path: userPath(id)}, nil)
+	return err
+}
+
+func userPath(id uint) string {
+	return "/users/" + formatID(id)
+}
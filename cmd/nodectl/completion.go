@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+)
+
+var completionCommand = &command{
+	name:    "completion",
+	summary: "Print a shell completion script",
+	usage:   "bash|zsh|fish",
+	run:     runCompletion,
+}
+
+// completionEntry is a command path with the words that can follow it
+type completionEntry struct {
+	path  string
+	words []string
+}
+
+func runCompletion(ctx context.Context, env *environment, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	entries := completionEntries(commands, nil)
+	switch args[0] {
+	case "bash":
+		fmt.Print(bashCompletion(entries))
+	case "zsh":
+		// zsh runs bash completion functions through bashcompinit
+		fmt.Print("autoload -U +X bashcompinit && bashcompinit\n" + bashCompletion(entries))
+	case "fish":
+		fmt.Print(fishCompletion(entries))
+	default:
+		return fmt.Errorf("unsupported shell %q; use bash, zsh or fish", args[0])
+	}
+	return nil
+}
+
+// completionEntries lists, for every command path, its subcommands or its flags
+func completionEntries(tree []*command, path []string) []completionEntry {
+	entries := []completionEntry{{path: strings.Join(path, " ")}}
+	for _, cmd := range tree {
+		entries[0].words = append(entries[0].words, cmd.name)
+
+		cmdPath := append(append([]string(nil), path...), cmd.name)
+		if len(cmd.subcommands) > 0 {
+			entries = append(entries, completionEntries(cmd.subcommands, cmdPath)...)
+			continue
+		}
+		entries = append(entries, completionEntry{path: strings.Join(cmdPath, " "), words: flagNames(cmd)})
+	}
+	return entries
+}
+
+// flagNames returns the flags of a command as they are typed
+func flagNames(cmd *command) []string {
+	flags := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	(&environment{}).register(flags)
+	if cmd.flags != nil {
+		cmd.flags(flags)
+	}
+
+	var names []string
+	flags.VisitAll(func(f *flag.Flag) { names = append(names, "-"+f.Name) })
+	return names
+}
+
+// commandPaths lists the command paths of the entries, quoted for a shell
+func commandPaths(entries []completionEntry) []string {
+	var paths []string
+	for _, entry := range entries[1:] {
+		paths = append(paths, fmt.Sprintf("%q", entry.path))
+	}
+	return paths
+}
+
+// bashCompletion builds the script; flag values are skipped by only following words that
+// extend a known command path
+func bashCompletion(entries []completionEntry) string {
+	var script strings.Builder
+	fmt.Fprintf(&script, `# nodectl completion; load with: source <(nodectl completion bash)
+_nodectl() {
+	local cur path word
+	cur="${COMP_WORDS[COMP_CWORD]}"
+	path=""
+	for word in "${COMP_WORDS[@]:1:COMP_CWORD-1}"; do
+		case "${path:+$path }$word" in
+		%s) path="${path:+$path }$word" ;;
+		esac
+	done
+
+	local words=""
+	case "$path" in
+`, strings.Join(commandPaths(entries), "|"))
+	for _, entry := range entries {
+		fmt.Fprintf(&script, "\t%q) words=%q ;;\n", entry.path, strings.Join(entry.words, " "))
+	}
+	script.WriteString(`	*) words="" ;;
+	esac
+	COMPREPLY=($(compgen -W "$words" -- "$cur"))
+}
+complete -o default -F _nodectl nodectl
+`)
+	return script.String()
+}
+
+func fishCompletion(entries []completionEntry) string {
+	var script strings.Builder
+	fmt.Fprintf(&script, `# nodectl completion; load with: nodectl completion fish | source
+function __nodectl_path
+	set -l known %s
+	set -l path ''
+	for word in (commandline -opc)[2..-1]
+		set -l candidate (string trim -- "$path $word")
+		if contains -- $candidate $known
+			set path $candidate
+		end
+	end
+	echo $path
+end
+
+complete -c nodectl -f
+`, strings.Join(commandPaths(entries), " "))
+	for _, entry := range entries {
+		for _, word := range entry.words {
+			fmt.Fprintf(&script, "complete -c nodectl -n 'test \"$(__nodectl_path)\" = %q' -a %q\n", entry.path, word)
+		}
+	}
+	return script.String()
+}
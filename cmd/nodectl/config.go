@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"node_management_application/client"
+)
+
+// defaultServer is used when neither a flag, the environment nor a login names a server
+const defaultServer = "http://localhost:8080"
+
+// credentials is what "nodectl login" stores. The password is never written to disk;
+// when the token expires the user logs in again.
+type credentials struct {
+	Server string `json:"server"`
+	Email  string `json:"email"`
+	Token  string `json:"token"`
+}
+
+// environment is the server, token and output format a command runs with. Flags take
+// precedence over NODE_API_URL and NODE_API_TOKEN, which take precedence over the stored login.
+type environment struct {
+	server string
+	token  string
+	output string
+	stored credentials
+}
+
+// register adds the flags every command accepts
+func (e *environment) register(flags *flag.FlagSet) {
+	flags.StringVar(&e.server, "server", os.Getenv("NODE_API_URL"), "base URL of the node management API")
+	flags.StringVar(&e.token, "token", os.Getenv("NODE_API_TOKEN"), "bearer token to use instead of the stored login")
+	flags.StringVar(&e.output, "o", formatTable, "output format: table, json or yaml")
+}
+
+// load validates the flags and fills the gaps from the stored login
+func (e *environment) load() error {
+	if e.output != formatTable && e.output != formatJSON && e.output != formatYAML {
+		return fmt.Errorf("unknown output format %q; use table, json or yaml", e.output)
+	}
+
+	stored, err := loadCredentials()
+	if err != nil {
+		return err
+	}
+	e.stored = stored
+
+	if e.server == "" {
+		e.server = stored.Server
+	}
+	if e.server == "" {
+		e.server = defaultServer
+	}
+	// A stored token is only valid for the server it came from
+	if e.token == "" && stored.Server == e.server {
+		e.token = stored.Token
+	}
+	return nil
+}
+
+// client returns an API client for the environment's server and token
+func (e *environment) client() (*client.Client, error) {
+	if e.token == "" {
+		return nil, fmt.Errorf("not logged in to %s; run \"nodectl login\" first", e.server)
+	}
+	return client.New(e.server, client.WithToken(e.token), client.WithUserAgent("nodectl")), nil
+}
+
+// credentialsPath returns where the login is stored, e.g. ~/.config/nodectl/credentials.json.
+// NODECTL_CONFIG overrides it.
+func credentialsPath() (string, error) {
+	if path := os.Getenv("NODECTL_CONFIG"); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "nodectl", "credentials.json"), nil
+}
+
+func loadCredentials() (credentials, error) {
+	var stored credentials
+	path, err := credentialsPath()
+	if err != nil {
+		return stored, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return stored, nil
+	}
+	if err != nil {
+		return stored, err
+	}
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return stored, fmt.Errorf("invalid credentials file %s: %v", path, err)
+	}
+	return stored, nil
+}
+
+// saveCredentials writes the login readable by the current user only
+func saveCredentials(stored credentials) error {
+	path, err := credentialsPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o600)
+}
+
+func removeCredentials() error {
+	path, err := credentialsPath()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
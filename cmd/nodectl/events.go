@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"node_management_application/client"
+)
+
+var eventFlags struct {
+	nodes  listFlag
+	groups listFlag
+	types  listFlag
+	since  uint64
+}
+
+// listFlag collects a flag given several times or as a comma separated list
+type listFlag []string
+
+func (l *listFlag) String() string { return strings.Join(*l, ",") }
+
+func (l *listFlag) Set(value string) error {
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
+	}
+	return nil
+}
+
+var eventsCommand = &command{
+	name:    "events",
+	summary: "Follow node and user events",
+	subcommands: []*command{
+		{
+			name:    "watch",
+			summary: "Print events as they happen until interrupted",
+			flags: func(flags *flag.FlagSet) {
+				flags.Var(&eventFlags.nodes, "node", "only events of this node ID; repeatable")
+				flags.Var(&eventFlags.groups, "group", "only events of the nodes of this manifest group; repeatable")
+				flags.Var(&eventFlags.types, "type", "only events of this type, e.g. node.started; repeatable")
+				flags.Uint64Var(&eventFlags.since, "since", 0, "first replay the events after this event ID")
+			},
+			run: runEventsWatch,
+		},
+	},
+}
+
+var logsCommand = &command{
+	name:    "logs",
+	summary: "Follow the log of a node",
+	subcommands: []*command{
+		{
+			name:    "tail",
+			summary: "Print the lifecycle and health log of a node as it grows",
+			usage:   "ID",
+			flags: func(flags *flag.FlagSet) {
+				flags.Uint64Var(&eventFlags.since, "since", 0, "first print the entries after this event ID; 0 replays the retained history")
+			},
+			run: runLogsTail,
+		},
+	},
+}
+
+func runEventsWatch(ctx context.Context, env *environment, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+
+	options := client.EventOptions{Groups: eventFlags.groups, Types: eventFlags.types, ResumeAfter: eventFlags.since}
+	for _, value := range eventFlags.nodes {
+		id, err := nodeID([]string{value})
+		if err != nil {
+			return err
+		}
+		options.Nodes = append(options.Nodes, id)
+	}
+
+	return follow(ctx, env, options, func(event client.Event) error {
+		switch env.output {
+		case formatJSON:
+			return writeJSON(os.Stdout, event, "")
+		case formatYAML:
+			fmt.Println("---")
+			return writeYAML(os.Stdout, event)
+		default:
+			node := "-"
+			if event.NodeID != 0 {
+				node = strconv.FormatUint(uint64(event.NodeID), 10)
+			}
+			fmt.Printf("%s  %-8d %-20s node=%-5s %s\n", event.Timestamp.Local().Format(time.RFC3339), event.ID, event.Type, node, summarize(event))
+			return nil
+		}
+	})
+}
+
+// runLogsTail follows a node's entries of the event log. The server keeps no process output
+// for nodes; their starts, stops, crashes, health changes and alerts are their log.
+func runLogsTail(ctx context.Context, env *environment, args []string) error {
+	id, err := nodeID(args)
+	if err != nil {
+		return err
+	}
+
+	options := client.EventOptions{Nodes: []uint{id}, ResumeAfter: eventFlags.since, Replay: true}
+	return follow(ctx, env, options, func(event client.Event) error {
+		switch env.output {
+		case formatJSON:
+			return writeJSON(os.Stdout, event, "")
+		case formatYAML:
+			fmt.Println("---")
+			return writeYAML(os.Stdout, event)
+		default:
+			fmt.Printf("%s %s %s\n", event.Timestamp.Local().Format(time.RFC3339), strings.TrimPrefix(event.Type, "node."), summarize(event))
+			return nil
+		}
+	})
+}
+
+// follow subscribes to events and prints them until interrupted
+func follow(ctx context.Context, env *environment, options client.EventOptions, print func(client.Event) error) error {
+	c, err := env.client()
+	if err != nil {
+		return err
+	}
+
+	options.OnResync = func(latestID uint64) {
+		fmt.Fprintf(os.Stderr, "Warning: events before %d are no longer available; continuing with live events\n", latestID)
+	}
+	subscription, err := c.Subscribe(ctx, options)
+	if err != nil {
+		return err
+	}
+	defer subscription.Close()
+
+	for event := range subscription.Events() {
+		if err := print(event); err != nil {
+			return err
+		}
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	return subscription.Err()
+}
+
+// summarize renders the payload of an event as sorted key=value pairs
+func summarize(event client.Event) string {
+	var data map[string]interface{}
+	if len(event.Data) == 0 || event.DecodeData(&data) != nil {
+		return string(event.Data)
+	}
+
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		value := data[key]
+		if _, nested := value.(map[string]interface{}); nested {
+			encoded, _ := json.Marshal(value)
+			value = string(encoded)
+		}
+		pairs = append(pairs, fmt.Sprintf("%s=%v", key, value))
+	}
+	return strings.Join(pairs, " ")
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"node_management_application/client"
+
+	"golang.org/x/term"
+)
+
+var (
+	loginEmail         string
+	loginPasswordStdin bool
+)
+
+var loginCommand = &command{
+	name:    "login",
+	summary: "Log in and store the token for later commands",
+	flags: func(flags *flag.FlagSet) {
+		flags.StringVar(&loginEmail, "email", "", "email address; prompted for when omitted")
+		flags.BoolVar(&loginPasswordStdin, "password-stdin", false, "read the password from standard input")
+	},
+	run: runLogin,
+}
+
+var logoutCommand = &command{
+	name:    "logout",
+	summary: "Forget the stored token",
+	run: func(ctx context.Context, env *environment, args []string) error {
+		if err := removeCredentials(); err != nil {
+			return err
+		}
+		fmt.Fprintln(os.Stderr, "Logged out.")
+		return nil
+	},
+}
+
+func runLogin(ctx context.Context, env *environment, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+
+	stdin := bufio.NewReader(os.Stdin)
+	email := loginEmail
+	if email == "" && env.stored.Server == env.server {
+		email = env.stored.Email
+	}
+	if email == "" {
+		fmt.Fprint(os.Stderr, "Email: ")
+		line, err := stdin.ReadString('\n')
+		if err != nil && line == "" {
+			return err
+		}
+		email = strings.TrimSpace(line)
+	}
+
+	password, err := readPassword(stdin)
+	if err != nil {
+		return err
+	}
+
+	c := client.New(env.server, client.WithUserAgent("nodectl"))
+	result, err := c.Login(ctx, email, password)
+	if err != nil {
+		return err
+	}
+
+	if err := saveCredentials(credentials{Server: env.server, Email: email, Token: result.Token}); err != nil {
+		return fmt.Errorf("logged in but failed to store the token: %v", err)
+	}
+	fmt.Fprintf(os.Stderr, "Logged in to %s as %s.\n", env.server, result.User.Email)
+	return nil
+}
+
+// readPassword prompts without echo on a terminal and reads one line otherwise
+func readPassword(stdin *bufio.Reader) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !loginPasswordStdin && term.IsTerminal(fd) {
+		fmt.Fprint(os.Stderr, "Password: ")
+		password, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		return string(password), err
+	}
+
+	line, err := stdin.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
@@ -0,0 +1,182 @@
+// Command nodectl operates the nodes of a node management server through its API.
+//
+// Run "nodectl login" once to store a token, then for example:
+//
+//	nodectl nodes list
+//	nodectl nodes create -name web-1 -ip 127.0.0.1 -auto-port
+//	nodectl nodes start 3 -async
+//	nodectl operations wait 12
+//	nodectl usage
+//	nodectl events watch -node 3 -o json
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+// errUsage reports invalid arguments; the command has already printed its usage
+var errUsage = errors.New("invalid usage")
+
+// command is a nodectl command or a group of subcommands
+type command struct {
+	name        string
+	summary     string
+	usage       string // Arguments after the command path
+	flags       func(*flag.FlagSet)
+	run         func(ctx context.Context, env *environment, args []string) error
+	subcommands []*command
+}
+
+// commands is the command tree; completion scripts are generated from it too
+var commands []*command
+
+func init() {
+	commands = []*command{
+		loginCommand,
+		logoutCommand,
+		nodesCommand,
+		operationsCommand,
+		teamsCommand,
+		usageCommand,
+		logsCommand,
+		eventsCommand,
+		importCommand,
+		exportCommand,
+		completionCommand,
+	}
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	os.Exit(execute(ctx, os.Args[1:]))
+}
+
+// execute runs the command named by args and returns the exit code
+func execute(ctx context.Context, args []string) int {
+	cmd, path, rest := resolve(commands, nil, args)
+	if cmd == nil || cmd.run == nil {
+		printUsage(cmd, path)
+		if len(args) == 0 || isHelp(args[0]) {
+			return 0
+		}
+		return 2
+	}
+
+	env := &environment{}
+	flags := flag.NewFlagSet("nodectl "+joinPath(path), flag.ContinueOnError)
+	flags.Usage = func() { printCommandUsage(flags, cmd, path) }
+	env.register(flags)
+	if cmd.flags != nil {
+		cmd.flags(flags)
+	}
+
+	positional, err := parseInterspersed(flags, rest)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if err := env.load(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	if err := cmd.run(ctx, env, positional); err != nil {
+		if errors.Is(err, errUsage) {
+			flags.Usage()
+			return 2
+		}
+		if ctx.Err() != nil {
+			return 130
+		}
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// resolve walks the command tree along args and returns the deepest command found,
+// its path and the remaining arguments
+func resolve(tree []*command, path []string, args []string) (*command, []string, []string) {
+	if len(args) == 0 {
+		return nil, path, args
+	}
+	for _, cmd := range tree {
+		if cmd.name != args[0] {
+			continue
+		}
+		path = append(path, cmd.name)
+		if len(cmd.subcommands) > 0 {
+			if sub, subPath, rest := resolve(cmd.subcommands, path, args[1:]); sub != nil {
+				return sub, subPath, rest
+			}
+		}
+		return cmd, path, args[1:]
+	}
+	return nil, path, args
+}
+
+// parseInterspersed parses flags wherever they appear, so "nodes get 3 -o json" works
+func parseInterspersed(flags *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+func printUsage(cmd *command, path []string) {
+	tree := commands
+	if cmd != nil {
+		tree = cmd.subcommands
+	}
+
+	width := 12
+	for _, sub := range tree {
+		if len(sub.name) > width {
+			width = len(sub.name)
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Usage: nodectl %s<command> [flags]\n\nCommands:\n", prefix(path))
+	for _, sub := range tree {
+		fmt.Fprintf(os.Stderr, "  %-*s %s\n", width, sub.name, sub.summary)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun \"nodectl %s<command> -h\" for the flags of a command.\n", prefix(path))
+}
+
+func printCommandUsage(flags *flag.FlagSet, cmd *command, path []string) {
+	fmt.Fprintf(os.Stderr, "Usage: nodectl %s [flags] %s\n\n%s\n\nFlags:\n", joinPath(path), cmd.usage, cmd.summary)
+	flags.PrintDefaults()
+}
+
+func isHelp(arg string) bool {
+	return arg == "help" || arg == "-h" || arg == "-help" || arg == "--help"
+}
+
+func joinPath(path []string) string {
+	return strings.Join(path, " ")
+}
+
+func prefix(path []string) string {
+	if len(path) == 0 {
+		return ""
+	}
+	return joinPath(path) + " "
+}
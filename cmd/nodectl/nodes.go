@@ -0,0 +1,283 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"node_management_application/client"
+)
+
+var nodeFlags struct {
+	name     string
+	ip       string
+	port     int
+	location string
+	autoPort bool
+	subnet   uint
+	restart  bool
+	async    bool
+	set      map[string]bool
+}
+
+// nodeFieldFlags registers the flags describing a node; set records which were given
+func nodeFieldFlags(flags *flag.FlagSet) {
+	nodeFlags.set = make(map[string]bool)
+	flags.Func("name", "node name", setNodeFlag("name", func(v string) error { nodeFlags.name = v; return nil }))
+	flags.Func("ip", "IP address the node listens on", setNodeFlag("ip", func(v string) error { nodeFlags.ip = v; return nil }))
+	flags.Func("port", "port the node listens on", setNodeFlag("port", func(v string) (err error) {
+		nodeFlags.port, err = strconv.Atoi(v)
+		return err
+	}))
+	flags.Func("location", "free-form location", setNodeFlag("location", func(v string) error { nodeFlags.location = v; return nil }))
+}
+
+func setNodeFlag(name string, set func(string) error) func(string) error {
+	return func(value string) error {
+		nodeFlags.set[name] = true
+		return set(value)
+	}
+}
+
+var nodesCommand = &command{
+	name:    "nodes",
+	summary: "List and manage nodes",
+	subcommands: []*command{
+		{name: "list", summary: "List your nodes", run: runNodesList},
+		{name: "get", summary: "Show a node", usage: "ID", run: runNodesGet},
+		{
+			name:    "create",
+			summary: "Create a node",
+			flags: func(flags *flag.FlagSet) {
+				nodeFieldFlags(flags)
+				flags.BoolVar(&nodeFlags.autoPort, "auto-port", false, "take a free port from the configured pool")
+				flags.UintVar(&nodeFlags.subnet, "subnet", 0, "allocate the IP from this subnet when -ip is omitted")
+			},
+			run: runNodesCreate,
+		},
+		{
+			name:    "update",
+			summary: "Change the given fields of a node",
+			usage:   "ID",
+			flags: func(flags *flag.FlagSet) {
+				nodeFieldFlags(flags)
+				flags.BoolVar(&nodeFlags.restart, "restart", false, "restart a running node to move it to a new address")
+			},
+			run: runNodesUpdate,
+		},
+		{name: "delete", summary: "Delete a node", usage: "ID", run: runNodesDelete},
+		{name: "start", summary: "Start a node's server", usage: "ID", flags: asyncFlag, run: runNodesStart},
+		{name: "stop", summary: "Stop a node's server", usage: "ID", flags: asyncFlag, run: runNodesStop},
+		{name: "health", summary: "Run a health check now", usage: "ID", run: runNodesHealth},
+		{name: "grants", summary: "List the users a node is shared with", usage: "ID", run: runNodesGrants},
+		{
+			name:    "share",
+			summary: "Share a node with a user",
+			usage:   "ID",
+			flags: func(flags *flag.FlagSet) {
+				flags.UintVar(&shareFlags.user, "user", 0, "ID of the user to share with")
+				flags.StringVar(&shareFlags.level, "level", client.AccessView, "access level: view, operate or admin")
+			},
+			run: runNodesShare,
+		},
+		{
+			name:    "unshare",
+			summary: "Stop sharing a node with a user",
+			usage:   "ID",
+			flags: func(flags *flag.FlagSet) {
+				flags.UintVar(&shareFlags.user, "user", 0, "ID of the user to stop sharing with")
+			},
+			run: runNodesUnshare,
+		},
+		{
+			name:    "transfer",
+			summary: "Hand a node to another owner or team",
+			usage:   "ID",
+			flags: func(flags *flag.FlagSet) {
+				flags.Func("user", "ID of the new owner", optionalID(&shareFlags.newOwner))
+				flags.Func("team", "ID of the new team; 0 takes the node out of its team", optionalID(&shareFlags.newTeam))
+			},
+			run: runNodesTransfer,
+		},
+	},
+}
+
+func runNodesList(ctx context.Context, env *environment, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	c, err := env.client()
+	if err != nil {
+		return err
+	}
+
+	nodes, err := c.ListNodes(ctx)
+	if err != nil {
+		return err
+	}
+	return env.render(nodes, func() *table { return nodeTable(nodes...) })
+}
+
+func runNodesGet(ctx context.Context, env *environment, args []string) error {
+	return withNode(ctx, env, args, func(c *client.Client, id uint) (*client.Node, error) {
+		return c.GetNode(ctx, id)
+	})
+}
+
+func runNodesCreate(ctx context.Context, env *environment, args []string) error {
+	if len(args) != 0 || nodeFlags.name == "" {
+		return errUsage
+	}
+	c, err := env.client()
+	if err != nil {
+		return err
+	}
+
+	node, err := c.CreateNode(ctx, client.NodeSpec{
+		Name:     nodeFlags.name,
+		IP:       nodeFlags.ip,
+		Port:     nodeFlags.port,
+		Location: nodeFlags.location,
+		AutoPort: nodeFlags.autoPort,
+		SubnetID: nodeFlags.subnet,
+	})
+	if err != nil {
+		return err
+	}
+	return env.render(node, func() *table { return nodeTable(*node) })
+}
+
+func runNodesUpdate(ctx context.Context, env *environment, args []string) error {
+	if len(nodeFlags.set) == 0 {
+		return errUsage
+	}
+
+	// Only the fields given on the command line are sent, as a merge patch
+	var patch client.NodePatch
+	if nodeFlags.set["name"] {
+		patch.Name = &nodeFlags.name
+	}
+	if nodeFlags.set["ip"] {
+		patch.IP = &nodeFlags.ip
+	}
+	if nodeFlags.set["port"] {
+		patch.Port = &nodeFlags.port
+	}
+	if nodeFlags.set["location"] {
+		patch.Location = &nodeFlags.location
+	}
+
+	return withNode(ctx, env, args, func(c *client.Client, id uint) (*client.Node, error) {
+		return c.PatchNode(ctx, id, 0, patch, nodeFlags.restart)
+	})
+}
+
+func runNodesDelete(ctx context.Context, env *environment, args []string) error {
+	id, err := nodeID(args)
+	if err != nil {
+		return err
+	}
+	c, err := env.client()
+	if err != nil {
+		return err
+	}
+
+	if err := c.DeleteNode(ctx, id, 0); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Node %d deleted.\n", id)
+	return nil
+}
+
+// asyncFlag lets a node action run in the background on the server
+func asyncFlag(flags *flag.FlagSet) {
+	flags.BoolVar(&nodeFlags.async, "async", false, "return the background operation instead of waiting for the action")
+}
+
+func runNodesStart(ctx context.Context, env *environment, args []string) error {
+	if nodeFlags.async {
+		return withOperation(ctx, env, args, nodeID, func(c *client.Client, id uint) (*client.Operation, error) {
+			return c.StartNodeAsync(ctx, id)
+		})
+	}
+	return withNode(ctx, env, args, func(c *client.Client, id uint) (*client.Node, error) {
+		return c.StartNode(ctx, id)
+	})
+}
+
+func runNodesStop(ctx context.Context, env *environment, args []string) error {
+	if nodeFlags.async {
+		return withOperation(ctx, env, args, nodeID, func(c *client.Client, id uint) (*client.Operation, error) {
+			return c.StopNodeAsync(ctx, id)
+		})
+	}
+	return withNode(ctx, env, args, func(c *client.Client, id uint) (*client.Node, error) {
+		return c.StopNode(ctx, id)
+	})
+}
+
+func runNodesHealth(ctx context.Context, env *environment, args []string) error {
+	id, err := nodeID(args)
+	if err != nil {
+		return err
+	}
+	c, err := env.client()
+	if err != nil {
+		return err
+	}
+
+	health, err := c.CheckNodeHealth(ctx, id)
+	if err != nil {
+		return err
+	}
+	return env.render(health, func() *table {
+		t := &table{header: []string{"NODE", "HEALTH", "CHECKED", "ERROR"}}
+		t.add(health.NodeID, health.HealthStatus, health.LastChecked.Local().Format(time.RFC3339), orDash(health.CheckError))
+		return t
+	})
+}
+
+// withNode runs an operation on the node named by the only argument and prints the result
+func withNode(ctx context.Context, env *environment, args []string, operation func(*client.Client, uint) (*client.Node, error)) error {
+	id, err := nodeID(args)
+	if err != nil {
+		return err
+	}
+	c, err := env.client()
+	if err != nil {
+		return err
+	}
+
+	node, err := operation(c, id)
+	if err != nil {
+		return err
+	}
+	return env.render(node, func() *table { return nodeTable(*node) })
+}
+
+func nodeID(args []string) (uint, error) {
+	return parseID("node", args)
+}
+
+// parseID parses the only argument as the ID of a kind of resource
+func parseID(kind string, args []string) (uint, error) {
+	if len(args) != 1 {
+		return 0, errUsage
+	}
+	id, err := strconv.ParseUint(args[0], 10, 64)
+	if err != nil || id == 0 {
+		return 0, fmt.Errorf("invalid %s ID %q", kind, args[0])
+	}
+	return uint(id), nil
+}
+
+func nodeTable(nodes ...client.Node) *table {
+	t := &table{header: []string{"ID", "NAME", "ADDRESS", "STATUS", "HEALTH", "LOCATION", "VERSION"}}
+	for _, node := range nodes {
+		t.add(node.ID, node.Name, node.IP+":"+strconv.Itoa(node.Port), node.Status, node.HealthStatus, orDash(node.Location), node.Version)
+	}
+	return t
+}
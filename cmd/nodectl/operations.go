@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strconv"
+	"time"
+
+	"node_management_application/client"
+)
+
+var operationFlags struct {
+	node   uint
+	status string
+	limit  int
+}
+
+var operationsCommand = &command{
+	name:    "operations",
+	summary: "Follow and cancel node actions running in the background",
+	subcommands: []*command{
+		{
+			name:    "list",
+			summary: "List your operations, newest first",
+			flags: func(flags *flag.FlagSet) {
+				flags.UintVar(&operationFlags.node, "node", 0, "only operations on this node ID")
+				flags.StringVar(&operationFlags.status, "status", "", "only operations in this status, e.g. running")
+				flags.IntVar(&operationFlags.limit, "limit", 0, "show at most this many")
+			},
+			run: runOperationsList,
+		},
+		{name: "get", summary: "Show the progress of an operation", usage: "ID", run: runOperationsGet},
+		{name: "wait", summary: "Wait for an operation to finish; fails unless it succeeds", usage: "ID", run: runOperationsWait},
+		{name: "cancel", summary: "Cancel a pending or running operation", usage: "ID", run: runOperationsCancel},
+	},
+}
+
+func runOperationsList(ctx context.Context, env *environment, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	c, err := env.client()
+	if err != nil {
+		return err
+	}
+
+	operations, err := c.ListOperations(ctx, client.OperationFilter{
+		NodeID: operationFlags.node,
+		Status: operationFlags.status,
+		Limit:  operationFlags.limit,
+	})
+	if err != nil {
+		return err
+	}
+	return env.render(operations, func() *table { return operationTable(operations...) })
+}
+
+func runOperationsGet(ctx context.Context, env *environment, args []string) error {
+	return withOperation(ctx, env, args, operationID, func(c *client.Client, id uint) (*client.Operation, error) {
+		return c.GetOperation(ctx, id)
+	})
+}
+
+func runOperationsWait(ctx context.Context, env *environment, args []string) error {
+	var operation *client.Operation
+	err := withOperation(ctx, env, args, operationID, func(c *client.Client, id uint) (*client.Operation, error) {
+		var err error
+		operation, err = c.WaitOperation(ctx, id)
+		return operation, err
+	})
+	if err != nil {
+		return err
+	}
+	if operation.Status != client.OperationSucceeded {
+		return fmt.Errorf("operation %d %s", operation.ID, operation.Status)
+	}
+	return nil
+}
+
+func runOperationsCancel(ctx context.Context, env *environment, args []string) error {
+	return withOperation(ctx, env, args, operationID, func(c *client.Client, id uint) (*client.Operation, error) {
+		return c.CancelOperation(ctx, id)
+	})
+}
+
+// withOperation runs a call on the ID given as the only argument and prints the operation it returns
+func withOperation(ctx context.Context, env *environment, args []string, parseID func([]string) (uint, error), call func(*client.Client, uint) (*client.Operation, error)) error {
+	id, err := parseID(args)
+	if err != nil {
+		return err
+	}
+	c, err := env.client()
+	if err != nil {
+		return err
+	}
+
+	operation, err := call(c, id)
+	if err != nil {
+		return err
+	}
+	return env.render(operation, func() *table { return operationTable(*operation) })
+}
+
+func operationID(args []string) (uint, error) {
+	return parseID("operation", args)
+}
+
+func operationTable(operations ...client.Operation) *table {
+	t := &table{header: []string{"ID", "TYPE", "NODE", "STATUS", "PROGRESS", "CREATED", "MESSAGE"}}
+	for _, operation := range operations {
+		message := operation.Message
+		if operation.Error != "" {
+			message = operation.Error
+		}
+		t.add(operation.ID, operation.Type, operation.NodeID, operation.Status, strconv.Itoa(operation.Progress)+"%",
+			operation.CreatedAt.Local().Format(time.RFC3339), orDash(message))
+	}
+	return t
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"node_management_application/client"
+)
+
+var shareFlags struct {
+	user     uint
+	level    string
+	role     string
+	newOwner *uint
+	newTeam  *uint
+}
+
+var teamsCommand = &command{
+	name:    "teams",
+	summary: "Manage the teams that share nodes",
+	subcommands: []*command{
+		{name: "list", summary: "List your teams", run: runTeamsList},
+		{name: "get", summary: "Show a team and its members", usage: "ID", run: runTeamsGet},
+		{name: "create", summary: "Create a team with you as its admin", usage: "NAME", run: runTeamsCreate},
+		{name: "delete", summary: "Delete a team that owns no nodes", usage: "ID", run: runTeamsDelete},
+		{
+			name:    "add-member",
+			summary: "Add a user to a team or change their role",
+			usage:   "TEAM USER",
+			flags: func(flags *flag.FlagSet) {
+				flags.StringVar(&shareFlags.role, "role", client.TeamRoleMember, "role in the team: member or admin")
+			},
+			run: runTeamsAddMember,
+		},
+		{name: "remove-member", summary: "Remove a user from a team; remove yourself to leave", usage: "TEAM USER", run: runTeamsRemoveMember},
+	},
+}
+
+// optionalID parses a flag into an ID that is nil unless the flag was given
+func optionalID(target **uint) func(string) error {
+	return func(value string) error {
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		parsed := uint(id)
+		*target = &parsed
+		return nil
+	}
+}
+
+func runNodesGrants(ctx context.Context, env *environment, args []string) error {
+	id, err := nodeID(args)
+	if err != nil {
+		return err
+	}
+	c, err := env.client()
+	if err != nil {
+		return err
+	}
+
+	grants, err := c.ListNodeGrants(ctx, id)
+	if err != nil {
+		return err
+	}
+	return env.render(grants, func() *table { return grantTable(grants...) })
+}
+
+func runNodesShare(ctx context.Context, env *environment, args []string) error {
+	id, err := nodeID(args)
+	if err != nil {
+		return err
+	}
+	if shareFlags.user == 0 {
+		return errUsage
+	}
+	c, err := env.client()
+	if err != nil {
+		return err
+	}
+
+	grant, err := c.GrantNodeAccess(ctx, id, shareFlags.user, shareFlags.level)
+	if err != nil {
+		return err
+	}
+	return env.render(grant, func() *table { return grantTable(*grant) })
+}
+
+func runNodesUnshare(ctx context.Context, env *environment, args []string) error {
+	id, err := nodeID(args)
+	if err != nil {
+		return err
+	}
+	if shareFlags.user == 0 {
+		return errUsage
+	}
+	c, err := env.client()
+	if err != nil {
+		return err
+	}
+
+	if err := c.RevokeNodeAccess(ctx, id, shareFlags.user); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Node %d is no longer shared with user %d.\n", id, shareFlags.user)
+	return nil
+}
+
+func runNodesTransfer(ctx context.Context, env *environment, args []string) error {
+	if shareFlags.newOwner == nil && shareFlags.newTeam == nil {
+		return errUsage
+	}
+	return withNode(ctx, env, args, func(c *client.Client, id uint) (*client.Node, error) {
+		return c.TransferNode(ctx, id, 0, client.NodeTransfer{UserID: shareFlags.newOwner, TeamID: shareFlags.newTeam})
+	})
+}
+
+func runTeamsList(ctx context.Context, env *environment, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	c, err := env.client()
+	if err != nil {
+		return err
+	}
+
+	teams, err := c.ListTeams(ctx)
+	if err != nil {
+		return err
+	}
+	return env.render(teams, func() *table {
+		t := &table{header: []string{"ID", "NAME", "MEMBERS", "CREATED"}}
+		for _, team := range teams {
+			t.add(team.ID, team.Name, len(team.Members), team.CreatedAt.Local().Format(time.RFC3339))
+		}
+		return t
+	})
+}
+
+func runTeamsGet(ctx context.Context, env *environment, args []string) error {
+	id, err := parseID("team", args)
+	if err != nil {
+		return err
+	}
+	c, err := env.client()
+	if err != nil {
+		return err
+	}
+
+	team, err := c.GetTeam(ctx, id)
+	if err != nil {
+		return err
+	}
+	return env.render(team, func() *table { return memberTable(team.Members...) })
+}
+
+func runTeamsCreate(ctx context.Context, env *environment, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	c, err := env.client()
+	if err != nil {
+		return err
+	}
+
+	team, err := c.CreateTeam(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	return env.render(team, func() *table {
+		t := &table{header: []string{"ID", "NAME", "CREATED"}}
+		t.add(team.ID, team.Name, team.CreatedAt.Local().Format(time.RFC3339))
+		return t
+	})
+}
+
+func runTeamsDelete(ctx context.Context, env *environment, args []string) error {
+	id, err := parseID("team", args)
+	if err != nil {
+		return err
+	}
+	c, err := env.client()
+	if err != nil {
+		return err
+	}
+
+	if err := c.DeleteTeam(ctx, id); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Team %d deleted.\n", id)
+	return nil
+}
+
+func runTeamsAddMember(ctx context.Context, env *environment, args []string) error {
+	teamID, userID, err := teamAndUser(args)
+	if err != nil {
+		return err
+	}
+	c, err := env.client()
+	if err != nil {
+		return err
+	}
+
+	member, err := c.SetTeamMember(ctx, teamID, userID, shareFlags.role)
+	if err != nil {
+		return err
+	}
+	return env.render(member, func() *table { return memberTable(*member) })
+}
+
+func runTeamsRemoveMember(ctx context.Context, env *environment, args []string) error {
+	teamID, userID, err := teamAndUser(args)
+	if err != nil {
+		return err
+	}
+	c, err := env.client()
+	if err != nil {
+		return err
+	}
+
+	if err := c.RemoveTeamMember(ctx, teamID, userID); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "User %d removed from team %d.\n", userID, teamID)
+	return nil
+}
+
+// teamAndUser parses the TEAM USER arguments of the member commands
+func teamAndUser(args []string) (uint, uint, error) {
+	if len(args) != 2 {
+		return 0, 0, errUsage
+	}
+	teamID, err := parseID("team", args[:1])
+	if err != nil {
+		return 0, 0, err
+	}
+	userID, err := parseID("user", args[1:])
+	if err != nil {
+		return 0, 0, err
+	}
+	return teamID, userID, nil
+}
+
+func grantTable(grants ...client.NodeGrant) *table {
+	t := &table{header: []string{"NODE", "USER", "LEVEL", "GRANTED BY", "CREATED"}}
+	for _, grant := range grants {
+		t.add(grant.NodeID, grant.UserID, grant.Level, grant.GrantedBy, grant.CreatedAt.Local().Format(time.RFC3339))
+	}
+	return t
+}
+
+func memberTable(members ...client.TeamMember) *table {
+	t := &table{header: []string{"TEAM", "USER", "ROLE", "SINCE"}}
+	for _, member := range members {
+		t.add(member.TeamID, member.UserID, member.Role, member.CreatedAt.Local().Format(time.RFC3339))
+	}
+	return t
+}
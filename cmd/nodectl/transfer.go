@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"node_management_application/client"
+)
+
+var transferFlags struct {
+	format string
+	dryRun bool
+	file   string
+}
+
+var importCommand = &command{
+	name:    "import",
+	summary: "Create the nodes of a JSON, YAML or CSV file, all or none",
+	usage:   "FILE",
+	flags: func(flags *flag.FlagSet) {
+		flags.StringVar(&transferFlags.format, "format", "", "json, yaml or csv; taken from the file extension when omitted")
+		flags.BoolVar(&transferFlags.dryRun, "dry-run", false, "validate the file without creating anything")
+	},
+	run: runImport,
+}
+
+var exportCommand = &command{
+	name:    "export",
+	summary: "Download your nodes as JSON, YAML or CSV",
+	flags: func(flags *flag.FlagSet) {
+		flags.StringVar(&transferFlags.format, "format", client.FormatJSON, "json, yaml or csv")
+		flags.StringVar(&transferFlags.file, "f", "", "write to this file instead of standard output")
+	},
+	run: runExport,
+}
+
+func runImport(ctx context.Context, env *environment, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	// "-" reads standard input, like apply -f -
+	var document []byte
+	var err error
+	if args[0] == "-" {
+		document, err = io.ReadAll(os.Stdin)
+	} else {
+		document, err = os.ReadFile(args[0])
+	}
+	if err != nil {
+		return err
+	}
+
+	format := transferFlags.format
+	if format == "" {
+		format = formatOf(args[0])
+	}
+
+	c, err := env.client()
+	if err != nil {
+		return err
+	}
+	result, err := c.ImportNodes(ctx, format, document, transferFlags.dryRun)
+	if result == nil {
+		return err
+	}
+
+	// A rejected import still reports which rows were wrong
+	if renderErr := env.render(result, func() *table { return importTable(result) }); renderErr != nil {
+		return renderErr
+	}
+	if err != nil {
+		return err
+	}
+	if result.DryRun {
+		fmt.Fprintf(os.Stderr, "Dry run: %d nodes would be imported.\n", result.Imported)
+	} else {
+		fmt.Fprintf(os.Stderr, "Imported %d nodes.\n", result.Imported)
+	}
+	return nil
+}
+
+func runExport(ctx context.Context, env *environment, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	switch transferFlags.format {
+	case client.FormatJSON, client.FormatYAML, client.FormatCSV:
+	default:
+		return errors.New("format must be json, yaml or csv")
+	}
+
+	c, err := env.client()
+	if err != nil {
+		return err
+	}
+	document, err := c.ExportNodes(ctx, transferFlags.format)
+	if err != nil {
+		return err
+	}
+
+	if transferFlags.file == "" {
+		_, err = os.Stdout.Write(document)
+		return err
+	}
+	return os.WriteFile(transferFlags.file, document, 0o644)
+}
+
+// formatOf guesses the format of a file from its extension, defaulting to JSON
+func formatOf(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return client.FormatYAML
+	case ".csv":
+		return client.FormatCSV
+	default:
+		return client.FormatJSON
+	}
+}
+
+func importTable(result *client.ImportResult) *table {
+	if len(result.Errors) > 0 {
+		t := &table{header: []string{"ROW", "NAME", "ERROR"}}
+		for _, rowErr := range result.Errors {
+			t.add(rowErr.Row, orDash(rowErr.Name), rowErr.Error)
+		}
+		return t
+	}
+	return nodeTable(result.Nodes...)
+}
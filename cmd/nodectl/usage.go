@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"node_management_application/client"
+)
+
+var usageCommand = &command{
+	name:    "usage",
+	summary: "Show your quotas and those of your teams next to what they use",
+	run:     runUsage,
+}
+
+func runUsage(ctx context.Context, env *environment, args []string) error {
+	if len(args) > 0 {
+		return errUsage
+	}
+	c, err := env.client()
+	if err != nil {
+		return err
+	}
+
+	usage, err := c.Usage(ctx)
+	if err != nil {
+		return err
+	}
+	return env.render(usage, func() *table {
+		return usageTable(append([]client.QuotaUsage{usage.User}, usage.Teams...)...)
+	})
+}
+
+func usageTable(usages ...client.QuotaUsage) *table {
+	t := &table{header: []string{"SCOPE", "ID", "NAME", "QUOTA", "NODES", "RUNNING", "CHECKS/MIN", "PORTS"}}
+	for _, usage := range usages {
+		quota := usage.Quota
+		if quota == nil {
+			quota = &client.Quota{}
+		}
+		t.add(usage.Scope, usage.SubjectID, orDash(usage.Name), orDash(quota.Scope),
+			ofLimit(usage.Nodes, quota.MaxNodes),
+			ofLimit(usage.RunningNodes, quota.MaxRunningNodes),
+			ofLimit(usage.HealthChecksLastMinute, quota.MaxHealthChecksPerMinute),
+			orDash(quota.AllowedPorts))
+	}
+	return t
+}
+
+// ofLimit shows a usage next to its limit, or alone when it is unlimited
+func ofLimit(used int, limit *int) string {
+	if limit == nil {
+		return fmt.Sprint(used)
+	}
+	return fmt.Sprintf("%d/%d", used, *limit)
+}
@@ -1,3 +1,104 @@
 package config
 
+import (
+	"os"
+	"strings"
+	"time"
+)
+
 var JWTSecretKey = []byte("IU+/s6wEa9r0dV8FlkVhNp+zFpD+QZ71+RhNdJ2x0fA=")
+
+// AdminEmails lists the users promoted to administrators at startup (comma separated ADMIN_EMAILS)
+var AdminEmails = splitList(os.Getenv("ADMIN_EMAILS"))
+
+// AllowedOrigins lists the browser origins allowed to call the API and open WebSocket connections
+var AllowedOrigins = []string{"http://localhost:8081"} // Replace with the frontend's origin
+
+// NodeRuntime says where node servers run. With "in-process" they bind on this host,
+// so node IPs must exist on a local interface; set NODE_RUNTIME=external to skip that check.
+var NodeRuntime = envOrDefault("NODE_RUNTIME", "in-process")
+
+// EventLogRetention is how many of the most recent events are kept for client replay
+var EventLogRetention = 10000
+
+// WebSocketSendQueue is the number of messages buffered per WebSocket client
+var WebSocketSendQueue = 256
+
+// WebSocketSlowClientPolicy decides what happens when a client's send queue is full:
+// "drop" discards the message, "disconnect" closes the connection
+var WebSocketSlowClientPolicy = envOrDefault("WS_SLOW_CLIENT_POLICY", "drop")
+
+// NodeStatsMode decides the lifetime of node traffic counters: "reset" starts them over
+// whenever a node starts, "persistent" accumulates them in the database across restarts
+var NodeStatsMode = envOrDefault("NODE_STATS_MODE", "reset")
+
+// TracingExporter selects where spans are sent: "none", "otlp", "stdout" or "file".
+// The OTLP exporter honours the standard OTEL_EXPORTER_OTLP_* variables.
+var TracingExporter = envOrDefault("TRACING_EXPORTER", "none")
+
+// TracingFile is the file the "file" exporter appends spans to
+var TracingFile = envOrDefault("TRACING_FILE", "traces.json")
+
+// LogFormat selects the log output: "logfmt" or "json"
+var LogFormat = envOrDefault("LOG_FORMAT", "logfmt")
+
+// LogLevel is the initial base log level; it can be changed at runtime through /admin/log-level
+var LogLevel = envOrDefault("LOG_LEVEL", "info")
+
+// MetricsToken, when set, must be presented as a Bearer token to scrape /metrics
+var MetricsToken = os.Getenv("METRICS_TOKEN")
+
+// GRPCAddress is where the gRPC API listens; set GRPC_ADDR=off to disable it
+var GRPCAddress = envOrDefault("GRPC_ADDR", ":9090")
+
+// LegacyAPISunset is the date the unversioned API aliases go away, announced in their Sunset header
+var LegacyAPISunset = os.Getenv("LEGACY_API_SUNSET")
+
+// IdempotencyKeyTTL is how long the response of a request with an Idempotency-Key is replayed
+var IdempotencyKeyTTL = durationOrDefault("IDEMPOTENCY_KEY_TTL", 24*time.Hour)
+
+// OperationRetention is how long finished operations are kept in the history
+var OperationRetention = durationOrDefault("OPERATION_RETENTION", 30*24*time.Hour)
+
+// WebhookURLs are the endpoints every event is posted to (comma separated WEBHOOK_URLS)
+var WebhookURLs = splitList(os.Getenv("WEBHOOK_URLS"))
+
+// WebhookEvents limits webhooks to these event types (comma separated WEBHOOK_EVENTS); empty sends all
+var WebhookEvents = splitList(os.Getenv("WEBHOOK_EVENTS"))
+
+// WebhookSecret, when set, signs each webhook body with HMAC-SHA256 so endpoints can verify it
+var WebhookSecret = os.Getenv("WEBHOOK_SECRET")
+
+// AuditLogFile is the file events are recorded in for auditing; set AUDIT_LOG_FILE=off to disable it
+var AuditLogFile = envOrDefault("AUDIT_LOG_FILE", "audit.log")
+
+// AlertAfterFailedChecks is the number of consecutive failed health checks that fires an alert
+var AlertAfterFailedChecks = 3
+
+// envOrDefault returns the environment variable or the fallback when it is unset
+func envOrDefault(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// durationOrDefault parses a duration such as "90m" from the environment, or returns the fallback
+// when it is unset or invalid
+func durationOrDefault(key string, fallback time.Duration) time.Duration {
+	if value, err := time.ParseDuration(os.Getenv(key)); err == nil && value > 0 {
+		return value
+	}
+	return fallback
+}
+
+// splitList splits a comma separated value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
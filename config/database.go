@@ -1,7 +1,9 @@
 package config
 
 import (
-	"log"
+	"os"
+
+	"node_management_application/logging"
 
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
@@ -11,10 +13,11 @@ var DB *gorm.DB
 
 func ConnectDatabase() {
     dsn := "root:new_password@tcp(127.0.0.1:3306)/node_management?charset=utf8mb4&parseTime=True&loc=Local"
-    database, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+    database, err := gorm.Open(mysql.Open(dsn), &gorm.Config{TranslateError: true})
     if err != nil {
-        log.Fatal("Failed to connect to database:", err)
+        logging.For("database").Error("Failed to connect to database", "error", err)
+        os.Exit(1)
     }
     DB = database
-    log.Println("Database connected!")
+    logging.For("database").Info("Database connected")
 }
@@ -0,0 +1,128 @@
+package controllers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"node_management_application/events"
+	"node_management_application/logging"
+	"node_management_application/services"
+	"node_management_application/utils"
+
+	"github.com/kataras/iris/v12"
+	"gorm.io/gorm"
+)
+
+var logger = logging.For("controllers")
+
+// errorMapping turns a typed service error into a problem response
+type errorMapping struct {
+	target error
+	status int
+	code   string
+}
+
+// serviceErrors maps every error the services return on purpose to its status and stable code.
+// Errors matched here are safe to show to the client; anything else is reported as internal.
+var serviceErrors = []errorMapping{
+	{services.ErrPortConflict, http.StatusConflict, utils.CodePortConflict},
+	{services.ErrNoFreePort, http.StatusConflict, utils.CodeNoFreePort},
+	{services.ErrPortInUse, http.StatusConflict, utils.CodePortInUse},
+	{services.ErrNodeNotRunning, http.StatusConflict, utils.CodeNodeNotRunning},
+	{services.ErrNodeRunning, http.StatusConflict, utils.CodeNodeRunning},
+	{services.ErrSubnetExhausted, http.StatusConflict, utils.CodeSubnetExhausted},
+	{services.ErrSubnetNotFound, http.StatusNotFound, utils.CodeNotFound},
+	{services.ErrVersionConflict, http.StatusPreconditionFailed, utils.CodeVersionConflict},
+	{services.ErrUnsupportedPatchType, http.StatusUnsupportedMediaType, utils.CodeUnsupportedMediaType},
+	{services.ErrUnsupportedFormat, http.StatusUnsupportedMediaType, utils.CodeUnsupportedMediaType},
+	{services.ErrInvalidPatch, http.StatusBadRequest, utils.CodeInvalidRequest},
+	{services.ErrInvalidDocument, http.StatusBadRequest, utils.CodeInvalidRequest},
+	{services.ErrInvalidManifest, http.StatusBadRequest, utils.CodeValidationFailed},
+	{services.ErrImportRejected, http.StatusUnprocessableEntity, utils.CodeImportRejected},
+	{services.ErrOperationFinished, http.StatusConflict, utils.CodeOperationFinished},
+	{services.ErrAccessDenied, http.StatusForbidden, utils.CodeForbidden},
+	{services.ErrUserNotFound, http.StatusNotFound, utils.CodeNotFound},
+	{services.ErrGrantNotFound, http.StatusNotFound, utils.CodeNotFound},
+	{services.ErrUserHasNodes, http.StatusConflict, utils.CodeUserHasNodes},
+	{services.ErrTeamNotFound, http.StatusNotFound, utils.CodeNotFound},
+	{services.ErrNotTeamMember, http.StatusNotFound, utils.CodeNotFound},
+	{services.ErrTeamNameTaken, http.StatusConflict, utils.CodeConflict},
+	{services.ErrTeamHasNodes, http.StatusConflict, utils.CodeTeamHasNodes},
+	{services.ErrLastTeamAdmin, http.StatusConflict, utils.CodeLastTeamAdmin},
+	{services.ErrQuotaExceeded, http.StatusForbidden, utils.CodeQuotaExceeded},
+	{services.ErrQuotaNotFound, http.StatusNotFound, utils.CodeNotFound},
+	{events.ErrResyncRequired, http.StatusGone, utils.CodeResyncRequired},
+	{gorm.ErrRecordNotFound, http.StatusNotFound, utils.CodeNotFound},
+}
+
+// respondError writes the problem response for an error returned by a service.
+// Unknown errors are logged with the request ID and hidden behind a generic 500.
+func respondError(ctx iris.Context, err error) {
+	respondErrorWith(ctx, err, nil)
+}
+
+// respondErrorWith is respondError with extension members added to the problem
+func respondErrorWith(ctx iris.Context, err error, extensions iris.Map) {
+	var validation *services.ValidationError
+	if errors.As(err, &validation) {
+		fields := make([]utils.FieldError, len(validation.Fields))
+		for i, field := range validation.Fields {
+			fields[i] = utils.FieldError{Field: field.Field, Message: field.Message}
+		}
+		problem := utils.NewProblem(http.StatusBadRequest, utils.CodeValidationFailed, err.Error())
+		problem.Errors = fields
+		problem.Extensions = extensions
+		utils.WriteProblem(ctx, problem)
+		return
+	}
+
+	// Quota errors name the limit; one that frees up by itself is a 429 with Retry-After
+	var quota *services.QuotaError
+	if errors.As(err, &quota) {
+		status := http.StatusForbidden
+		if quota.RetryAfter > 0 {
+			status = http.StatusTooManyRequests
+			ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(quota.RetryAfter.Seconds()))))
+		}
+		problem := utils.NewProblem(status, utils.CodeQuotaExceeded, err.Error())
+		problem.Extensions = iris.Map{"quota": quota}
+		for key, value := range extensions {
+			problem.Extensions[key] = value
+		}
+		utils.WriteProblem(ctx, problem)
+		return
+	}
+
+	for _, mapping := range serviceErrors {
+		if errors.Is(err, mapping.target) {
+			problem := utils.NewProblem(mapping.status, mapping.code, err.Error())
+			problem.Extensions = extensions
+			utils.WriteProblem(ctx, problem)
+			return
+		}
+	}
+
+	logger.ErrorContext(ctx.Request().Context(), "Unhandled error", "method", ctx.Method(), "path", ctx.Path(), "error", err)
+	problem := utils.NewProblem(http.StatusInternalServerError, utils.CodeInternal, "An internal error occurred; quote the correlation ID when reporting it")
+	problem.Extensions = extensions
+	utils.WriteProblem(ctx, problem)
+}
+
+// describeError returns the code and message a problem response would carry for a service
+// error, for failures reported outside a response such as those of background operations
+func describeError(err error) (string, string) {
+	var validation *services.ValidationError
+	if errors.As(err, &validation) {
+		return utils.CodeValidationFailed, err.Error()
+	}
+	for _, mapping := range serviceErrors {
+		if errors.Is(err, mapping.target) {
+			return mapping.code, err.Error()
+		}
+	}
+
+	logger.Error("Unhandled error in background operation", "error", err)
+	return utils.CodeInternal, "An internal error occurred"
+}
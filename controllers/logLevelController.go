@@ -0,0 +1,37 @@
+package controllers
+
+import (
+	"node_management_application/logging"
+	"node_management_application/utils"
+
+	"github.com/kataras/iris/v12"
+)
+
+// logLevelRequest changes the base level, or one component's level when Component is set
+type logLevelRequest struct {
+	Component string `json:"component"`
+	Level     string `json:"level"`
+}
+
+// GetLogLevels - Get the base log level and the per-component overrides
+func GetLogLevels(ctx iris.Context) {
+	ctx.JSON(logging.CurrentLevels())
+}
+
+// SetLogLevel - Change the log level of the application or of one component at runtime
+func SetLogLevel(ctx iris.Context) {
+	var request logLevelRequest
+	if err := ctx.ReadJSON(&request); err != nil {
+		utils.InvalidRequestResponse(ctx, "Invalid request body")
+		return
+	}
+
+	if err := logging.SetLevel(request.Component, request.Level); err != nil {
+		utils.ValidationErrorResponse(ctx, err, utils.FieldError{Field: "level", Message: err.Error()})
+		return
+	}
+
+	logging.For("admin").InfoContext(ctx.Request().Context(), "Log level changed",
+		"component", request.Component, "level", request.Level, "user_id", ctx.Values().GetUintDefault("user_id", 0))
+	ctx.JSON(logging.CurrentLevels())
+}
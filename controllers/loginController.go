@@ -6,6 +6,7 @@ import (
 
 	"node_management_application/config"
 	"node_management_application/models"
+	"node_management_application/utils"
 
 	"golang.org/x/crypto/bcrypt"
 
@@ -28,8 +29,7 @@ func Login(ctx iris.Context) {
 		Password string `json:"password"`
 	}
 	if err := ctx.ReadJSON(&credentials); err != nil {
-		ctx.StatusCode(http.StatusBadRequest)
-		ctx.JSON(iris.Map{"error": "Invalid request"})
+		utils.InvalidRequestResponse(ctx, "Invalid request")
 		return
 	}
 
@@ -37,16 +37,14 @@ func Login(ctx iris.Context) {
 	var user models.User
 	result := config.DB.Where("email = ?", credentials.Email).First(&user)
 	if result.Error != nil {
-		ctx.StatusCode(http.StatusUnauthorized)
-		ctx.JSON(iris.Map{"error": "Invalid email or password"})
+		utils.ProblemResponse(ctx, http.StatusUnauthorized, utils.CodeInvalidCredentials, "Invalid email or password")
 		return
 	}
 
 	// Compare the hashed password with the provided password
 	err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(credentials.Password))
 	if err != nil {
-		ctx.StatusCode(http.StatusUnauthorized)
-		ctx.JSON(iris.Map{"error": "Invalid email or password"})
+		utils.ProblemResponse(ctx, http.StatusUnauthorized, utils.CodeInvalidCredentials, "Invalid email or password")
 		return
 	}
 
@@ -62,8 +60,7 @@ func Login(ctx iris.Context) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	tokenString, err := token.SignedString(config.JWTSecretKey)
 	if err != nil {
-		ctx.StatusCode(http.StatusInternalServerError)
-		ctx.JSON(iris.Map{"error": "Failed to generate token"})
+		respondError(ctx, err)
 		return
 	}
 
@@ -0,0 +1,50 @@
+package controllers
+
+import (
+	"node_management_application/services"
+	"node_management_application/utils"
+
+	"github.com/kataras/iris/v12"
+)
+
+// ApplyManifest - Diff a fleet manifest against the authenticated user's nodes and apply the resulting plan
+func ApplyManifest(ctx iris.Context) {
+	// Retrieve user ID from the context
+	userID := ctx.Values().GetUintDefault("user_id", 0)
+
+	body, err := ctx.GetBody()
+	if err != nil {
+		utils.InvalidRequestResponse(ctx, "Invalid request body")
+		return
+	}
+
+	manifest, err := services.ParseManifest(body)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	// Unmanaged nodes are only deleted when pruning is requested explicitly
+	prune := ctx.URLParamBoolDefault("prune", false)
+	dryRun := ctx.URLParamBoolDefault("dry_run", false)
+
+	result, err := services.ApplyManifest(ctx.Request().Context(), userID, manifest, prune, dryRun)
+	if err != nil {
+		respondErrorWith(ctx, err, iris.Map{"plan": planOf(result)})
+		return
+	}
+
+	if ctx.URLParam("output") == "text" {
+		ctx.WriteString(result.Plan.String())
+		return
+	}
+	ctx.JSON(result)
+}
+
+// planOf returns the plan of a partially applied manifest, if one was computed
+func planOf(result *services.ApplyResult) *services.Plan {
+	if result == nil {
+		return nil
+	}
+	return result.Plan
+}
@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"errors"
+
+	"node_management_application/models"
+	"node_management_application/services"
+	"node_management_application/utils"
+
+	"github.com/kataras/iris/v12"
+)
+
+// GetNodeGrants - List the users a node is shared with; requires admin access to the node
+func GetNodeGrants(ctx iris.Context) {
+	// Retrieve user ID from the context
+	userID := ctx.Values().GetUintDefault("user_id", 0)
+
+	var node models.Node
+
+	// Fetch node by ID and ensure the user may administer it
+	if err := fetchNodeByIDAndUser(ctx, &node, userID, models.AccessAdmin); err != nil {
+		return
+	}
+
+	grants, err := services.ListNodeGrants(ctx.Request().Context(), node.ID)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(grants)
+}
+
+// GrantNodeAccess - Give a user view, operate or admin access to a node, replacing any earlier grant
+func GrantNodeAccess(ctx iris.Context) {
+	// Retrieve user ID from the context
+	userID := ctx.Values().GetUintDefault("user_id", 0)
+
+	var node models.Node
+
+	// Fetch node by ID and ensure the user may administer it
+	if err := fetchNodeByIDAndUser(ctx, &node, userID, models.AccessAdmin); err != nil {
+		return
+	}
+
+	var request struct {
+		Level string `json:"level"`
+	}
+	if err := ctx.ReadJSON(&request); err != nil {
+		utils.InvalidRequestResponse(ctx, "Invalid request body")
+		return
+	}
+
+	grant, err := services.GrantNodeAccess(ctx.Request().Context(), &node, ctx.Params().GetUintDefault("userId", 0), request.Level, userID)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(grant)
+}
+
+// RevokeNodeAccess - Remove the grant of a user to a node
+func RevokeNodeAccess(ctx iris.Context) {
+	// Retrieve user ID from the context
+	userID := ctx.Values().GetUintDefault("user_id", 0)
+
+	var node models.Node
+
+	// Fetch node by ID and ensure the user may administer it
+	if err := fetchNodeByIDAndUser(ctx, &node, userID, models.AccessAdmin); err != nil {
+		return
+	}
+
+	if err := services.RevokeNodeAccess(ctx.Request().Context(), &node, ctx.Params().GetUintDefault("userId", 0)); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(iris.Map{"message": "Access revoked successfully"})
+}
+
+// TransferNode - Hand a node to another user or team. Only its owner or an admin of its team may.
+func TransferNode(ctx iris.Context) {
+	// Retrieve user ID from the context
+	userID := ctx.Values().GetUintDefault("user_id", 0)
+
+	var node models.Node
+
+	// Fetch node by ID and ensure the user may administer it
+	if err := fetchNodeByIDAndUser(ctx, &node, userID, models.AccessAdmin); err != nil {
+		return
+	}
+
+	// Refuse the transfer when the client saw an older version
+	if !utils.IfMatch(ctx, utils.ETag(node.ID, node.Version)) {
+		return
+	}
+
+	// team_id 0 takes the node out of its team
+	var request struct {
+		UserID *uint `json:"user_id"`
+		TeamID *uint `json:"team_id"`
+	}
+	if err := ctx.ReadJSON(&request); err != nil {
+		utils.InvalidRequestResponse(ctx, "Invalid request body")
+		return
+	}
+
+	err := services.TransferNode(ctx.Request().Context(), userID, &node, services.NodeTransfer{UserID: request.UserID, TeamID: request.TeamID})
+	if errors.Is(err, services.ErrVersionConflict) {
+		utils.PreconditionFailedResponse(ctx)
+		return
+	}
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.Header("ETag", utils.ETag(node.ID, node.Version))
+	ctx.JSON(node)
+}
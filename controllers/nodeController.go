@@ -1,79 +1,107 @@
 package controllers
 
 import (
-	"fmt"
+	"context"
+	"errors"
 	"net/http"
-	"time"
 
-	"node_management_application/config"
 	"node_management_application/models"
 	"node_management_application/services"
 	"node_management_application/utils"
 
 	"github.com/kataras/iris/v12"
+	"gorm.io/gorm"
 )
 
-// GetNodes - Fetch a list of all nodes belonging to the authenticated user
+// GetNodes - Fetch the nodes the authenticated user owns or shares
 func GetNodes(ctx iris.Context) {
 	// Retrieve user ID from the context
 	userID := ctx.Values().GetUintDefault("user_id", 0)
 
-	// Fetch nodes for the authenticated user
-	var nodes []models.Node
-	if result := config.DB.Where("user_id = ?", userID).Find(&nodes); result.Error != nil {
-		ctx.StatusCode(http.StatusInternalServerError)
-		ctx.JSON(iris.Map{"error": result.Error.Error()})
+	// Fetch the nodes the user owns, shares through a team or was granted
+	nodes, err := services.ListUserNodes(ctx.Request().Context(), userID)
+	if err != nil {
+		respondError(ctx, err)
 		return
 	}
 
 	ctx.JSON(nodes)
 }
 
-// CreateNode - Add a new node for the authenticated user
-func CreateNode(ctx iris.Context) {
+// GetNode - Fetch a single node the authenticated user may view
+func GetNode(ctx iris.Context) {
 	// Retrieve user ID from the context
 	userID := ctx.Values().GetUintDefault("user_id", 0)
 
 	var node models.Node
 
-	// Read request body
-	if err := ctx.ReadJSON(&node); err != nil {
-		ctx.StatusCode(http.StatusBadRequest)
-		ctx.JSON(iris.Map{"error": "Invalid request body"})
+	// Fetch node by ID and ensure the user may view it
+	if err := fetchNodeByIDAndUser(ctx, &node, userID, models.AccessView); err != nil {
 		return
 	}
 
-	// Validate node data
-	if err := services.ValidateNodeData(node.Name, node.IP, node.Port); err != nil {
-		utils.ValidationErrorResponse(ctx, err)
+	etag := utils.ETag(node.ID, node.Version)
+	ctx.Header("ETag", etag)
+	if utils.IfNoneMatch(ctx, etag) {
+		ctx.StatusCode(http.StatusNotModified)
 		return
 	}
 
-	// Assign user ID and default values
-	node.UserID = userID
-	node.LastChecked = time.Now()
-	node.Status = "Stopped"
-	node.HealthStatus = "Unhealthy"
+	ctx.JSON(node)
+}
+
+// CreateNode - Add a new node for the authenticated user
+func CreateNode(ctx iris.Context) {
+	// Retrieve user ID from the context
+	userID := ctx.Values().GetUintDefault("user_id", 0)
+
+	// auto_port asks for a port from the configured pool instead of a caller supplied one,
+	// and subnet_id without an IP asks for a free address of that subnet
+	var request struct {
+		models.Node
+		AutoPort bool `json:"auto_port"`
+		SubnetID uint `json:"subnet_id"`
+	}
 
-	// Save the node to the database
-	if result := config.DB.Create(&node); result.Error != nil {
-		ctx.StatusCode(http.StatusInternalServerError)
-		ctx.JSON(iris.Map{"error": "Failed to save node to database"})
+	// Read request body
+	if err := ctx.ReadJSON(&request); err != nil {
+		utils.InvalidRequestResponse(ctx, "Invalid request body")
+		return
+	}
+
+	// Validate and save the node, reserving its port
+	node, err := services.CreateNode(ctx.Request().Context(), userID, services.NodeSpec{
+		Name:     request.Name,
+		IP:       request.IP,
+		Port:     request.Port,
+		Location: request.Location,
+		AutoPort: request.AutoPort,
+		SubnetID: request.SubnetID,
+	})
+	if err != nil {
+		respondError(ctx, err)
 		return
 	}
 
+	ctx.Header("ETag", utils.ETag(node.ID, node.Version))
 	ctx.JSON(node)
 }
 
-// UpdateNode - Update an existing node's details belonging to the authenticated user
+// UpdateNode - Update the details of a node the authenticated user administers.
+// Changing the address of a running node requires ?restart=true, which stops and restarts it.
 func UpdateNode(ctx iris.Context) {
 	// Retrieve user ID from the context
 	userID := ctx.Values().GetUintDefault("user_id", 0)
 
 	var node models.Node
 
-	// Fetch node by ID and ensure it belongs to the authenticated user
-	if err := fetchNodeByIDAndUser(ctx, &node, userID); err != nil {
+	// Fetch node by ID and ensure the user may administer it
+	if err := fetchNodeByIDAndUser(ctx, &node, userID, models.AccessAdmin); err != nil {
+		return
+	}
+
+	// Refuse the update when the client edited an older version
+	if !utils.IfMatch(ctx, utils.ETag(node.ID, node.Version)) {
 		return
 	}
 
@@ -85,164 +113,181 @@ func UpdateNode(ctx iris.Context) {
 		Location string `json:"location"`
 	}
 	if err := ctx.ReadJSON(&updatedData); err != nil {
-		utils.ValidationErrorResponse(ctx, err)
+		utils.InvalidRequestResponse(ctx, "Invalid request body")
 		return
 	}
-	if err := services.ValidateNodeData(updatedData.Name, updatedData.IP, updatedData.Port); err != nil {
-		utils.ValidationErrorResponse(ctx, err)
-		return
-	}
-
-	// Apply updates
-	node.Name = updatedData.Name
-	node.IP = updatedData.IP
-	node.Port = updatedData.Port
-	node.Location = updatedData.Location
 
-	// Save changes to the database
-	if result := config.DB.Save(&node); result.Error != nil {
-		ctx.StatusCode(http.StatusInternalServerError)
-		ctx.JSON(iris.Map{"error": "Failed to update node"})
+	// Save the changes, unless someone else saved first
+	err := services.UpdateNode(ctx.Request().Context(), &node, services.NodeUpdate{
+		Name:     updatedData.Name,
+		IP:       updatedData.IP,
+		Port:     updatedData.Port,
+		Location: updatedData.Location,
+	}, ctx.URLParamBoolDefault("restart", false))
+	if err != nil {
+		respondNodeUpdateError(ctx, &node, err)
 		return
 	}
 
+	ctx.Header("ETag", utils.ETag(node.ID, node.Version))
 	ctx.JSON(node)
 }
 
-// DeleteNode - Remove a node belonging to the authenticated user
+// respondNodeUpdateError writes the problem response of a failed node update. A node that was
+// saved but did not start again is returned with the error.
+func respondNodeUpdateError(ctx iris.Context, node *models.Node, err error) {
+	switch {
+	case errors.Is(err, services.ErrVersionConflict):
+		utils.PreconditionFailedResponse(ctx)
+	case errors.Is(err, services.ErrNodeRestartFailed):
+		respondErrorWith(ctx, err, iris.Map{"node": node})
+	default:
+		respondError(ctx, err)
+	}
+}
+
+// DeleteNode - Remove a node the authenticated user administers
 func DeleteNode(ctx iris.Context) {
 	// Retrieve user ID from the context
 	userID := ctx.Values().GetUintDefault("user_id", 0)
 
 	var node models.Node
 
-	// Fetch node by ID and ensure it belongs to the authenticated user
-	if err := fetchNodeByIDAndUser(ctx, &node, userID); err != nil {
+	// Fetch node by ID and ensure the user may administer it
+	if err := fetchNodeByIDAndUser(ctx, &node, userID, models.AccessAdmin); err != nil {
 		return
 	}
 
-	// Delete the node
-	if result := config.DB.Delete(&node); result.Error != nil {
-		ctx.StatusCode(http.StatusInternalServerError)
-		ctx.JSON(iris.Map{"error": "Failed to delete node"})
+	// Refuse the delete when the client saw an older version
+	if !utils.IfMatch(ctx, utils.ETag(node.ID, node.Version)) {
+		return
+	}
+
+	// Delete the node and release its port
+	err := services.DeleteNode(ctx.Request().Context(), &node)
+	if errors.Is(err, services.ErrVersionConflict) {
+		utils.PreconditionFailedResponse(ctx)
+		return
+	}
+	if err != nil {
+		respondError(ctx, err)
 		return
 	}
 
 	ctx.JSON(iris.Map{"message": "Node deleted successfully"})
 }
 
-// Helper: Fetch node by ID and ensure it belongs to the authenticated user
-func fetchNodeByIDAndUser(ctx iris.Context, node *models.Node, userID uint) error {
+// Helper: Fetch node by ID and ensure the authenticated user has the required access to it.
+// A node the user cannot see is not found; one they may only see is forbidden.
+func fetchNodeByIDAndUser(ctx iris.Context, node *models.Node, userID uint, required string) error {
 	id := ctx.Params().GetUintDefault("id", 0)
 
-	// Find the node by ID and user ID
-	if result := config.DB.Where("id = ? AND user_id = ?", id, userID).First(node); result.Error != nil {
-		ctx.StatusCode(http.StatusNotFound)
-		ctx.JSON(iris.Map{"error": "Node not found or access denied"})
-		return result.Error
+	// Find the node by ID and check the user's access
+	found, err := services.GetUserNode(ctx.Request().Context(), userID, id, required)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.NotFoundResponse(ctx, "Node not found or access denied")
+		} else {
+			respondError(ctx, err)
+		}
+		return err
 	}
 
+	*node = *found
 	return nil
 }
 
-// StartNode - Start a node belonging to the authenticated user
+// StartNode - Start a node the authenticated user may operate, in the background when asked
 func StartNode(ctx iris.Context) {
 	// Retrieve user ID from the context
 	userID := ctx.Values().GetUintDefault("user_id", 0)
 
-	nodeID := ctx.Params().GetUintDefault("id", 0)
-
-	// Fetch node by ID and ensure it belongs to the authenticated user
 	var node models.Node
-	if result := config.DB.Where("id = ? AND user_id = ?", nodeID, userID).First(&node); result.Error != nil {
-		ctx.StatusCode(http.StatusNotFound)
-		ctx.JSON(iris.Map{"error": "Node not found or access denied"})
+
+	// Fetch node by ID and ensure the user may operate it
+	if err := fetchNodeByIDAndUser(ctx, &node, userID, models.AccessOperate); err != nil {
 		return
 	}
 
-	// Start the node using the service
-	err := services.StartNodeConcurrently(&node)
-	if err != nil {
-		ctx.StatusCode(http.StatusConflict)
-		ctx.JSON(iris.Map{"error": err.Error()})
+	if wantsAsync(ctx) {
+		submitNodeOperation(ctx, userID, &node, "node.start", func(runCtx context.Context, report func(int, string)) (interface{}, error) {
+			report(10, "Starting the node's server")
+			if err := services.StartNode(runCtx, &node); err != nil {
+				return nil, err
+			}
+			return node, nil
+		})
 		return
 	}
 
-	// Update status in the database
-	if result := config.DB.Model(&node).Update("status", "Running"); result.Error != nil {
-		ctx.StatusCode(http.StatusInternalServerError)
-		ctx.JSON(iris.Map{"error": "Failed to update node status"})
+	// Start the node and record it as running
+	if err := services.StartNode(ctx.Request().Context(), &node); err != nil {
+		respondError(ctx, err)
 		return
 	}
 
 	ctx.JSON(iris.Map{"message": "Node started successfully", "node": node})
 }
 
-// StopNode - Stop a node belonging to the authenticated user
+// StopNode - Stop a node the authenticated user may operate, in the background when asked
 func StopNode(ctx iris.Context) {
 	// Retrieve user ID from the context
 	userID := ctx.Values().GetUintDefault("user_id", 0)
 
-	nodeID := ctx.Params().GetUintDefault("id", 0)
-
-	// Fetch node by ID and ensure it belongs to the authenticated user
 	var node models.Node
-	if result := config.DB.Where("id = ? AND user_id = ?", nodeID, userID).First(&node); result.Error != nil {
-		ctx.StatusCode(http.StatusNotFound)
-		ctx.JSON(iris.Map{"error": "Node not found or access denied"})
+
+	// Fetch node by ID and ensure the user may operate it
+	if err := fetchNodeByIDAndUser(ctx, &node, userID, models.AccessOperate); err != nil {
 		return
 	}
 
-	// Stop the node using the service
-	err := services.StopNodeService(&node)
-	if err != nil {
-		ctx.StatusCode(http.StatusInternalServerError)
-		ctx.JSON(iris.Map{"error": err.Error()})
+	if wantsAsync(ctx) {
+		submitNodeOperation(ctx, userID, &node, "node.stop", func(runCtx context.Context, report func(int, string)) (interface{}, error) {
+			report(10, "Stopping the node's server")
+			if err := services.StopRunningNode(runCtx, &node); err != nil {
+				return nil, err
+			}
+			return node, nil
+		})
 		return
 	}
 
-	// Update status in the database
-	if result := config.DB.Model(&node).Updates(map[string]interface{}{
-		"status":       "Stopped",
-		"health_status": "Unhealthy",
-		"last_checked": time.Now(),
-	}); result.Error != nil {
-		ctx.StatusCode(http.StatusInternalServerError)
-		ctx.JSON(iris.Map{"error": "Failed to update node status"})
+	// Stop the node and record it as stopped
+	if err := services.StopRunningNode(ctx.Request().Context(), &node); err != nil {
+		respondError(ctx, err)
 		return
 	}
 
 	ctx.JSON(iris.Map{"message": "Node stopped successfully", "node": node})
 }
 
-// HealthCheck - Perform a health check for a node belonging to the authenticated user
+// HealthCheck - Perform a health check for a node the authenticated user may operate
 func HealthCheck(ctx iris.Context) {
 	// Retrieve user ID from the context
 	userID := ctx.Values().GetUintDefault("user_id", 0)
 
-	nodeID := ctx.Params().GetUintDefault("id", 0)
-
-	// Fetch node by ID and ensure it belongs to the authenticated user
 	var node models.Node
-	if result := config.DB.Where("id = ? AND user_id = ?", nodeID, userID).First(&node); result.Error != nil {
-		ctx.StatusCode(iris.StatusNotFound)
-		ctx.JSON(iris.Map{"error": "Node not found or access denied"})
+
+	// Fetch node by ID and ensure the user may operate it
+	if err := fetchNodeByIDAndUser(ctx, &node, userID, models.AccessOperate); err != nil {
 		return
 	}
 
-	// Perform the health check
-	err := services.PerformHealthCheckConcurrently(&node)
-	if err != nil {
-		ctx.StatusCode(iris.StatusInternalServerError)
-		ctx.JSON(iris.Map{"error": fmt.Sprintf("Health check failed for node %s: %v", node.Name, err)})
+	// Perform the health check; an unhealthy node is a normal result, not a failed request
+	err := services.PerformHealthCheckConcurrently(ctx.Request().Context(), &node)
+	if err != nil && !errors.Is(err, services.ErrNodeUnhealthy) {
+		respondError(ctx, err)
 		return
 	}
 
 	// Respond with the updated health status
-	ctx.JSON(iris.Map{
+	response := iris.Map{
 		"node_id":       node.ID,
 		"health_status": node.HealthStatus,
 		"last_checked":  node.LastChecked,
-	})
+	}
+	if err != nil {
+		response["check_error"] = err.Error()
+	}
+	ctx.JSON(response)
 }
-
@@ -0,0 +1,23 @@
+package controllers
+
+import (
+	"node_management_application/models"
+	"node_management_application/traffic"
+
+	"github.com/kataras/iris/v12"
+)
+
+// GetNodeStats - Get the request statistics of a node the authenticated user may view
+func GetNodeStats(ctx iris.Context) {
+	// Retrieve user ID from the context
+	userID := ctx.Values().GetUintDefault("user_id", 0)
+
+	var node models.Node
+
+	// Fetch node by ID and ensure the user may view it
+	if err := fetchNodeByIDAndUser(ctx, &node, userID, models.AccessView); err != nil {
+		return
+	}
+
+	ctx.JSON(traffic.Get(node.ID))
+}
@@ -0,0 +1,80 @@
+package controllers
+
+import (
+	"node_management_application/config"
+	"node_management_application/models"
+	"node_management_application/services"
+	"node_management_application/utils"
+
+	"github.com/kataras/iris/v12"
+)
+
+// ImportNodes - Create many nodes for the authenticated user from a JSON, YAML or CSV document
+func ImportNodes(ctx iris.Context) {
+	// Retrieve user ID from the context
+	userID := ctx.Values().GetUintDefault("user_id", 0)
+
+	// The format comes from ?format= and falls back to the request content type
+	format, err := services.NormalizeFormat(ctx.URLParamDefault("format", ctx.GetContentTypeRequested()))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	body, err := ctx.GetBody()
+	if err != nil {
+		utils.InvalidRequestResponse(ctx, "Invalid request body")
+		return
+	}
+
+	records, err := services.ParseNodeRecords(format, body)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	if len(records) == 0 {
+		utils.InvalidRequestResponse(ctx, "No nodes to import")
+		return
+	}
+
+	// Import all records in one transaction, or none of them
+	result, err := services.ImportNodes(userID, records, ctx.URLParamBoolDefault("dry_run", false))
+	if err == services.ErrImportRejected {
+		respondErrorWith(ctx, err, iris.Map{"result": result})
+		return
+	}
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(result)
+}
+
+// ExportNodes - Download all nodes of the authenticated user as JSON, YAML or CSV
+func ExportNodes(ctx iris.Context) {
+	// Retrieve user ID from the context
+	userID := ctx.Values().GetUintDefault("user_id", 0)
+
+	format, err := services.NormalizeFormat(ctx.URLParamDefault("format", services.FormatJSON))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	var nodes []models.Node
+	if result := config.DB.Where("user_id = ?", userID).Order("id").Find(&nodes); result.Error != nil {
+		respondError(ctx, result.Error)
+		return
+	}
+
+	data, err := services.EncodeNodeRecords(format, nodes)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.Header("Content-Disposition", "attachment; filename=nodes."+format)
+	ctx.ContentType(services.ContentTypeFor(format))
+	ctx.Write(data)
+}
@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"node_management_application/models"
+	"node_management_application/services"
+	"node_management_application/utils"
+
+	"github.com/kataras/iris/v12"
+	"gorm.io/gorm"
+)
+
+// operationStatuses are the statuses GetOperations can filter on
+var operationStatuses = map[string]bool{
+	models.OperationPending:   true,
+	models.OperationRunning:   true,
+	models.OperationSucceeded: true,
+	models.OperationFailed:    true,
+	models.OperationCancelled: true,
+}
+
+// GetOperations - List the operations of the authenticated user, newest first
+func GetOperations(ctx iris.Context) {
+	// Retrieve user ID from the context
+	userID := ctx.Values().GetUintDefault("user_id", 0)
+
+	filter := services.OperationFilter{
+		NodeID: uint(ctx.URLParamUint64("node_id")),
+		Status: ctx.URLParam("status"),
+		Limit:  ctx.URLParamIntDefault("limit", 0),
+	}
+	if filter.Status != "" && !operationStatuses[filter.Status] {
+		utils.ProblemResponse(ctx, http.StatusBadRequest, utils.CodeInvalidRequest, "Unknown operation status "+strconv.Quote(filter.Status))
+		return
+	}
+
+	operations, err := services.ListUserOperations(ctx.Request().Context(), userID, filter)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(operations)
+}
+
+// GetOperation - Fetch an operation of the authenticated user to follow its progress
+func GetOperation(ctx iris.Context) {
+	// Retrieve user ID from the context
+	userID := ctx.Values().GetUintDefault("user_id", 0)
+
+	operation, err := services.GetUserOperation(ctx.Request().Context(), userID, ctx.Params().GetUintDefault("id", 0))
+	if err != nil {
+		respondOperationError(ctx, err)
+		return
+	}
+
+	ctx.JSON(operation)
+}
+
+// CancelOperation - Ask a pending or running operation to stop. The answer is 202 while the
+// operation winds down and 200 once it is cancelled.
+func CancelOperation(ctx iris.Context) {
+	// Retrieve user ID from the context
+	userID := ctx.Values().GetUintDefault("user_id", 0)
+
+	operation, err := services.CancelOperation(ctx.Request().Context(), userID, ctx.Params().GetUintDefault("id", 0))
+	if err != nil {
+		respondOperationError(ctx, err)
+		return
+	}
+
+	if !operation.Finished() {
+		ctx.StatusCode(http.StatusAccepted)
+	}
+	ctx.JSON(operation)
+}
+
+// respondOperationError reports a missing operation like a missing node
+func respondOperationError(ctx iris.Context, err error) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.NotFoundResponse(ctx, "Operation not found or access denied")
+		return
+	}
+	respondError(ctx, err)
+}
+
+// wantsAsync reports whether the client asked for the action to run in the background,
+// with ?async=true or the RFC 7240 "Prefer: respond-async" header
+func wantsAsync(ctx iris.Context) bool {
+	for _, preference := range strings.Split(ctx.GetHeader("Prefer"), ",") {
+		if strings.EqualFold(strings.TrimSpace(preference), "respond-async") {
+			ctx.Header("Preference-Applied", "respond-async")
+			return true
+		}
+	}
+	return ctx.URLParamBoolDefault("async", false)
+}
+
+// submitNodeOperation runs a node action for the user in the background and answers 202 Accepted
+// with the operation and its location
+func submitNodeOperation(ctx iris.Context, userID uint, node *models.Node, operationType string, run func(context.Context, func(int, string)) (interface{}, error)) {
+	operation, err := services.SubmitOperation(ctx.Request().Context(), services.OperationTask{
+		UserID:   userID,
+		NodeID:   node.ID,
+		Type:     operationType,
+		Run:      run,
+		Describe: describeError,
+	})
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.Header("Location", utils.APIPath(ctx, "/operations/"+strconv.FormatUint(uint64(operation.ID), 10)))
+	ctx.StatusCode(http.StatusAccepted)
+	ctx.JSON(operation)
+}
@@ -0,0 +1,146 @@
+package controllers
+
+import (
+	"net/http"
+
+	"node_management_application/config"
+	"node_management_application/events"
+	"node_management_application/models"
+	"node_management_application/services"
+	"node_management_application/utils"
+
+	"github.com/kataras/iris/v12"
+)
+
+// nodePatchDocument is the editable part of a node that PATCH operates on
+type nodePatchDocument struct {
+	Name     string `json:"name"`
+	IP       string `json:"ip"`
+	Port     int    `json:"port"`
+	Location string `json:"location"`
+}
+
+// userPatchDocument is the editable part of a user that PATCH operates on
+type userPatchDocument struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// PatchNode - Partially update a node with a JSON Merge Patch or JSON Patch document.
+// Like UpdateNode, changing the address of a running node requires ?restart=true.
+func PatchNode(ctx iris.Context) {
+	// Retrieve user ID from the context
+	userID := ctx.Values().GetUintDefault("user_id", 0)
+
+	var node models.Node
+
+	// Fetch node by ID and ensure the user may administer it
+	if err := fetchNodeByIDAndUser(ctx, &node, userID, models.AccessAdmin); err != nil {
+		return
+	}
+
+	// Refuse the patch when the client edited an older version
+	if !utils.IfMatch(ctx, utils.ETag(node.ID, node.Version)) {
+		return
+	}
+
+	body, err := ctx.GetBody()
+	if err != nil {
+		utils.InvalidRequestResponse(ctx, "Invalid request body")
+		return
+	}
+
+	original := nodePatchDocument{Name: node.Name, IP: node.IP, Port: node.Port, Location: node.Location}
+	var patched nodePatchDocument
+	if err := services.ApplyPatch(ctx.GetContentTypeRequested(), original, body, &patched); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	// Save the patched document the way PUT saves a full one
+	err = services.UpdateNode(ctx.Request().Context(), &node, services.NodeUpdate{
+		Name:     patched.Name,
+		IP:       patched.IP,
+		Port:     patched.Port,
+		Location: patched.Location,
+	}, ctx.URLParamBoolDefault("restart", false))
+	if err != nil {
+		respondNodeUpdateError(ctx, &node, err)
+		return
+	}
+
+	ctx.Header("ETag", utils.ETag(node.ID, node.Version))
+	ctx.JSON(node)
+}
+
+// PatchUser - Partially update a user with a JSON Merge Patch or JSON Patch document
+func PatchUser(ctx iris.Context) {
+	id := ctx.Params().GetUintDefault("id", 0)
+	var user models.User
+
+	// Retrieve user ID from the context
+	userID := ctx.Values().GetUintDefault("user_id", 0)
+
+	// Users may only edit themselves unless they are administrators
+	if err := services.CheckUserEditAccess(ctx.Request().Context(), userID, id); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	// Find the user by ID
+	if result := config.DB.First(&user, id); result.Error != nil {
+		utils.NotFoundResponse(ctx, "User not found")
+		return
+	}
+
+	body, err := ctx.GetBody()
+	if err != nil {
+		utils.InvalidRequestResponse(ctx, "Invalid request body")
+		return
+	}
+
+	original := userPatchDocument{Name: user.Name, Email: user.Email}
+	var patched userPatchDocument
+	if err := services.ApplyPatch(ctx.GetContentTypeRequested(), original, body, &patched); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	// Validate only the fields that change
+	columns := map[string]interface{}{}
+	if patched.Name != original.Name {
+		if err := services.ValidateUserName(patched.Name); err != nil {
+			respondError(ctx, err)
+			return
+		}
+		columns["name"] = patched.Name
+	}
+	if patched.Email != original.Email {
+		if err := services.ValidateUserEmail(patched.Email); err != nil {
+			respondError(ctx, err)
+			return
+		}
+
+		// Check if the new email is already registered
+		var existingUser models.User
+		if result := config.DB.Where("email = ? AND id <> ?", patched.Email, user.ID).First(&existingUser); result.RowsAffected > 0 {
+			utils.ProblemResponse(ctx, http.StatusConflict, utils.CodeEmailTaken, "Email is already registered")
+			return
+		}
+		columns["email"] = patched.Email
+	}
+
+	if len(columns) > 0 {
+		if result := config.DB.Model(&user).Updates(columns); result.Error != nil {
+			respondError(ctx, result.Error)
+			return
+		}
+	}
+	user.Name = patched.Name
+	user.Email = patched.Email
+	if len(columns) > 0 {
+		events.PublishUser("updated", &user)
+	}
+
+	ctx.JSON(user)
+}
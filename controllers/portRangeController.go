@@ -0,0 +1,71 @@
+package controllers
+
+import (
+	"node_management_application/config"
+	"node_management_application/models"
+	"node_management_application/services"
+	"node_management_application/utils"
+
+	"github.com/kataras/iris/v12"
+)
+
+// GetPortRanges - List the port ranges available for automatic allocation
+func GetPortRanges(ctx iris.Context) {
+	var ranges []models.PortRange
+	if result := config.DB.Order("ip, start_port").Find(&ranges); result.Error != nil {
+		respondError(ctx, result.Error)
+		return
+	}
+
+	ctx.JSON(ranges)
+}
+
+// CreatePortRange - Add a port range to the allocation pool
+func CreatePortRange(ctx iris.Context) {
+	var portRange models.PortRange
+	if err := ctx.ReadJSON(&portRange); err != nil {
+		utils.InvalidRequestResponse(ctx, "Invalid request body")
+		return
+	}
+
+	if err := services.ValidatePortRange(&portRange); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	portRange.ID = 0
+	if result := config.DB.Create(&portRange); result.Error != nil {
+		respondError(ctx, result.Error)
+		return
+	}
+
+	ctx.JSON(portRange)
+}
+
+// DeletePortRange - Remove a port range; existing reservations are kept
+func DeletePortRange(ctx iris.Context) {
+	id := ctx.Params().GetUintDefault("id", 0)
+
+	result := config.DB.Delete(&models.PortRange{}, id)
+	if result.Error != nil {
+		respondError(ctx, result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
+		utils.NotFoundResponse(ctx, "Port range not found")
+		return
+	}
+
+	ctx.JSON(iris.Map{"message": "Port range deleted successfully"})
+}
+
+// GetPortReservations - List every reserved IP:port and the node holding it
+func GetPortReservations(ctx iris.Context) {
+	var reservations []models.PortReservation
+	if result := config.DB.Order("ip, port").Find(&reservations); result.Error != nil {
+		respondError(ctx, result.Error)
+		return
+	}
+
+	ctx.JSON(reservations)
+}
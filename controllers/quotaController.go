@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"node_management_application/models"
+	"node_management_application/services"
+	"node_management_application/utils"
+
+	"github.com/kataras/iris/v12"
+)
+
+// GetUsage - Show the quotas of the authenticated user and their teams next to what they use
+func GetUsage(ctx iris.Context) {
+	// Retrieve user ID from the context
+	userID := ctx.Values().GetUintDefault("user_id", 0)
+
+	usage, err := services.GetUsage(ctx.Request().Context(), userID)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(usage)
+}
+
+// GetQuotas - List the default quota and every user and team quota
+func GetQuotas(ctx iris.Context) {
+	quotas, err := services.ListQuotas(ctx.Request().Context())
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(quotas)
+}
+
+// SetDefaultQuota - Set the quota of every user and team without a quota of their own
+func SetDefaultQuota(ctx iris.Context) {
+	setQuota(ctx, models.QuotaScopeDefault, 0)
+}
+
+// SetUserQuota - Set the quota of the nodes a user owns outside any team
+func SetUserQuota(ctx iris.Context) {
+	setQuota(ctx, models.QuotaScopeUser, ctx.Params().GetUintDefault("id", 0))
+}
+
+// SetTeamQuota - Set the quota of the nodes a team owns
+func SetTeamQuota(ctx iris.Context) {
+	setQuota(ctx, models.QuotaScopeTeam, ctx.Params().GetUintDefault("id", 0))
+}
+
+// DeleteDefaultQuota - Remove the default quota, leaving everyone without a quota unlimited
+func DeleteDefaultQuota(ctx iris.Context) {
+	deleteQuota(ctx, models.QuotaScopeDefault, 0)
+}
+
+// DeleteUserQuota - Remove the quota of a user, who falls back on the default quota
+func DeleteUserQuota(ctx iris.Context) {
+	deleteQuota(ctx, models.QuotaScopeUser, ctx.Params().GetUintDefault("id", 0))
+}
+
+// DeleteTeamQuota - Remove the quota of a team, which falls back on the default quota
+func DeleteTeamQuota(ctx iris.Context) {
+	deleteQuota(ctx, models.QuotaScopeTeam, ctx.Params().GetUintDefault("id", 0))
+}
+
+// setQuota replaces the quota of a subject with the limits in the request body
+func setQuota(ctx iris.Context, scope string, subjectID uint) {
+	var limits models.Quota
+	if err := ctx.ReadJSON(&limits); err != nil {
+		utils.InvalidRequestResponse(ctx, "Invalid request body")
+		return
+	}
+
+	quota, err := services.SetQuota(ctx.Request().Context(), scope, subjectID, limits)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(quota)
+}
+
+// deleteQuota removes the quota of a subject
+func deleteQuota(ctx iris.Context, scope string, subjectID uint) {
+	if err := services.DeleteQuota(ctx.Request().Context(), scope, subjectID); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(iris.Map{"message": "Quota deleted successfully"})
+}
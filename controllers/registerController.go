@@ -4,7 +4,9 @@ import (
 	"net/http"
 
 	"node_management_application/config"
+	"node_management_application/events"
 	"node_management_application/models"
+	"node_management_application/utils"
 
 	"github.com/kataras/iris/v12"
 	"golang.org/x/crypto/bcrypt"
@@ -19,24 +21,21 @@ func RegisterUser(ctx iris.Context) {
 	}
 
 	if err := ctx.ReadJSON(&user); err != nil {
-		ctx.StatusCode(http.StatusBadRequest)
-		ctx.JSON(iris.Map{"error": "Invalid request body"})
+		utils.InvalidRequestResponse(ctx, "Invalid request body")
 		return
 	}
 
 	// Check if email is already registered
 	var existingUser models.User
 	if result := config.DB.Where("email = ?", user.Email).First(&existingUser); result.RowsAffected > 0 {
-		ctx.StatusCode(http.StatusConflict)
-		ctx.JSON(iris.Map{"error": "Email is already registered"})
+		utils.ProblemResponse(ctx, http.StatusConflict, utils.CodeEmailTaken, "Email is already registered")
 		return
 	}
 
 	// Hash the password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
 	if err != nil {
-		ctx.StatusCode(http.StatusInternalServerError)
-		ctx.JSON(iris.Map{"error": "Failed to hash password"})
+		respondError(ctx, err)
 		return
 	}
 
@@ -47,13 +46,13 @@ func RegisterUser(ctx iris.Context) {
 		Password: string(hashedPassword),
 	}
 	if result := config.DB.Create(&newUser); result.Error != nil {
-		ctx.StatusCode(http.StatusInternalServerError)
-		ctx.JSON(iris.Map{"error": "Failed to create user"})
+		respondError(ctx, result.Error)
 		return
 	}
+	events.PublishUser("registered", &newUser)
 
 	// Respond with the user detail created
 	ctx.JSON(iris.Map{
-		"user":  newUser,
+		"user": newUser,
 	})
 }
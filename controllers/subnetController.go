@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"net/http"
+
+	"node_management_application/config"
+	"node_management_application/models"
+	"node_management_application/services"
+	"node_management_application/utils"
+
+	"github.com/kataras/iris/v12"
+)
+
+// GetSubnets - List the subnets node addresses can be allocated from
+func GetSubnets(ctx iris.Context) {
+	var subnets []models.Subnet
+	if result := config.DB.Order("cidr").Find(&subnets); result.Error != nil {
+		respondError(ctx, result.Error)
+		return
+	}
+
+	ctx.JSON(subnets)
+}
+
+// CreateSubnet - Define a new subnet
+func CreateSubnet(ctx iris.Context) {
+	var subnet models.Subnet
+	if err := ctx.ReadJSON(&subnet); err != nil {
+		utils.InvalidRequestResponse(ctx, "Invalid request body")
+		return
+	}
+
+	if err := services.ValidateSubnet(&subnet); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	// Check if the subnet is already defined
+	var existing models.Subnet
+	if result := config.DB.Where("cidr = ?", subnet.CIDR).First(&existing); result.RowsAffected > 0 {
+		utils.ProblemResponse(ctx, http.StatusConflict, utils.CodeConflict, "Subnet is already defined")
+		return
+	}
+
+	subnet.ID = 0
+	if result := config.DB.Create(&subnet); result.Error != nil {
+		respondError(ctx, result.Error)
+		return
+	}
+
+	ctx.JSON(subnet)
+}
+
+// DeleteSubnet - Remove a subnet definition; nodes keep their addresses
+func DeleteSubnet(ctx iris.Context) {
+	id := ctx.Params().GetUintDefault("id", 0)
+
+	result := config.DB.Delete(&models.Subnet{}, id)
+	if result.Error != nil {
+		respondError(ctx, result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
+		utils.NotFoundResponse(ctx, "Subnet not found")
+		return
+	}
+
+	ctx.JSON(iris.Map{"message": "Subnet deleted successfully"})
+}
+
+// GetSubnetUtilization - Report used and free addresses of one subnet
+func GetSubnetUtilization(ctx iris.Context) {
+	id := ctx.Params().GetUintDefault("id", 0)
+
+	var subnet models.Subnet
+	if result := config.DB.First(&subnet, id); result.Error != nil {
+		utils.NotFoundResponse(ctx, "Subnet not found")
+		return
+	}
+
+	report, err := services.GetSubnetUtilization(subnet)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(report)
+}
+
+// GetSubnetsUtilization - Report utilization of every subnet
+func GetSubnetsUtilization(ctx iris.Context) {
+	var subnets []models.Subnet
+	if result := config.DB.Order("cidr").Find(&subnets); result.Error != nil {
+		respondError(ctx, result.Error)
+		return
+	}
+
+	reports := make([]*services.SubnetUtilization, 0, len(subnets))
+	for _, subnet := range subnets {
+		report, err := services.GetSubnetUtilization(subnet)
+		if err != nil {
+			respondError(ctx, err)
+			return
+		}
+		reports = append(reports, report)
+	}
+
+	ctx.JSON(reports)
+}
@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"node_management_application/services"
+	"node_management_application/utils"
+
+	"github.com/kataras/iris/v12"
+)
+
+// GetTeams - List the teams the authenticated user is a member of
+func GetTeams(ctx iris.Context) {
+	// Retrieve user ID from the context
+	userID := ctx.Values().GetUintDefault("user_id", 0)
+
+	teams, err := services.ListUserTeams(ctx.Request().Context(), userID)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(teams)
+}
+
+// GetTeam - Fetch a team of the authenticated user with its members
+func GetTeam(ctx iris.Context) {
+	// Retrieve user ID from the context
+	userID := ctx.Values().GetUintDefault("user_id", 0)
+
+	team, err := services.GetUserTeam(ctx.Request().Context(), userID, ctx.Params().GetUintDefault("id", 0))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(team)
+}
+
+// CreateTeam - Create a team with the authenticated user as its admin
+func CreateTeam(ctx iris.Context) {
+	// Retrieve user ID from the context
+	userID := ctx.Values().GetUintDefault("user_id", 0)
+
+	var request struct {
+		Name string `json:"name"`
+	}
+	if err := ctx.ReadJSON(&request); err != nil {
+		utils.InvalidRequestResponse(ctx, "Invalid request body")
+		return
+	}
+
+	team, err := services.CreateTeam(ctx.Request().Context(), userID, request.Name)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(team)
+}
+
+// DeleteTeam - Delete a team that owns no nodes; only team admins may
+func DeleteTeam(ctx iris.Context) {
+	// Retrieve user ID from the context
+	userID := ctx.Values().GetUintDefault("user_id", 0)
+
+	if err := services.DeleteTeam(ctx.Request().Context(), userID, ctx.Params().GetUintDefault("id", 0)); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(iris.Map{"message": "Team deleted successfully"})
+}
+
+// SetTeamMember - Add a user to a team or change their role; only team admins may
+func SetTeamMember(ctx iris.Context) {
+	// Retrieve user ID from the context
+	userID := ctx.Values().GetUintDefault("user_id", 0)
+
+	var request struct {
+		Role string `json:"role"`
+	}
+	if err := ctx.ReadJSON(&request); err != nil {
+		utils.InvalidRequestResponse(ctx, "Invalid request body")
+		return
+	}
+
+	member, err := services.SetTeamMember(ctx.Request().Context(), userID,
+		ctx.Params().GetUintDefault("id", 0), ctx.Params().GetUintDefault("userId", 0), request.Role)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(member)
+}
+
+// RemoveTeamMember - Remove a user from a team; team admins may remove anyone, members themselves
+func RemoveTeamMember(ctx iris.Context) {
+	// Retrieve user ID from the context
+	userID := ctx.Values().GetUintDefault("user_id", 0)
+
+	err := services.RemoveTeamMember(ctx.Request().Context(), userID,
+		ctx.Params().GetUintDefault("id", 0), ctx.Params().GetUintDefault("userId", 0))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(iris.Map{"message": "Team member removed successfully"})
+}
@@ -0,0 +1,12 @@
+package controllers
+
+import (
+	"node_management_application/websocket"
+
+	"github.com/kataras/iris/v12"
+)
+
+// GetWebSocketStats - Report WebSocket connection and delivery metrics
+func GetWebSocketStats(ctx iris.Context) {
+	ctx.JSON(websocket.GetStats())
+}
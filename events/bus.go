@@ -0,0 +1,77 @@
+package events
+
+import (
+	"node_management_application/logging"
+
+	"sync"
+	"time"
+)
+
+var logger = logging.For("events")
+
+// subscriberQueueSize bounds the events buffered for a slow subscriber before new ones are dropped
+const subscriberQueueSize = 1024
+
+// Handler consumes events delivered to a subscriber
+type Handler func(Event)
+
+type subscriber struct {
+	name  string
+	queue chan Event
+}
+
+var (
+	subscribers    = make(map[*subscriber]bool)
+	subscribersMux = sync.Mutex{}
+	sequence       uint64
+)
+
+// Subscribe registers a handler under a descriptive name. Each subscriber receives events
+// in publish order on its own goroutine, so a slow subscriber never blocks publishers.
+// The returned function unsubscribes.
+func Subscribe(name string, handler Handler) func() {
+	sub := &subscriber{name: name, queue: make(chan Event, subscriberQueueSize)}
+
+	subscribersMux.Lock()
+	subscribers[sub] = true
+	subscribersMux.Unlock()
+
+	go func() {
+		for event := range sub.queue {
+			handler(event)
+		}
+	}()
+
+	return func() {
+		subscribersMux.Lock()
+		if subscribers[sub] {
+			delete(subscribers, sub)
+			close(sub.queue)
+		}
+		subscribersMux.Unlock()
+	}
+}
+
+// Publish stamps the event with a sequence number, timestamp and schema version and fans it out
+func Publish(event Event) Event {
+	subscribersMux.Lock()
+	defer subscribersMux.Unlock()
+
+	// Numbering under the lock keeps sequence numbers in delivery order
+	sequence++
+	event.ID = sequence
+	event.Version = SchemaVersion
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now().UTC()
+	}
+	appendToLog(event)
+
+	for sub := range subscribers {
+		select {
+		case sub.queue <- event:
+		default:
+			logger.Warn("Event subscriber queue is full; dropping event", "subscriber", sub.name, "event_id", event.ID, "type", event.Type)
+		}
+	}
+	return event
+}
@@ -0,0 +1,171 @@
+package events
+
+import (
+	"encoding/json"
+	"time"
+
+	"node_management_application/models"
+)
+
+// SchemaVersion is bumped whenever the payload of an existing event type changes incompatibly
+const SchemaVersion = 1
+
+// Type identifies the kind of an event
+type Type string
+
+// Event types published by the application
+const (
+	NodeCreated       Type = "node.created"
+	NodeUpdated       Type = "node.updated"
+	NodeDeleted       Type = "node.deleted"
+	NodeStarted       Type = "node.started"
+	NodeStopped       Type = "node.stopped"
+	NodeCrashed       Type = "node.crashed"
+	NodeHealthChanged Type = "node.health_changed"
+	NodeTransferred   Type = "node.transferred"
+	NodeShared        Type = "node.shared"
+	NodeUnshared      Type = "node.unshared"
+	UserChanged       Type = "user.changed"
+	AlertFired        Type = "alert.fired"
+
+	OperationStarted   Type = "operation.started"
+	OperationProgress  Type = "operation.progress"
+	OperationSucceeded Type = "operation.succeeded"
+	OperationFailed    Type = "operation.failed"
+	OperationCancelled Type = "operation.cancelled"
+)
+
+// Event is the envelope shared by every event type
+type Event struct {
+	ID        uint64      `json:"id"` // Sequence number, increasing in publish order
+	Type      Type        `json:"type"`
+	Version   int         `json:"version"`
+	Timestamp time.Time   `json:"timestamp"`
+	UserID    uint        `json:"user_id,omitempty"` // Owner of the node, or the user the event is about
+	NodeID    uint        `json:"node_id,omitempty"`
+	Data      interface{} `json:"data,omitempty"`
+}
+
+// NodeData describes a node in node lifecycle events
+type NodeData struct {
+	Name   string `json:"name"`
+	IP     string `json:"ip"`
+	Port   int    `json:"port"`
+	Status string `json:"status,omitempty"`
+}
+
+// HealthChangedData is the payload of NodeHealthChanged
+type HealthChangedData struct {
+	HealthStatus   string    `json:"health_status"`
+	PreviousStatus string    `json:"previous_status"`
+	LastChecked    time.Time `json:"last_checked"`
+	Error          string    `json:"error,omitempty"`
+}
+
+// CrashedData is the payload of NodeCrashed
+type CrashedData struct {
+	Error string `json:"error"`
+}
+
+// TransferredData is the payload of NodeTransferred
+type TransferredData struct {
+	PreviousUserID uint  `json:"previous_user_id"`
+	UserID         uint  `json:"user_id"`
+	TeamID         *uint `json:"team_id"`
+}
+
+// AccessData is the payload of NodeShared and NodeUnshared
+type AccessData struct {
+	Name  string `json:"name"`
+	Level string `json:"level,omitempty"` // Granted level; empty when the grant was revoked
+}
+
+// UserChangedData is the payload of UserChanged
+type UserChangedData struct {
+	Action string `json:"action"` // "registered", "updated" or "deleted"
+	Name   string `json:"name,omitempty"`
+	Email  string `json:"email,omitempty"`
+}
+
+// AlertData is the payload of AlertFired
+type AlertData struct {
+	Name     string `json:"name"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+// OperationData is the payload of the operation events
+type OperationData struct {
+	OperationID uint            `json:"operation_id"`
+	Operation   string          `json:"operation"`
+	Status      string          `json:"status"`
+	Progress    int             `json:"progress"`
+	Message     string          `json:"message,omitempty"`
+	Result      json.RawMessage `json:"result,omitempty"`
+	ErrorCode   string          `json:"error_code,omitempty"`
+	Error       string          `json:"error,omitempty"`
+}
+
+// PublishNode publishes a node lifecycle event carrying the node's current details
+func PublishNode(eventType Type, node *models.Node) Event {
+	return Publish(Event{
+		Type:   eventType,
+		UserID: node.UserID,
+		NodeID: node.ID,
+		Data: NodeData{
+			Name:   node.Name,
+			IP:     node.IP,
+			Port:   node.Port,
+			Status: node.Status,
+		},
+	})
+}
+
+// PublishTransfer publishes a NodeTransferred event. It is addressed to the previous owner, so
+// they learn about it even without access left; the new owner and team see it as their node's event.
+func PublishTransfer(node *models.Node, previousUserID uint) Event {
+	return Publish(Event{
+		Type:   NodeTransferred,
+		UserID: previousUserID,
+		NodeID: node.ID,
+		Data:   TransferredData{PreviousUserID: previousUserID, UserID: node.UserID, TeamID: node.TeamID},
+	})
+}
+
+// PublishAccess publishes a NodeShared or NodeUnshared event, addressed to the user whose access changed
+func PublishAccess(eventType Type, node *models.Node, userID uint, level string) Event {
+	return Publish(Event{
+		Type:   eventType,
+		UserID: userID,
+		NodeID: node.ID,
+		Data:   AccessData{Name: node.Name, Level: level},
+	})
+}
+
+// PublishUser publishes a UserChanged event
+func PublishUser(action string, user *models.User) Event {
+	return Publish(Event{
+		Type:   UserChanged,
+		UserID: user.ID,
+		Data:   UserChangedData{Action: action, Name: user.Name, Email: user.Email},
+	})
+}
+
+// PublishOperation publishes an operation event carrying the operation's current state
+func PublishOperation(eventType Type, operation *models.Operation) Event {
+	return Publish(Event{
+		Type:   eventType,
+		UserID: operation.UserID,
+		NodeID: operation.NodeID,
+		Data: OperationData{
+			OperationID: operation.ID,
+			Operation:   operation.Type,
+			Status:      operation.Status,
+			Progress:    operation.Progress,
+			Message:     operation.Message,
+			Result:      operation.Result,
+			ErrorCode:   operation.ErrorCode,
+			Error:       operation.Error,
+		},
+	})
+}
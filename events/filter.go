@@ -0,0 +1,63 @@
+package events
+
+// visibleNodes returns the nodes a user owns or shares; it is installed with SetNodeVisibility
+var visibleNodes func(userID uint) map[uint]bool
+
+// SetNodeVisibility installs the lookup of the nodes each user may see, so the events of
+// nodes shared with a user reach them along with their own. Without it users only see
+// events addressed to them.
+func SetNodeVisibility(lookup func(userID uint) map[uint]bool) {
+	visibleNodes = lookup
+}
+
+// nodeGroup returns the group of a node; it is installed with SetNodeGroups
+var nodeGroup func(nodeID uint) string
+
+// SetNodeGroups installs the lookup of node groups, so clients can follow the events of a group
+func SetNodeGroups(lookup func(nodeID uint) string) {
+	nodeGroup = lookup
+}
+
+// NodeGroup returns the group of a node, or "" when it has none or no lookup is installed
+func NodeGroup(nodeID uint) string {
+	if nodeID == 0 || nodeGroup == nil {
+		return ""
+	}
+	return nodeGroup(nodeID)
+}
+
+// Visible reports whether a user may see an event: it is addressed to them or about a node
+// they own or share
+func Visible(event Event, userID uint) bool {
+	if event.UserID == userID {
+		return true
+	}
+	return event.NodeID != 0 && visibleNodes != nil && visibleNodes(userID)[event.NodeID]
+}
+
+// Filter selects the events of one user, optionally narrowed to some nodes and event types
+type Filter struct {
+	UserID uint
+	Nodes  map[uint]bool   // With Groups, the nodes selected; both empty means every node
+	Groups map[string]bool // Node groups whose nodes are selected
+	Types  map[Type]bool   // Empty means every type
+}
+
+// NewFilter returns a filter passing every event of the user
+func NewFilter(userID uint) *Filter {
+	return &Filter{UserID: userID, Nodes: make(map[uint]bool), Groups: make(map[string]bool), Types: make(map[Type]bool)}
+}
+
+// Matches reports whether the event is visible to the user and passes the node and type filters.
+// Events not about a particular node pass the node filter.
+func (f *Filter) Matches(event Event) bool {
+	if !Visible(event, f.UserID) {
+		return false
+	}
+	if (len(f.Nodes) > 0 || len(f.Groups) > 0) && event.NodeID != 0 && !f.Nodes[event.NodeID] {
+		if group := NodeGroup(event.NodeID); group == "" || !f.Groups[group] {
+			return false
+		}
+	}
+	return len(f.Types) == 0 || f.Types[event.Type]
+}
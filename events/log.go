@@ -0,0 +1,204 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"node_management_application/config"
+	"node_management_application/models"
+)
+
+// replayLimit caps the number of events returned by a single replay
+const replayLimit = 5000
+
+// pruneInterval is how many events are appended between trims of the log
+const pruneInterval = 100
+
+// ErrResyncRequired is returned when the requested events were already pruned from the log
+var ErrResyncRequired = errors.New("requested events are no longer available; resync required")
+
+// logEnabled is set once InitLog has connected the bus to the database
+var logEnabled bool
+
+// Published events are persisted by a writer goroutine, so that publishers never wait on the
+// database while holding subscribersMux. Events are queued under that lock, in sequence order.
+var (
+	logMu      sync.Mutex
+	logCond    = sync.NewCond(&logMu)
+	logPending []Event // Events waiting for the writer
+	logWritten uint64  // Sequence number of the last event the writer has handled
+	logWriting bool    // Set once the writer runs
+)
+
+// InitLog continues the sequence from the persisted event log so IDs stay monotonic across
+// restarts, and starts the writer persisting new events
+func InitLog() error {
+	var last uint64
+	if err := config.DB.Model(&models.EventRecord{}).Select("COALESCE(MAX(id), 0)").Scan(&last).Error; err != nil {
+		return err
+	}
+
+	logMu.Lock()
+	logWritten = last
+	logWriting = true
+	logMu.Unlock()
+	go writeLog()
+
+	subscribersMux.Lock()
+	sequence = last
+	logEnabled = true
+	subscribersMux.Unlock()
+	return nil
+}
+
+// FlushLog waits until every event published so far has been persisted
+func FlushLog() {
+	waitForLog(LatestID())
+}
+
+// LatestID returns the sequence number of the last published event
+func LatestID() uint64 {
+	subscribersMux.Lock()
+	defer subscribersMux.Unlock()
+	return sequence
+}
+
+// Since returns the events visible to a user that were published after the given sequence number, oldest first.
+// ErrResyncRequired means some of them were pruned and the client must reload its state.
+func Since(since uint64, userID uint) ([]Event, error) {
+	latest := LatestID()
+	if since > latest {
+		return nil, ErrResyncRequired
+	}
+	if since == latest {
+		return nil, nil
+	}
+
+	// Events still queued for the writer would be missing from the replay
+	waitForLog(latest)
+
+	var oldest uint64
+	if err := config.DB.Model(&models.EventRecord{}).Select("COALESCE(MIN(id), 0)").Scan(&oldest).Error; err != nil {
+		return nil, err
+	}
+	if oldest == 0 || since+1 < oldest {
+		return nil, ErrResyncRequired
+	}
+
+	// Besides the events addressed to the user, replay those of the nodes they may see
+	query := config.DB.Where("id > ?", since)
+	var shared []uint
+	if visibleNodes != nil {
+		for id := range visibleNodes(userID) {
+			shared = append(shared, id)
+		}
+	}
+	if len(shared) > 0 {
+		query = query.Where("user_id = ? OR node_id IN ?", userID, shared)
+	} else {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	var records []models.EventRecord
+	if err := query.Order("id").Limit(replayLimit + 1).Find(&records).Error; err != nil {
+		return nil, err
+	}
+	if len(records) > replayLimit {
+		return nil, ErrResyncRequired
+	}
+
+	replayed := make([]Event, 0, len(records))
+	for _, record := range records {
+		event := Event{
+			ID:        record.ID,
+			Type:      Type(record.Type),
+			Version:   record.Version,
+			Timestamp: record.Timestamp,
+			UserID:    record.UserID,
+			NodeID:    record.NodeID,
+		}
+		if record.Data != "" {
+			event.Data = json.RawMessage(record.Data)
+		}
+		replayed = append(replayed, event)
+	}
+	return replayed, nil
+}
+
+// appendToLog queues an event for the writer; it runs under subscribersMux
+func appendToLog(event Event) {
+	if !logEnabled {
+		return
+	}
+
+	logMu.Lock()
+	logPending = append(logPending, event)
+	logMu.Unlock()
+	logCond.Broadcast()
+}
+
+// waitForLog blocks until the writer has handled the events up to id
+func waitForLog(id uint64) {
+	logMu.Lock()
+	defer logMu.Unlock()
+	for logWriting && logWritten < id {
+		logCond.Wait()
+	}
+}
+
+// writeLog persists queued events in batches and periodically trims the log
+func writeLog() {
+	for {
+		logMu.Lock()
+		for len(logPending) == 0 {
+			logCond.Wait()
+		}
+		batch := logPending
+		logPending = nil
+		logMu.Unlock()
+
+		persistEvents(batch)
+
+		// Failed events count as handled too, so replays never wait on them
+		logMu.Lock()
+		logWritten = batch[len(batch)-1].ID
+		logMu.Unlock()
+		logCond.Broadcast()
+	}
+}
+
+// persistEvents saves a batch of events, trimming the log whenever the batch crosses a multiple of pruneInterval
+func persistEvents(batch []Event) {
+	records := make([]models.EventRecord, 0, len(batch))
+	for _, event := range batch {
+		data, err := json.Marshal(event.Data)
+		if err != nil {
+			logger.Error("Failed to encode event", "event_id", event.ID, "error", err)
+			continue
+		}
+		records = append(records, models.EventRecord{
+			ID:        event.ID,
+			Type:      string(event.Type),
+			Version:   event.Version,
+			Timestamp: event.Timestamp,
+			UserID:    event.UserID,
+			NodeID:    event.NodeID,
+			Data:      string(data),
+		})
+	}
+	if len(records) > 0 {
+		if err := config.DB.CreateInBatches(records, pruneInterval).Error; err != nil {
+			logger.Error("Failed to persist events", "first_event_id", batch[0].ID, "count", len(batch), "error", err)
+			return
+		}
+	}
+
+	last := batch[len(batch)-1].ID
+	if last/pruneInterval != (batch[0].ID-1)/pruneInterval && last > uint64(config.EventLogRetention) {
+		cutoff := last - uint64(config.EventLogRetention)
+		if err := config.DB.Where("id <= ?", cutoff).Delete(&models.EventRecord{}).Error; err != nil {
+			logger.Error("Failed to prune event log", "error", err)
+		}
+	}
+}
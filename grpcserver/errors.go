@@ -0,0 +1,50 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+
+	"node_management_application/events"
+	"node_management_application/services"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+)
+
+// serviceErrors maps the errors the services return on purpose to status codes; see controllers/errors.go
+var serviceErrors = []struct {
+	target error
+	code   codes.Code
+}{
+	{services.ErrPortConflict, codes.AlreadyExists},
+	{services.ErrNoFreePort, codes.ResourceExhausted},
+	{services.ErrPortInUse, codes.FailedPrecondition},
+	{services.ErrNodeNotRunning, codes.FailedPrecondition},
+	{services.ErrNodeRunning, codes.FailedPrecondition},
+	{services.ErrSubnetExhausted, codes.ResourceExhausted},
+	{services.ErrSubnetNotFound, codes.NotFound},
+	{services.ErrVersionConflict, codes.Aborted},
+	{services.ErrAccessDenied, codes.PermissionDenied},
+	{services.ErrQuotaExceeded, codes.ResourceExhausted},
+	{services.ErrQuotaNotFound, codes.NotFound},
+	{events.ErrResyncRequired, codes.OutOfRange},
+	{gorm.ErrRecordNotFound, codes.NotFound},
+}
+
+// toStatus converts a service error to a gRPC status. Unknown errors are logged and hidden.
+func toStatus(ctx context.Context, err error) error {
+	var validation *services.ValidationError
+	if errors.As(err, &validation) {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	for _, mapping := range serviceErrors {
+		if errors.Is(err, mapping.target) {
+			return status.Error(mapping.code, err.Error())
+		}
+	}
+
+	logger.ErrorContext(ctx, "Unhandled error", "error", err)
+	return status.Error(codes.Internal, "internal error")
+}
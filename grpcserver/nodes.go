@@ -0,0 +1,257 @@
+package grpcserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"node_management_application/events"
+	"node_management_application/models"
+	pb "node_management_application/proto/nodemanagement/v1"
+	"node_management_application/services"
+
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// watchQueueSize is the number of events buffered per WatchEvents call before new ones are dropped
+const watchQueueSize = 256
+
+// nodeServer implements NodeService on top of the same services as the REST controllers
+type nodeServer struct {
+	pb.UnimplementedNodeServiceServer
+}
+
+func (s *nodeServer) ListNodes(ctx context.Context, req *pb.ListNodesRequest) (*pb.ListNodesResponse, error) {
+	nodes, err := services.ListUserNodes(ctx, userIDFrom(ctx))
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+
+	resp := &pb.ListNodesResponse{Nodes: make([]*pb.Node, len(nodes))}
+	for i := range nodes {
+		resp.Nodes[i] = nodeToProto(&nodes[i])
+	}
+	return resp, nil
+}
+
+func (s *nodeServer) GetNode(ctx context.Context, req *pb.GetNodeRequest) (*pb.Node, error) {
+	node, err := services.GetUserNode(ctx, userIDFrom(ctx), uint(req.GetId()), models.AccessView)
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return nodeToProto(node), nil
+}
+
+func (s *nodeServer) CreateNode(ctx context.Context, req *pb.CreateNodeRequest) (*pb.Node, error) {
+	node, err := services.CreateNode(ctx, userIDFrom(ctx), services.NodeSpec{
+		Name:     req.GetName(),
+		IP:       req.GetIp(),
+		Port:     int(req.GetPort()),
+		Location: req.GetLocation(),
+		AutoPort: req.GetAutoPort(),
+		SubnetID: uint(req.GetSubnetId()),
+	})
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return nodeToProto(node), nil
+}
+
+func (s *nodeServer) UpdateNode(ctx context.Context, req *pb.UpdateNodeRequest) (*pb.Node, error) {
+	node, err := services.GetUserNode(ctx, userIDFrom(ctx), uint(req.GetId()), models.AccessAdmin)
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+
+	// The update is conditional on the version the client saw, like If-Match
+	if req.GetExpectedVersion() != 0 {
+		node.Version = uint(req.GetExpectedVersion())
+	}
+
+	err = services.UpdateNode(ctx, node, services.NodeUpdate{
+		Name:     req.GetName(),
+		IP:       req.GetIp(),
+		Port:     int(req.GetPort()),
+		Location: req.GetLocation(),
+	}, false)
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return nodeToProto(node), nil
+}
+
+func (s *nodeServer) DeleteNode(ctx context.Context, req *pb.DeleteNodeRequest) (*pb.DeleteNodeResponse, error) {
+	node, err := services.GetUserNode(ctx, userIDFrom(ctx), uint(req.GetId()), models.AccessAdmin)
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+
+	if req.GetExpectedVersion() != 0 {
+		node.Version = uint(req.GetExpectedVersion())
+	}
+
+	if err := services.DeleteNode(ctx, node); err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return &pb.DeleteNodeResponse{}, nil
+}
+
+func (s *nodeServer) StartNode(ctx context.Context, req *pb.StartNodeRequest) (*pb.Node, error) {
+	node, err := services.GetUserNode(ctx, userIDFrom(ctx), uint(req.GetId()), models.AccessOperate)
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+
+	if err := services.StartNode(ctx, node); err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return nodeToProto(node), nil
+}
+
+func (s *nodeServer) StopNode(ctx context.Context, req *pb.StopNodeRequest) (*pb.Node, error) {
+	node, err := services.GetUserNode(ctx, userIDFrom(ctx), uint(req.GetId()), models.AccessOperate)
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+
+	if err := services.StopRunningNode(ctx, node); err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return nodeToProto(node), nil
+}
+
+func (s *nodeServer) CheckNodeHealth(ctx context.Context, req *pb.CheckNodeHealthRequest) (*pb.CheckNodeHealthResponse, error) {
+	node, err := services.GetUserNode(ctx, userIDFrom(ctx), uint(req.GetId()), models.AccessOperate)
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+
+	// An unhealthy node is a normal result, not a failed call
+	err = services.PerformHealthCheckConcurrently(ctx, node)
+	if err != nil && !errors.Is(err, services.ErrNodeUnhealthy) {
+		return nil, toStatus(ctx, err)
+	}
+
+	resp := &pb.CheckNodeHealthResponse{
+		NodeId:       uint64(node.ID),
+		HealthStatus: node.HealthStatus,
+		LastChecked:  timestamppb.New(node.LastChecked),
+	}
+	if err != nil {
+		resp.CheckError = err.Error()
+	}
+	return resp, nil
+}
+
+func (s *nodeServer) WatchEvents(req *pb.WatchEventsRequest, stream pb.NodeService_WatchEventsServer) error {
+	ctx := stream.Context()
+	filter := events.NewFilter(userIDFrom(ctx))
+	for _, id := range req.GetNodeIds() {
+		filter.Nodes[uint(id)] = true
+	}
+	for _, eventType := range req.GetTypes() {
+		filter.Types[events.Type(eventType)] = true
+	}
+
+	// Subscribe before replaying so no event falls between the replay and the live stream
+	live := make(chan events.Event, watchQueueSize)
+	unsubscribe := events.Subscribe("grpc", func(event events.Event) {
+		if !filter.Matches(event) {
+			return
+		}
+		select {
+		case live <- event:
+		default:
+			logger.Warn("Event watch queue is full; dropping event", "user_id", filter.UserID, "event_id", event.ID)
+		}
+	})
+	defer unsubscribe()
+
+	var last uint64
+	if req.ResumeAfter != nil {
+		last = req.GetResumeAfter()
+		missed, err := events.Since(last, filter.UserID)
+		if err != nil {
+			return toStatus(ctx, err)
+		}
+		for _, event := range missed {
+			if filter.Matches(event) {
+				if err := sendEvent(stream, event); err != nil {
+					return err
+				}
+			}
+			last = event.ID
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event := <-live:
+			// Skip live events that were already part of the replay
+			if event.ID <= last {
+				continue
+			}
+			last = event.ID
+			if err := sendEvent(stream, event); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// sendEvent converts an event and sends it on the stream
+func sendEvent(stream pb.NodeService_WatchEventsServer, event events.Event) error {
+	message, err := eventToProto(event)
+	if err != nil {
+		logger.Error("Failed to encode event", "event_id", event.ID, "error", err)
+		return nil
+	}
+	return stream.Send(message)
+}
+
+// nodeToProto converts a node to its protobuf message
+func nodeToProto(node *models.Node) *pb.Node {
+	return &pb.Node{
+		Id:           uint64(node.ID),
+		UserId:       uint64(node.UserID),
+		Name:         node.Name,
+		Ip:           node.IP,
+		Port:         int32(node.Port),
+		Location:     node.Location,
+		Status:       node.Status,
+		HealthStatus: node.HealthStatus,
+		LastChecked:  timestamppb.New(node.LastChecked),
+		Version:      uint64(node.Version),
+	}
+}
+
+// eventToProto converts an event; its payload is carried as the same JSON object the REST streams send
+func eventToProto(event events.Event) (*pb.Event, error) {
+	message := &pb.Event{
+		Id:        event.ID,
+		Type:      string(event.Type),
+		Version:   int32(event.Version),
+		Timestamp: timestamppb.New(event.Timestamp),
+		UserId:    uint64(event.UserID),
+		NodeId:    uint64(event.NodeID),
+	}
+	if event.Data == nil {
+		return message, nil
+	}
+
+	encoded, err := json.Marshal(event.Data)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(encoded, &fields); err != nil {
+		return nil, err
+	}
+	if message.Data, err = structpb.NewStruct(fields); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
@@ -0,0 +1,64 @@
+// Package grpcserver serves the node and user management API over gRPC, next to the REST API.
+package grpcserver
+
+//go:generate protoc -I ../proto --go_out=../proto --go_opt=paths=source_relative --go-grpc_out=../proto --go-grpc_opt=paths=source_relative nodemanagement/v1/nodemanagement.proto
+
+import (
+	"net"
+
+	"node_management_application/logging"
+	pb "node_management_application/proto/nodemanagement/v1"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+var logger = logging.For("grpc")
+
+// Server is the running gRPC server
+type Server struct {
+	server *grpc.Server
+	health *health.Server
+}
+
+// Start listens on the address and serves the API, the gRPC health protocol and reflection
+func Start(address string) (*Server, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(logUnary, authenticateUnary),
+		grpc.ChainStreamInterceptor(logStream, authenticateStream),
+	)
+	pb.RegisterNodeServiceServer(server, &nodeServer{})
+	pb.RegisterUserServiceServer(server, &userServer{})
+
+	// Report every service as serving; the empty name stands for the server as a whole
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(server, healthServer)
+	for _, name := range []string{"", pb.NodeService_ServiceDesc.ServiceName, pb.UserService_ServiceDesc.ServiceName} {
+		healthServer.SetServingStatus(name, healthpb.HealthCheckResponse_SERVING)
+	}
+
+	reflection.Register(server)
+
+	go func() {
+		logger.Info("Starting gRPC server", "address", listener.Addr().String())
+		if err := server.Serve(listener); err != nil {
+			logger.Error("gRPC server stopped with error", "error", err)
+		}
+	}()
+
+	return &Server{server: server, health: healthServer}, nil
+}
+
+// Stop reports the server as not serving and closes every connection. Running calls are
+// cancelled rather than awaited because event streams never finish on their own.
+func (s *Server) Stop() {
+	s.health.Shutdown()
+	s.server.Stop()
+}
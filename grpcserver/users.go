@@ -0,0 +1,45 @@
+package grpcserver
+
+import (
+	"context"
+
+	"node_management_application/config"
+	"node_management_application/models"
+	pb "node_management_application/proto/nodemanagement/v1"
+)
+
+// userServer implements UserService
+type userServer struct {
+	pb.UnimplementedUserServiceServer
+}
+
+func (s *userServer) GetProfile(ctx context.Context, req *pb.GetProfileRequest) (*pb.User, error) {
+	var user models.User
+	if err := config.DB.WithContext(ctx).First(&user, userIDFrom(ctx)).Error; err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return userToProto(&user), nil
+}
+
+func (s *userServer) ListUsers(ctx context.Context, req *pb.ListUsersRequest) (*pb.ListUsersResponse, error) {
+	var users []models.User
+	if err := config.DB.WithContext(ctx).Find(&users).Error; err != nil {
+		return nil, toStatus(ctx, err)
+	}
+
+	resp := &pb.ListUsersResponse{Users: make([]*pb.User, len(users))}
+	for i := range users {
+		resp.Users[i] = userToProto(&users[i])
+	}
+	return resp, nil
+}
+
+// userToProto converts a user to its protobuf message, leaving out the password hash
+func userToProto(user *models.User) *pb.User {
+	return &pb.User{
+		Id:      uint64(user.ID),
+		Name:    user.Name,
+		Email:   user.Email,
+		IsAdmin: user.IsAdmin,
+	}
+}
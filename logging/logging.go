@@ -0,0 +1,192 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Output formats accepted by Init
+const (
+	FormatJSON   = "json"
+	FormatLogfmt = "logfmt"
+)
+
+var (
+	// root is the handler every component logger writes through; Init swaps it
+	root atomic.Pointer[slog.Handler]
+
+	// baseLevel applies to components without their own level
+	baseLevel = new(slog.LevelVar)
+
+	componentLevels   = make(map[string]*slog.LevelVar)
+	componentLevelsMu sync.RWMutex
+)
+
+func init() {
+	root.Store(newRootHandler(os.Stderr, FormatLogfmt))
+}
+
+// Init selects the output format and base level and routes the standard log package through it
+func Init(format, level string) error {
+	if format != FormatJSON && format != FormatLogfmt {
+		return fmt.Errorf("unknown log format %q", format)
+	}
+	if err := SetLevel("", level); err != nil {
+		return err
+	}
+
+	root.Store(newRootHandler(os.Stderr, format))
+	slog.SetDefault(For("app"))
+	return nil
+}
+
+// newRootHandler writes every record it is given; levels are filtered by the component handlers
+func newRootHandler(w io.Writer, format string) *slog.Handler {
+	options := &slog.HandlerOptions{Level: slog.LevelDebug - 4}
+	var handler slog.Handler = slog.NewTextHandler(w, options)
+	if format == FormatJSON {
+		handler = slog.NewJSONHandler(w, options)
+	}
+	return &handler
+}
+
+// For returns the logger of a component. Its records carry a component attribute
+// and are filtered by the component's level, falling back to the base level.
+func For(component string) *slog.Logger {
+	return slog.New(&componentHandler{component: component})
+}
+
+// ParseLevel accepts debug, info, warn and error
+func ParseLevel(value string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(value)); err != nil {
+		return 0, fmt.Errorf("unknown log level %q", value)
+	}
+	return level, nil
+}
+
+// SetLevel changes the level of a component at runtime; an empty component sets the base level.
+// Setting a component's level to "default" makes it follow the base level again.
+func SetLevel(component, value string) error {
+	if component != "" && strings.EqualFold(value, "default") {
+		componentLevelsMu.Lock()
+		delete(componentLevels, component)
+		componentLevelsMu.Unlock()
+		return nil
+	}
+
+	level, err := ParseLevel(value)
+	if err != nil {
+		return err
+	}
+	if component == "" {
+		baseLevel.Set(level)
+		return nil
+	}
+
+	componentLevelsMu.Lock()
+	defer componentLevelsMu.Unlock()
+	if _, ok := componentLevels[component]; !ok {
+		componentLevels[component] = new(slog.LevelVar)
+	}
+	componentLevels[component].Set(level)
+	return nil
+}
+
+// Levels reports the base level and the components that override it
+type Levels struct {
+	Level      string            `json:"level"`
+	Components map[string]string `json:"components"`
+}
+
+// CurrentLevels returns the levels in effect
+func CurrentLevels() Levels {
+	componentLevelsMu.RLock()
+	defer componentLevelsMu.RUnlock()
+
+	levels := Levels{Level: strings.ToLower(baseLevel.Level().String()), Components: make(map[string]string, len(componentLevels))}
+	names := make([]string, 0, len(componentLevels))
+	for name := range componentLevels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		levels.Components[name] = strings.ToLower(componentLevels[name].Level().String())
+	}
+	return levels
+}
+
+// levelOf returns the level in effect for a component
+func levelOf(component string) slog.Level {
+	componentLevelsMu.RLock()
+	level, ok := componentLevels[component]
+	componentLevelsMu.RUnlock()
+	if ok {
+		return level.Level()
+	}
+	return baseLevel.Level()
+}
+
+type requestIDKey struct{}
+
+// WithRequestID stores the request ID in the context so loggers called with it include the ID
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID stored in the context, if any
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// componentHandler filters by component level and adds the component, request ID and trace ID.
+// Attributes and groups are replayed on the current root handler so Init can swap it at any time.
+type componentHandler struct {
+	component string
+	wrappers  []func(slog.Handler) slog.Handler
+}
+
+func (h *componentHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= levelOf(h.component)
+}
+
+func (h *componentHandler) Handle(ctx context.Context, record slog.Record) error {
+	handler := (*root.Load()).WithAttrs([]slog.Attr{slog.String("component", h.component)})
+	for _, wrap := range h.wrappers {
+		handler = wrap(handler)
+	}
+
+	if ctx != nil {
+		if id := RequestID(ctx); id != "" {
+			record.AddAttrs(slog.String("request_id", id))
+		}
+		if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+			record.AddAttrs(slog.String("trace_id", span.TraceID().String()))
+		}
+	}
+	return handler.Handle(ctx, record)
+}
+
+func (h *componentHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.with(func(handler slog.Handler) slog.Handler { return handler.WithAttrs(attrs) })
+}
+
+func (h *componentHandler) WithGroup(name string) slog.Handler {
+	return h.with(func(handler slog.Handler) slog.Handler { return handler.WithGroup(name) })
+}
+
+func (h *componentHandler) with(wrap func(slog.Handler) slog.Handler) slog.Handler {
+	wrappers := make([]func(slog.Handler) slog.Handler, len(h.wrappers), len(h.wrappers)+1)
+	copy(wrappers, h.wrappers)
+	return &componentHandler{component: h.component, wrappers: append(wrappers, wrap)}
+}
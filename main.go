@@ -1,20 +1,46 @@
 package main
 
 import (
-	"log"
+	"context"
+	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
+	"node_management_application/audit"
 	"node_management_application/config"
+	"node_management_application/events"
+	"node_management_application/grpcserver"
+	"node_management_application/logging"
+	"node_management_application/metrics"
+	"node_management_application/middlewares"
 	"node_management_application/models"
+	"node_management_application/openapi"
 	"node_management_application/routes"
 	"node_management_application/services"
+	"node_management_application/tracing"
+	"node_management_application/traffic"
+	"node_management_application/webhooks"
+	"node_management_application/websocket"
 
 	"github.com/kataras/iris/v12"
 )
 
+var logger = logging.For("main")
+
 func main() {
+	// Run the apply subcommand against a running server instead of starting one
+	if len(os.Args) > 1 && os.Args[1] == "apply" {
+		os.Exit(runApply(os.Args[2:]))
+	}
+
+	// Configure structured logging before anything else logs
+	if err := logging.Init(config.LogFormat, config.LogLevel); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid logging configuration: %v\n", err)
+		os.Exit(1)
+	}
+
 	// Initialize the application
 	initialize()
 
@@ -24,91 +50,202 @@ func main() {
 	// Start the Iris web server
 	app := startServer()
 
+	// Start the gRPC server next to it
+	grpcServer := startGRPCServer()
+
 	// Handle graceful shutdown
-	handleShutdown(app, healthMonitorShutdown)
+	handleShutdown(app, grpcServer, healthMonitorShutdown)
 }
 
 // initialize sets up the database and performs migrations
 func initialize() {
-	log.Println("Initializing application...")
+	logger.Info("Initializing application")
+
+	// Configure the trace exporter before anything is instrumented
+	if err := tracing.Init(); err != nil {
+		fatal("Failed to initialize tracing", err)
+	}
 
 	// Connect to the database
-	log.Println("Connecting to the database...")
+	logger.Info("Connecting to the database")
 	config.ConnectDatabase()
+	if err := tracing.RegisterGORMCallbacks(config.DB); err != nil {
+		fatal("Failed to instrument database", err)
+	}
 
 	// Run database migrations
-	log.Println("Running database migrations...")
-	if err := config.DB.AutoMigrate(&models.User{}, &models.Node{}); err != nil {
-		log.Fatalf("Failed to migrate database schema: %v", err)
+	logger.Info("Running database migrations")
+	if err := config.DB.AutoMigrate(models.All()...); err != nil {
+		fatal("Failed to migrate database schema", err)
+	}
+
+	// Promote the configured administrators
+	if len(config.AdminEmails) > 0 {
+		if err := config.DB.Model(&models.User{}).Where("email IN ?", config.AdminEmails).Update("is_admin", true).Error; err != nil {
+			logger.Error("Failed to promote administrators", "error", err)
+		}
+	}
+
+	// Reserve the ports of nodes created before reservations existed
+	services.SyncPortReservations()
+
+	// Operations left running by the previous process will never finish
+	if err := services.RecoverOperations(); err != nil {
+		logger.Error("Failed to recover unfinished operations", "error", err)
+	}
+
+	// Continue event numbering from the persisted event log
+	if err := events.InitLog(); err != nil {
+		fatal("Failed to load event log", err)
 	}
+
+	// Deliver the events of shared nodes to every user they are shared with
+	events.SetNodeVisibility(services.VisibleNodes)
+
+	// Let clients follow the events of node groups
+	events.SetNodeGroups(services.NodeGroup)
+
+	// Connect event consumers to the event bus
+	logger.Info("Subscribing event consumers")
+	events.Subscribe("websocket", websocket.BroadcastEvent)
+	events.Subscribe("traffic", traffic.HandleEvent)
+	webhooks.Subscribe()
+	if err := audit.Init(); err != nil {
+		fatal("Failed to open audit log", err)
+	}
+
+	// Register Prometheus collectors
+	metrics.Init()
 }
 
-// startHealthMonitoring starts the health monitoring service in a goroutine
+// startHealthMonitoring starts the health monitoring service and the node scheduler in goroutines
 func startHealthMonitoring() chan struct{} {
-	log.Println("Starting health monitoring service...")
+	logger.Info("Starting health monitoring service")
 	shutdown := make(chan struct{})
 	go services.MonitorNodeHealth(shutdown)
+	go services.RunNodeSchedules(shutdown)
 	return shutdown
 }
 
 // startServer initializes and starts the Iris web server
 func startServer() *iris.Application {
-	log.Println("Starting web server...")
+	logger.Info("Starting web server")
 
 	app := iris.New()
 
+	// Register metrics first so every route below is instrumented
+	logger.Info("Registering metrics route")
+	routes.RegisterMetricsRoute(app)
+
+	// Trace every request, continuing the caller's trace if any
+	app.UseGlobal(tracing.Middleware)
+
+	// Tag every request with an ID that service logs carry along
+	app.UseGlobal(middlewares.RequestID)
+
 	// Register routes
-	log.Println("Registering routes...")
+	logger.Info("Registering routes")
 	routes.RegisterRoutes(app)
 
 	// Register WebSocket route
-	log.Println("Registering WebSocket route...")
+	logger.Info("Registering WebSocket route")
 	routes.RegisterWebSocketRoute(app)
-	
+
+	// Register Server-Sent Events route
+	logger.Info("Registering event stream route")
+	routes.RegisterEventStreamRoute(app)
+
+	// Serve the API description, which should cover exactly the routes registered above.
+	// The tests hold the two together; a mismatch here only makes the document less useful.
+	logger.Info("Registering API documentation routes")
+	routes.RegisterDocsRoutes(app)
+	if err := openapi.Verify(app.GetRoutes()); err != nil {
+		logger.Error("API documentation does not match the routes", "error", err)
+	}
+
 	// Start the server in a goroutine
 	go func() {
 		if err := app.Listen(":8080"); err != nil {
-			log.Fatalf("Failed to start server: %v", err)
+			fatal("Failed to start server", err)
 		}
 	}()
 
 	return app
 }
 
+// startGRPCServer starts the gRPC API unless it is disabled
+func startGRPCServer() *grpcserver.Server {
+	if config.GRPCAddress == "off" {
+		return nil
+	}
+
+	logger.Info("Starting gRPC server")
+	server, err := grpcserver.Start(config.GRPCAddress)
+	if err != nil {
+		fatal("Failed to start gRPC server", err)
+	}
+	return server
+}
+
 // handleShutdown manages the cleanup and graceful shutdown of the application
-func handleShutdown(app *iris.Application, healthMonitorShutdown chan struct{}) {
+func handleShutdown(app *iris.Application, grpcServer *grpcserver.Server, healthMonitorShutdown chan struct{}) {
 	// Set up signal channel to catch OS interrupts
 	shutdown := make(chan os.Signal, 1)
 	signal.Notify(shutdown, syscall.SIGINT, syscall.SIGTERM)
 
 	// Wait for a termination signal
 	<-shutdown
-	log.Println("Shutting down application...")
+	logger.Info("Shutting down application")
 
-	// Stop health monitoring service
-	log.Println("Stopping health monitoring service...")
+	// Stop health monitoring service and node scheduler
+	logger.Info("Stopping health monitoring service")
 	close(healthMonitorShutdown)
 
+	// Stop taking gRPC calls before the nodes they act on go away
+	if grpcServer != nil {
+		logger.Info("Stopping gRPC server")
+		grpcServer.Stop()
+	}
+
+	// Let background operations record how far they got
+	logger.Info("Cancelling running operations")
+	services.CancelOperations(10 * time.Second)
+
 	// Stop all node servers
-	log.Println("Stopping all node servers...")
+	logger.Info("Stopping all node servers")
 	services.StopAllNodes()
 
+	// Save node traffic totals and queued events before the database goes away
+	traffic.FlushAll()
+	events.FlushLog()
+
 	// Close database connections
-	log.Println("Closing database connections...")
+	logger.Info("Closing database connections")
 	sqlDB, err := config.DB.DB() // Access the underlying *sql.DB
 	if err != nil {
-		log.Printf("Failed to access database connection: %v", err)
+		logger.Error("Failed to access database connection", "error", err)
 	} else {
 		if err := sqlDB.Close(); err != nil {
-			log.Printf("Failed to close database connection: %v", err)
+			logger.Error("Failed to close database connection", "error", err)
 		}
 	}
 
 	// Shutdown the web server
-	log.Println("Shutting down web server...")
+	logger.Info("Shutting down web server")
 	if err := app.Shutdown(nil); err != nil {
-		log.Printf("Failed to shutdown web server: %v", err)
+		logger.Error("Failed to shutdown web server", "error", err)
+	}
+
+	// Flush the remaining spans
+	if err := tracing.Shutdown(context.Background()); err != nil {
+		logger.Error("Failed to flush traces", "error", err)
 	}
 
-	log.Println("Application shutdown completed.")
+	logger.Info("Application shutdown completed")
+}
+
+// fatal logs an error that prevents the application from running and exits
+func fatal(msg string, err error) {
+	logger.Error(msg, "error", err)
+	os.Exit(1)
 }
@@ -0,0 +1,251 @@
+package metrics
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"node_management_application/config"
+	"node_management_application/events"
+	"node_management_application/logging"
+	"node_management_application/traffic"
+	"node_management_application/websocket"
+
+	"github.com/kataras/iris/v12"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+var logger = logging.For("metrics")
+
+// namespace prefixes every metric exported by the application
+const namespace = "node_management"
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests handled, by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency, by method and route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	healthCheckDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "health_check_duration_seconds",
+		Help:      "Duration of node health checks, by node.",
+		Buckets:   []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 3},
+	}, []string{"node_id"})
+
+	healthChecks = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "health_checks_total",
+		Help:      "Node health checks performed, by node and result.",
+	}, []string{"node_id", "result"})
+
+	nodeLifecycle = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "node_lifecycle_events_total",
+		Help:      "Node starts, stops and crashes, by node and event.",
+	}, []string{"node_id", "event"})
+
+	nodeRestarts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "node_restarts_total",
+		Help:      "Node starts that followed an earlier stop or crash of the same node, by node.",
+	}, []string{"node_id"})
+)
+
+// stoppedNodes remembers nodes that stopped or crashed so the next start counts as a restart
+var stoppedNodes sync.Map
+
+// Init registers every collector and subscribes to the event bus
+func Init() {
+	prometheus.MustRegister(
+		httpRequests,
+		httpDuration,
+		healthCheckDuration,
+		healthChecks,
+		nodeLifecycle,
+		nodeRestarts,
+		&nodeCollector{},
+		&webSocketCollector{},
+		&trafficCollector{},
+	)
+
+	if sqlDB, err := config.DB.DB(); err == nil {
+		prometheus.MustRegister(collectors.NewDBStatsCollector(sqlDB, "node_management"))
+	} else {
+		logger.Warn("Database pool metrics unavailable", "error", err)
+	}
+
+	events.Subscribe("metrics", recordEvent)
+}
+
+// Middleware records the count and latency of every request, labelled by the matched route template
+func Middleware(ctx iris.Context) {
+	start := time.Now()
+	ctx.Next()
+
+	route := "unmatched"
+	if current := ctx.GetCurrentRoute(); current != nil {
+		route = current.Path()
+	}
+	method := ctx.Method()
+
+	httpRequests.WithLabelValues(method, route, strconv.Itoa(ctx.GetStatusCode())).Inc()
+	httpDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+}
+
+// ObserveHealthCheck records the duration and outcome of one health check
+func ObserveHealthCheck(nodeID uint, status string, duration time.Duration) {
+	id := strconv.FormatUint(uint64(nodeID), 10)
+	healthCheckDuration.WithLabelValues(id).Observe(duration.Seconds())
+	healthChecks.WithLabelValues(id, status).Inc()
+}
+
+// recordEvent counts node lifecycle events published on the event bus
+func recordEvent(event events.Event) {
+	id := strconv.FormatUint(uint64(event.NodeID), 10)
+
+	switch event.Type {
+	case events.NodeStarted:
+		nodeLifecycle.WithLabelValues(id, "started").Inc()
+		if _, restarted := stoppedNodes.LoadAndDelete(event.NodeID); restarted {
+			nodeRestarts.WithLabelValues(id).Inc()
+		}
+	case events.NodeStopped:
+		nodeLifecycle.WithLabelValues(id, "stopped").Inc()
+		stoppedNodes.Store(event.NodeID, true)
+	case events.NodeCrashed:
+		nodeLifecycle.WithLabelValues(id, "crashed").Inc()
+		stoppedNodes.Store(event.NodeID, true)
+	case events.NodeDeleted:
+		stoppedNodes.Delete(event.NodeID)
+	}
+}
+
+// nodeCollector reports node counts by status and health, read from the database at scrape time
+type nodeCollector struct{}
+
+var nodesDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, "", "nodes"),
+	"Nodes, by status and health status.",
+	[]string{"status", "health_status"}, nil,
+)
+
+func (c *nodeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- nodesDesc
+}
+
+func (c *nodeCollector) Collect(ch chan<- prometheus.Metric) {
+	var rows []struct {
+		Status       string
+		HealthStatus string
+		Count        int64
+	}
+	if err := config.DB.Table("nodes").Select("status, health_status, COUNT(*) AS count").
+		Group("status, health_status").Scan(&rows).Error; err != nil {
+		logger.Error("Failed to collect node metrics", "error", err)
+		return
+	}
+
+	for _, row := range rows {
+		ch <- prometheus.MustNewConstMetric(nodesDesc, prometheus.GaugeValue, float64(row.Count), row.Status, row.HealthStatus)
+	}
+}
+
+// webSocketCollector exports the WebSocket hub statistics
+type webSocketCollector struct{}
+
+var (
+	wsClientsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "websocket", "clients"),
+		"Connected WebSocket clients.", nil, nil,
+	)
+	wsConnectionsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "websocket", "connections_total"),
+		"WebSocket connections accepted.", nil, nil,
+	)
+	wsSentDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "websocket", "messages_sent_total"),
+		"Messages written to WebSocket clients.", nil, nil,
+	)
+	wsDroppedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "websocket", "messages_dropped_total"),
+		"Messages dropped because a WebSocket or Server-Sent Events client's queue was full.", nil, nil,
+	)
+	wsDisconnectsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "websocket", "forced_disconnects_total"),
+		"Clients disconnected by the server, by reason.", []string{"reason"}, nil,
+	)
+)
+
+func (c *webSocketCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- wsClientsDesc
+	ch <- wsConnectionsDesc
+	ch <- wsSentDesc
+	ch <- wsDroppedDesc
+	ch <- wsDisconnectsDesc
+}
+
+func (c *webSocketCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := websocket.GetStats()
+	ch <- prometheus.MustNewConstMetric(wsClientsDesc, prometheus.GaugeValue, float64(stats.ConnectedClients))
+	ch <- prometheus.MustNewConstMetric(wsConnectionsDesc, prometheus.CounterValue, float64(stats.TotalConnections))
+	ch <- prometheus.MustNewConstMetric(wsSentDesc, prometheus.CounterValue, float64(stats.MessagesSent))
+	ch <- prometheus.MustNewConstMetric(wsDroppedDesc, prometheus.CounterValue, float64(stats.MessagesDropped))
+	ch <- prometheus.MustNewConstMetric(wsDisconnectsDesc, prometheus.CounterValue, float64(stats.SlowDisconnects), "slow_client")
+	ch <- prometheus.MustNewConstMetric(wsDisconnectsDesc, prometheus.CounterValue, float64(stats.TimeoutDisconnects), "pong_timeout")
+}
+
+// trafficCollector exports the request statistics recorded by node servers
+type trafficCollector struct{}
+
+var (
+	nodeRequestsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "node", "http_requests_total"),
+		"Requests served by node servers, by node and status code.", []string{"node_id", "code"}, nil,
+	)
+	nodeBytesInDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "node", "http_request_bytes_total"),
+		"Request body bytes received by node servers, by node.", []string{"node_id"}, nil,
+	)
+	nodeBytesOutDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "node", "http_response_bytes_total"),
+		"Response body bytes sent by node servers, by node.", []string{"node_id"}, nil,
+	)
+	nodeLatencyDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "node", "http_request_duration_seconds"),
+		"Request latency of node servers, by node.", []string{"node_id"}, nil,
+	)
+)
+
+func (c *trafficCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- nodeRequestsDesc
+	ch <- nodeBytesInDesc
+	ch <- nodeBytesOutDesc
+	ch <- nodeLatencyDesc
+}
+
+func (c *trafficCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, stats := range traffic.All() {
+		id := strconv.FormatUint(uint64(stats.NodeID), 10)
+		for code, count := range stats.StatusCodes {
+			ch <- prometheus.MustNewConstMetric(nodeRequestsDesc, prometheus.CounterValue, float64(count), id, code)
+		}
+		ch <- prometheus.MustNewConstMetric(nodeBytesInDesc, prometheus.CounterValue, float64(stats.BytesIn), id)
+		ch <- prometheus.MustNewConstMetric(nodeBytesOutDesc, prometheus.CounterValue, float64(stats.BytesOut), id)
+
+		buckets := make(map[float64]uint64, len(stats.Latency.Buckets))
+		for _, bucket := range stats.Latency.Buckets {
+			buckets[bucket.LE] = bucket.Count
+		}
+		ch <- prometheus.MustNewConstHistogram(nodeLatencyDesc, stats.Latency.Count, stats.Latency.SumSeconds, buckets, id)
+	}
+}
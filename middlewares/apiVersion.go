@@ -0,0 +1,60 @@
+package middlewares
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kataras/iris/v12"
+)
+
+// APIVersion records which version of the API a request was routed through, so handlers
+// shared by several versions can read it with utils.APIVersion where their behaviour differs.
+// The prefix it was served under is kept for links to other resources, see utils.APIPath.
+func APIVersion(version int, prefix string) iris.Handler {
+	prefix = strings.TrimRight(prefix, "/")
+	return func(ctx iris.Context) {
+		ctx.Values().Set("api_version", version)
+		ctx.Values().Set("api_prefix", prefix)
+		ctx.Next()
+	}
+}
+
+// Deprecated marks every response of a route as deprecated (RFC 9745) and points to the path
+// that replaces it. The Sunset header (RFC 8594) is added when a valid date is configured;
+// it accepts RFC 3339 timestamps and plain dates such as 2027-06-30.
+func Deprecated(successorPrefix string, sunset string) iris.Handler {
+	sunsetHeader := ""
+	if at, ok := parseSunset(sunset); ok {
+		sunsetHeader = at.UTC().Format(http.TimeFormat)
+	} else if sunset != "" {
+		httpLogger.Warn("Ignoring invalid sunset date", "sunset", sunset)
+	}
+
+	return func(ctx iris.Context) {
+		ctx.Header("Deprecation", "true")
+		if sunsetHeader != "" {
+			ctx.Header("Sunset", sunsetHeader)
+		}
+		ctx.Header("Link", "<"+successorPrefix+ctx.Path()+">; rel=\"successor-version\"")
+		ctx.Next()
+	}
+}
+
+// parseSunset reads a sunset date as an RFC 3339 timestamp, a date or a Unix timestamp
+func parseSunset(value string) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+	if at, err := time.Parse(time.RFC3339, value); err == nil {
+		return at, true
+	}
+	if at, err := time.Parse(time.DateOnly, value); err == nil {
+		return at, true
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), true
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,130 @@
+package middlewares
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"node_management_application/logging"
+	"node_management_application/services"
+	"node_management_application/utils"
+
+	"github.com/kataras/iris/v12"
+)
+
+// IdempotencyKeyHeader names the header clients set to make a mutating request safe to retry
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotentReplayedHeader marks a response replayed from an earlier request with the same key
+const IdempotentReplayedHeader = "Idempotent-Replayed"
+
+// validIdempotencyKey allows up to 255 visible ASCII characters, enough for a UUID or a hash
+var validIdempotencyKey = regexp.MustCompile(`^[\x21-\x7e]{1,255}$`)
+
+// replayedHeaders are the response headers stored and replayed with the body
+var replayedHeaders = []string{"Content-Type", "ETag", "Location", "Content-Disposition"}
+
+var idempotencyLogger = logging.For("idempotency")
+
+// Idempotency replays the stored response when a mutating request is retried with the same
+// Idempotency-Key, so a retried create or start has no second effect. A key reused for a
+// different request is rejected with 422. It must run after Authenticate; keys are per user.
+func Idempotency(ctx iris.Context) {
+	key := ctx.GetHeader(IdempotencyKeyHeader)
+	if key == "" || !isMutating(ctx.Method()) {
+		ctx.Next()
+		return
+	}
+	if !validIdempotencyKey.MatchString(key) {
+		utils.ProblemResponse(ctx, http.StatusBadRequest, utils.CodeInvalidRequest, "Idempotency-Key must be 1 to 255 visible ASCII characters")
+		return
+	}
+
+	// Keep the body readable for the handler after hashing it
+	ctx.RecordRequestBody(true)
+	body, err := ctx.GetBody()
+	if err != nil {
+		utils.InvalidRequestResponse(ctx, "Invalid request body")
+		return
+	}
+
+	requestCtx := ctx.Request().Context()
+	userID := ctx.Values().GetUintDefault("user_id", 0)
+	stored, err := services.ClaimIdempotencyKey(requestCtx, userID, key, fingerprint(ctx, body))
+	switch {
+	case errors.Is(err, services.ErrIdempotencyKeyReused):
+		utils.ProblemResponse(ctx, http.StatusUnprocessableEntity, utils.CodeIdempotencyKeyReused, "Idempotency-Key was already used for a different request")
+		return
+	case errors.Is(err, services.ErrIdempotencyKeyInUse):
+		ctx.Header("Retry-After", "1")
+		utils.ProblemResponse(ctx, http.StatusConflict, utils.CodeIdempotencyKeyInUse, "A request with this Idempotency-Key is still being processed")
+		return
+	case err != nil:
+		idempotencyLogger.ErrorContext(requestCtx, "Failed to claim idempotency key", "error", err)
+		utils.ProblemResponse(ctx, http.StatusInternalServerError, utils.CodeInternal, "An internal error occurred; quote the correlation ID when reporting it")
+		return
+	}
+
+	if stored != nil {
+		for name, value := range stored.Header {
+			ctx.Header(name, value)
+		}
+		ctx.Header(IdempotentReplayedHeader, "true")
+		ctx.StatusCode(stored.StatusCode)
+		ctx.Write(stored.Body)
+		return
+	}
+
+	ctx.Record()
+	ctx.Next()
+
+	// The outcome is stored even if the client went away; that is when it retries
+	storeCtx := context.WithoutCancel(requestCtx)
+	status := ctx.GetStatusCode()
+	if status >= http.StatusInternalServerError {
+		// Server errors are not final; let a retry run the request again
+		if err := services.ReleaseIdempotencyKey(storeCtx, userID, key); err != nil {
+			idempotencyLogger.ErrorContext(requestCtx, "Failed to release idempotency key", "error", err)
+		}
+		return
+	}
+
+	recorder := ctx.Recorder()
+	response := services.StoredResponse{StatusCode: status, Header: map[string]string{}, Body: recorder.Body()}
+	for _, name := range replayedHeaders {
+		if value := recorder.Header().Get(name); value != "" {
+			response.Header[name] = value
+		}
+	}
+	if err := services.CompleteIdempotencyKey(storeCtx, userID, key, response); err != nil {
+		idempotencyLogger.ErrorContext(requestCtx, "Failed to store idempotent response", "error", err)
+	}
+}
+
+// fingerprint identifies a request by its method, API version, path, query and body. The path
+// is taken without the API prefix, so a retry through an unversioned alias of the same version,
+// e.g. /nodes for /api/v1/nodes, is the same request.
+func fingerprint(ctx iris.Context, body []byte) string {
+	path := strings.TrimPrefix(ctx.Path(), ctx.Values().GetString("api_prefix"))
+	if query := ctx.Request().URL.RawQuery; query != "" {
+		path += "?" + query
+	}
+
+	hash := sha256.New()
+	hash.Write([]byte(ctx.Method() + " v" + strconv.Itoa(utils.APIVersion(ctx)) + " " + path + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func isMutating(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+package middlewares
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"regexp"
+	"time"
+
+	"node_management_application/logging"
+
+	"github.com/kataras/iris/v12"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestIDHeader carries the request ID in both directions
+const RequestIDHeader = "X-Request-ID"
+
+// validRequestID limits caller supplied IDs to something safe to log and echo
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+var httpLogger = logging.For("http")
+
+// RequestID assigns every request an ID, reusing a valid X-Request-ID from the caller.
+// The ID is echoed in the response, stored in the request context for service loggers
+// and logged with the outcome of the request, at debug level when it succeeded.
+func RequestID(ctx iris.Context) {
+	id := ctx.GetHeader(RequestIDHeader)
+	if !validRequestID.MatchString(id) {
+		id = newRequestID()
+	}
+
+	ctx.Header(RequestIDHeader, id)
+	ctx.Values().Set("request_id", id)
+	requestCtx := logging.WithRequestID(ctx.Request().Context(), id)
+	trace.SpanFromContext(requestCtx).SetAttributes(attribute.String("request.id", id))
+	ctx.ResetRequest(ctx.Request().WithContext(requestCtx))
+
+	start := time.Now()
+	ctx.Next()
+
+	// Successful requests, including metrics scrapes and health checks, are debug output;
+	// failures are worth a line at the default level
+	status := ctx.GetStatusCode()
+	level := slog.LevelDebug
+	switch {
+	case status >= 500:
+		level = slog.LevelWarn
+	case status >= 400:
+		level = slog.LevelInfo
+	}
+	httpLogger.Log(requestCtx, level, "Request completed",
+		"method", ctx.Method(),
+		"path", ctx.Path(),
+		"status", status,
+		"duration_ms", time.Since(start).Milliseconds(),
+		"user_id", ctx.Values().GetUintDefault("user_id", 0),
+	)
+}
+
+// newRequestID returns 16 random bytes in hex
+func newRequestID() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
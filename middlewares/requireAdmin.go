@@ -0,0 +1,24 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"node_management_application/config"
+	"node_management_application/models"
+	"node_management_application/utils"
+
+	"github.com/kataras/iris/v12"
+)
+
+// RequireAdmin only lets administrators through; it must run after Authenticate
+func RequireAdmin(ctx iris.Context) {
+	userID := ctx.Values().GetUintDefault("user_id", 0)
+
+	var user models.User
+	if result := config.DB.First(&user, userID); result.Error != nil || !user.IsAdmin {
+		utils.ProblemResponse(ctx, http.StatusForbidden, utils.CodeForbidden, "Administrator privileges required")
+		return
+	}
+
+	ctx.Next()
+}
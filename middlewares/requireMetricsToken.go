@@ -0,0 +1,29 @@
+package middlewares
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"node_management_application/config"
+	"node_management_application/utils"
+
+	"github.com/kataras/iris/v12"
+)
+
+// RequireMetricsToken protects the metrics endpoint when config.MetricsToken is set
+func RequireMetricsToken(ctx iris.Context) {
+	if config.MetricsToken == "" {
+		ctx.Next()
+		return
+	}
+
+	token := strings.TrimPrefix(ctx.GetHeader("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(config.MetricsToken)) != 1 {
+		ctx.Header("WWW-Authenticate", `Bearer realm="metrics"`)
+		utils.ProblemResponse(ctx, http.StatusUnauthorized, utils.CodeUnauthorized, "Invalid or missing metrics token")
+		return
+	}
+
+	ctx.Next()
+}
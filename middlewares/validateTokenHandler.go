@@ -1,10 +1,12 @@
 package middlewares
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 
 	"node_management_application/config"
+	"node_management_application/utils"
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/kataras/iris/v12"
@@ -22,30 +24,56 @@ func Authenticate(ctx iris.Context) {
 	// Extract the token from the Authorization header
 	authHeader := ctx.GetHeader("Authorization")
 	if authHeader == "" {
-		ctx.StatusCode(http.StatusUnauthorized)
-		ctx.JSON(iris.Map{"error": "Authorization token required"})
+		utils.ProblemResponse(ctx, http.StatusUnauthorized, utils.CodeUnauthorized, "Authorization token required")
 		return
 	}
 
 	// Check if the Authorization header has the "Bearer " prefix
 	if !strings.HasPrefix(authHeader, "Bearer ") {
-		ctx.StatusCode(http.StatusUnauthorized)
-		ctx.JSON(iris.Map{"error": "Invalid Authorization format"})
+		utils.ProblemResponse(ctx, http.StatusUnauthorized, utils.CodeUnauthorized, "Invalid Authorization format")
 		return
 	}
 
 	// Remove the "Bearer " prefix
 	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 
-	// Parse and validate the token
+	authenticateToken(ctx, tokenString)
+}
+
+// AuthenticateStream validates tokens for streaming endpoints. Browsers cannot set headers
+// when opening a WebSocket, so the token may also be passed as ?access_token=.
+func AuthenticateStream(ctx iris.Context) {
+	tokenString := ctx.URLParam("access_token")
+	if authHeader := ctx.GetHeader("Authorization"); strings.HasPrefix(authHeader, "Bearer ") {
+		tokenString = strings.TrimPrefix(authHeader, "Bearer ")
+	}
+
+	if tokenString == "" {
+		utils.ProblemResponse(ctx, http.StatusUnauthorized, utils.CodeUnauthorized, "Authorization token required")
+		return
+	}
+
+	authenticateToken(ctx, tokenString)
+}
+
+// ParseToken validates a JWT and returns its claims
+func ParseToken(tokenString string) (*UserClaims, error) {
 	claims := &UserClaims{}
 	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
 		return config.JWTSecretKey, nil
 	})
-
 	if err != nil || !token.Valid {
-		ctx.StatusCode(http.StatusUnauthorized)
-		ctx.JSON(iris.Map{"error": "Invalid or expired token"})
+		return nil, errors.New("invalid or expired token")
+	}
+	return claims, nil
+}
+
+// authenticateToken stores the token's user in the context or rejects the request
+func authenticateToken(ctx iris.Context, tokenString string) {
+	// Parse and validate the token
+	claims, err := ParseToken(tokenString)
+	if err != nil {
+		utils.ProblemResponse(ctx, http.StatusUnauthorized, utils.CodeUnauthorized, "Invalid or expired token")
 		return
 	}
 
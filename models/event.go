@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// EventRecord is a published event kept in the bounded event log for replay
+type EventRecord struct {
+	ID        uint64    `gorm:"primaryKey;autoIncrement:false"`
+	Type      string    `gorm:"size:50;not null"`
+	Version   int       `gorm:"not null"`
+	Timestamp time.Time `gorm:"not null"`
+	UserID    uint      `gorm:"index"`
+	NodeID    uint
+	Data      string    `gorm:"type:text"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Node access levels, each including the ones before it: view reads a node, operate also
+// starts, stops and checks it, and admin also edits, deletes, shares and transfers it
+const (
+	AccessView    = "view"
+	AccessOperate = "operate"
+	AccessAdmin   = "admin"
+)
+
+// NodeGrant gives a user access to a node they do not own
+type NodeGrant struct {
+	ID        uint      `gorm:"primaryKey" json:"-"`
+	NodeID    uint      `gorm:"not null;uniqueIndex:idx_node_grant" json:"node_id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_node_grant;index" json:"user_id"`
+	Level     string    `gorm:"size:20;not null" json:"level"`
+	GrantedBy uint      `json:"granted_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
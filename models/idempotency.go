@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// IdempotencyKey is a request made with an Idempotency-Key header and, once it finished,
+// the response replayed to retries of it
+type IdempotencyKey struct {
+	ID          uint   `gorm:"primaryKey"`
+	UserID      uint   `gorm:"not null;uniqueIndex:idx_idempotency_user_key"`
+	Key         string `gorm:"column:idempotency_key;size:255;not null;uniqueIndex:idx_idempotency_user_key"`
+	Fingerprint string `gorm:"size:64;not null"` // SHA-256 of the method, API version, unprefixed path, query and body
+	StatusCode  int    // 0 while the first request is still being processed
+	Header      string `gorm:"type:text"` // JSON object of the replayed response headers
+	Body        []byte `gorm:"type:mediumblob"`
+	CreatedAt   time.Time
+	ExpiresAt   time.Time `gorm:"index;not null"`
+}
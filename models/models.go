@@ -0,0 +1,9 @@
+package models
+
+// All lists every model whose table the application migrates
+func All() []interface{} {
+	return []interface{}{
+		&User{}, &Node{}, &PortRange{}, &PortReservation{}, &Subnet{}, &EventRecord{}, &NodeTraffic{},
+		&IdempotencyKey{}, &Operation{}, &Team{}, &TeamMember{}, &NodeGrant{}, &Quota{},
+	}
+}
@@ -10,15 +10,21 @@ import (
 var DB *gorm.DB // Assume this is initialized elsewhere
 
 type Node struct {
-	ID           uint      `gorm:"primaryKey"`
-	UserID       uint      `gorm:"not null"`
-	Name         string    `gorm:"size:100;not null"`
-	IP           string    `gorm:"size:50;not null"`
-	Status       string    `gorm:"size:50;default:'Stopped'"`
-	HealthStatus string    `gorm:"size:50;default:'Healthy'"`
-	Location     string    `gorm:"size:100"`
-	Port         int
-	LastChecked  time.Time `gorm:"autoCreateTime"`
+	ID            uint   `gorm:"primaryKey"`
+	UserID        uint   `gorm:"not null"` // Owner; always has admin access
+	TeamID        *uint  `gorm:"index"`    // Team the node belongs to, whose members share it
+	Name          string `gorm:"size:100;not null"`
+	IP            string `gorm:"size:50;not null"`
+	Status        string `gorm:"size:50;default:'Stopped'"`
+	HealthStatus  string `gorm:"size:50;default:'Healthy'"`
+	Location      string `gorm:"size:100"`
+	Port          int
+	LastChecked   time.Time `gorm:"autoCreateTime"`
+	Version       uint      `gorm:"not null;default:1"`               // Bumped on every user edit for optimistic concurrency
+	Group         string    `gorm:"column:node_group;size:100;index"` // Manifest group, which clients can subscribe to
+	CheckInterval int       `gorm:"not null;default:0"`               // Seconds between health checks; 0 checks every monitor round
+	StartAt       string    `gorm:"size:5"`                           // Daily UTC time, as HH:MM, to start the node at
+	StopAt        string    `gorm:"size:5"`                           // Daily UTC time, as HH:MM, to stop the node at
 }
 
 func GetNodeByID(id uint) (*Node, error) {
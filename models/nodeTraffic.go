@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// NodeTraffic holds the accumulated request statistics of a node server when they are kept across restarts
+type NodeTraffic struct {
+	NodeID         uint      `gorm:"primaryKey;autoIncrement:false"`
+	Requests       uint64    `gorm:"not null"`
+	BytesIn        uint64    `gorm:"not null"`
+	BytesOut       uint64    `gorm:"not null"`
+	StatusCodes    string    `gorm:"type:text"` // JSON object of status code to count
+	LatencyBuckets string    `gorm:"type:text"` // JSON array of per-bucket counts
+	LatencySum     float64   `gorm:"not null"`
+	Since          time.Time `gorm:"not null"`
+	UpdatedAt      time.Time
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Operation statuses; succeeded, failed and cancelled are final
+const (
+	OperationPending   = "pending"
+	OperationRunning   = "running"
+	OperationSucceeded = "succeeded"
+	OperationFailed    = "failed"
+	OperationCancelled = "cancelled"
+)
+
+// Operation is a node action accepted with 202 Accepted and carried out in the background.
+// Finished operations are kept as the history of the user's actions.
+type Operation struct {
+	ID              uint            `gorm:"primaryKey" json:"id"`
+	UserID          uint            `gorm:"index;not null" json:"user_id"`
+	NodeID          uint            `gorm:"index" json:"node_id,omitempty"`
+	Type            string          `gorm:"size:50;not null" json:"type"` // e.g. "node.start"
+	Status          string          `gorm:"size:20;not null;index" json:"status"`
+	Progress        int             `gorm:"not null;default:0" json:"progress"` // Percent done
+	Message         string          `gorm:"size:255" json:"message,omitempty"`
+	Result          json.RawMessage `gorm:"type:text" json:"result,omitempty"`
+	ErrorCode       string          `gorm:"size:50" json:"error_code,omitempty"`
+	Error           string          `gorm:"type:text" json:"error,omitempty"`
+	CancelRequested bool            `gorm:"not null;default:false" json:"cancel_requested"`
+	CreatedAt       time.Time       `json:"created_at"`
+	UpdatedAt       time.Time       `json:"updated_at"`
+	StartedAt       *time.Time      `json:"started_at,omitempty"`
+	FinishedAt      *time.Time      `json:"finished_at,omitempty"`
+}
+
+// Finished reports whether the operation reached a final status
+func (o *Operation) Finished() bool {
+	return o.Status == OperationSucceeded || o.Status == OperationFailed || o.Status == OperationCancelled
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// PortRange is an admin-configured pool of ports that can be handed out for an IP.
+// An empty IP makes the range apply to every address.
+type PortRange struct {
+	ID        uint   `gorm:"primaryKey" json:"id"`
+	IP        string `gorm:"size:50;index" json:"ip"`
+	StartPort int    `gorm:"not null" json:"start_port"`
+	EndPort   int    `gorm:"not null" json:"end_port"`
+}
+
+// PortReservation ties an IP:port to the node that owns it, whether or not the node is running
+type PortReservation struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	NodeID    uint      `gorm:"uniqueIndex;not null" json:"node_id"`
+	IP        string    `gorm:"size:50;not null;uniqueIndex:idx_port_reservation_address" json:"ip"`
+	Port      int       `gorm:"not null;uniqueIndex:idx_port_reservation_address" json:"port"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Quota scopes; the default quota applies to every user and team without a quota of their own
+const (
+	QuotaScopeDefault = "default"
+	QuotaScopeUser    = "user"
+	QuotaScopeTeam    = "team"
+)
+
+// Quota limits the resources of a user or team. A nil limit is unlimited, and an empty
+// AllowedPorts allows every port. A user's quota covers the nodes they own outside any team;
+// the nodes of a team count against the team's quota.
+type Quota struct {
+	ID                       uint      `gorm:"primaryKey" json:"id"`
+	Scope                    string    `gorm:"size:20;not null;uniqueIndex:idx_quota_subject" json:"scope"`
+	SubjectID                uint      `gorm:"not null;uniqueIndex:idx_quota_subject" json:"subject_id"`
+	MaxNodes                 *int      `json:"max_nodes"`
+	MaxRunningNodes          *int      `json:"max_running_nodes"`
+	MaxHealthChecksPerMinute *int      `json:"max_health_checks_per_minute"`
+	AllowedPorts             string    `gorm:"size:255" json:"allowed_ports"` // e.g. "8000-8099,9000"
+	UpdatedAt                time.Time `json:"updated_at"`
+}
@@ -0,0 +1,9 @@
+package models
+
+// Subnet is an admin-defined address range that node IPs are allocated from
+type Subnet struct {
+	ID          uint   `gorm:"primaryKey" json:"id"`
+	Name        string `gorm:"size:100;not null" json:"name"`
+	CIDR        string `gorm:"size:64;not null;uniqueIndex" json:"cidr"`
+	Description string `gorm:"size:255" json:"description"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// Team member roles; team admins manage the members and get admin access to the team's nodes
+const (
+	TeamRoleAdmin  = "admin"
+	TeamRoleMember = "member"
+)
+
+// Team is a group of users that owns nodes together
+type Team struct {
+	ID        uint         `gorm:"primaryKey" json:"id"`
+	Name      string       `gorm:"size:100;not null;uniqueIndex" json:"name"`
+	CreatedAt time.Time    `json:"created_at"`
+	Members   []TeamMember `gorm:"foreignKey:TeamID" json:"members,omitempty"`
+}
+
+// TeamMember is the membership of a user in a team
+type TeamMember struct {
+	ID        uint      `gorm:"primaryKey" json:"-"`
+	TeamID    uint      `gorm:"not null;uniqueIndex:idx_team_member" json:"team_id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_team_member;index" json:"user_id"`
+	Role      string    `gorm:"size:20;not null" json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -5,4 +5,5 @@ type User struct {
 	Name     string `gorm:"size:100;not null"`
 	Email    string `gorm:"size:100;unique;not null"`
 	Password string `gorm:"size:255;not null"` // Store hashed passwords
+	IsAdmin  bool   `gorm:"default:false"`
 }
@@ -0,0 +1,322 @@
+package openapi
+
+import (
+	"net/http"
+	"time"
+
+	"node_management_application/events"
+	"node_management_application/logging"
+	"node_management_application/models"
+	"node_management_application/services"
+	"node_management_application/traffic"
+	"node_management_application/websocket"
+)
+
+// Bodies the controllers declare inline; they mirror those declarations field for field
+
+type registration struct {
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type registrationResult struct {
+	User models.User `json:"user"`
+}
+
+type credentials struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type loginUser struct {
+	ID    uint   `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+type loginResult struct {
+	User  loginUser `json:"user"`
+	Token string    `json:"token"`
+}
+
+type userUpdate struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+type nodeCreate struct {
+	models.Node
+	AutoPort bool `json:"auto_port"`
+	SubnetID uint `json:"subnet_id"`
+}
+
+type nodeUpdate struct {
+	Name     string `json:"name"`
+	IP       string `json:"ip"`
+	Port     int    `json:"port"`
+	Location string `json:"location"`
+}
+
+type jsonPatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	From  string      `json:"from,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+type nodeAction struct {
+	Message string      `json:"message"`
+	Node    models.Node `json:"node"`
+}
+
+type nodeHealth struct {
+	NodeID       uint      `json:"node_id"`
+	HealthStatus string    `json:"health_status"`
+	LastChecked  time.Time `json:"last_checked"`
+	CheckError   string    `json:"check_error,omitempty"`
+}
+
+type message struct {
+	Message string `json:"message"`
+}
+
+type teamCreate struct {
+	Name string `json:"name"`
+}
+
+type teamMemberRole struct {
+	Role string `json:"role"` // admin or member
+}
+
+type grantLevel struct {
+	Level string `json:"level"` // view, operate or admin
+}
+
+type nodeTransfer struct {
+	UserID *uint `json:"user_id,omitempty"`
+	TeamID *uint `json:"team_id,omitempty"`
+}
+
+type quotaLimits struct {
+	MaxNodes                 *int   `json:"max_nodes"`
+	MaxRunningNodes          *int   `json:"max_running_nodes"`
+	MaxHealthChecksPerMinute *int   `json:"max_health_checks_per_minute"`
+	AllowedPorts             string `json:"allowed_ports"`
+}
+
+// quotaNote explains the limits of a quota body
+const quotaNote = "Leave a limit out or null for no limit. allowed_ports lists ports and ranges such as 8000-8099,9000; empty allows every port. The quota replaces the previous one."
+
+type logLevelChange struct {
+	Component string `json:"component,omitempty"`
+	Level     string `json:"level"`
+}
+
+// asyncParams ask a node action to run as a background operation
+var asyncParams = []Parameter{
+	{Name: "async", In: "query", Description: "Run in the background and answer 202 with the operation", Schema: &Schema{Type: "boolean"}},
+	{Name: "Prefer", In: "header", Description: "respond-async has the same effect as async=true", Schema: &Schema{Type: "string"}},
+}
+
+var operationStatuses = []string{models.OperationPending, models.OperationRunning, models.OperationSucceeded, models.OperationFailed, models.OperationCancelled}
+
+// Reusable parameters
+var (
+	ifMatch        = Parameter{Name: "If-Match", In: "header", Description: "ETag of the version being edited; the request fails with 412 when the node changed since", Schema: &Schema{Type: "string"}}
+	ifNoneMatch    = Parameter{Name: "If-None-Match", In: "header", Description: "ETag the client holds; answered with 304 when it is still current", Schema: &Schema{Type: "string"}}
+	idempotencyKey = Parameter{Name: "Idempotency-Key", In: "header", Description: "Unique key of up to 255 characters; retries with the same key replay the first response instead of repeating the change, and reusing it for a different request fails with 422", Schema: &Schema{Type: "string"}}
+	dryRun         = Parameter{Name: "dry_run", In: "query", Description: "Report what would happen without changing anything", Schema: &Schema{Type: "boolean"}}
+	restart        = Parameter{Name: "restart", In: "query", Description: "Restart a running node to move it to a new address; without it the change fails with 409", Schema: &Schema{Type: "boolean"}}
+	format         = Parameter{Name: "format", In: "query", Description: "Document format; defaults to the request content type", Schema: &Schema{Type: "string", Enum: []string{services.FormatJSON, services.FormatYAML, services.FormatCSV}}}
+)
+
+var tags = []Tag{
+	{Name: "auth", Description: "Registration and login"},
+	{Name: "users", Description: "User accounts"},
+	{Name: "nodes", Description: "Node servers the authenticated user owns or shares"},
+	{Name: "teams", Description: "Teams sharing the nodes they own"},
+	{Name: "background", Description: "Node actions running in the background"},
+	{Name: "subnets", Description: "Address ranges node IPs are allocated from"},
+	{Name: "quotas", Description: "Limits on the nodes, running nodes, health checks and ports of users and teams"},
+	{Name: "admin", Description: "Administration; requires an administrator account"},
+	{Name: "manifests", Description: "Declarative fleet management"},
+	{Name: "events", Description: "Live node and user events"},
+	{Name: "operations", Description: "Monitoring and documentation"},
+}
+
+// endpoints describes every route the server registers; Verify fails the startup when they disagree
+var endpoints = []endpoint{
+	// Authentication
+	{method: "POST", path: "/register", id: "registerUser", tag: "auth", summary: "Create a user account",
+		body: registration{}, response: registrationResult{}, errors: []int{http.StatusBadRequest, http.StatusConflict}},
+	{method: "POST", path: "/login", id: "login", tag: "auth", summary: "Exchange credentials for a token valid for 24 hours",
+		body: credentials{}, response: loginResult{}, errors: []int{http.StatusBadRequest}},
+
+	// Users
+	{method: "GET", path: "/users", id: "listUsers", tag: "users", summary: "List users", auth: authBearer,
+		response: []models.User{}},
+	{method: "GET", path: "/users/profile", id: "getProfile", tag: "users", summary: "Get the authenticated user", auth: authBearer,
+		response: models.User{}, errors: []int{http.StatusNotFound}},
+	{method: "PUT", path: "/users/{id}", id: "updateUser", tag: "users", summary: "Replace the name and email of your own user, or any user as an administrator", auth: authBearer,
+		body: userUpdate{}, response: models.User{}, errors: []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound}},
+	{method: "PATCH", path: "/users/{id}", id: "patchUser", tag: "users", summary: "Partially update your own user, or any user as an administrator", auth: authBearer,
+		body: userUpdate{}, bodyTypes: []string{services.MergePatchContentType, services.JSONPatchContentType},
+		response: models.User{}, errors: []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusUnsupportedMediaType}},
+	{method: "DELETE", path: "/users/{id}", id: "deleteUser", tag: "users", summary: "Delete your own user, or any user as an administrator", auth: authBearer,
+		response: message{}, errors: []int{http.StatusForbidden, http.StatusNotFound, http.StatusConflict}},
+
+	// Nodes
+	{method: "GET", path: "/nodes", id: "listNodes", tag: "nodes", summary: "List nodes", auth: authBearer,
+		response: []models.Node{}},
+	{method: "POST", path: "/nodes", id: "createNode", tag: "nodes", summary: "Create a node", auth: authBearer,
+		body: nodeCreate{}, bodyNote: "Set auto_port to take a port from the configured pool, and subnet_id without an IP to allocate an address.",
+		response: models.Node{}, etag: true, errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusForbidden, http.StatusConflict}},
+	{method: "POST", path: "/nodes/import", id: "importNodes", tag: "nodes", summary: "Create many nodes at once, all or none", auth: authBearer,
+		params: []Parameter{format, dryRun}, body: []services.NodeRecord{}, bodyTypes: []string{"application/json", "application/yaml", "text/csv"},
+		response: services.ImportResult{}, errors: []int{http.StatusBadRequest, http.StatusForbidden, http.StatusUnsupportedMediaType, http.StatusUnprocessableEntity}},
+	{method: "GET", path: "/nodes/export", id: "exportNodes", tag: "nodes", summary: "Download all nodes", auth: authBearer,
+		params: []Parameter{format}, response: []services.NodeRecord{}, responseType: "application/json,application/yaml,text/csv",
+		errors: []int{http.StatusUnsupportedMediaType}},
+	{method: "GET", path: "/nodes/{id}", id: "getNode", tag: "nodes", summary: "Get a node", auth: authBearer,
+		params: []Parameter{ifNoneMatch}, response: models.Node{}, etag: true, errors: []int{http.StatusNotFound}},
+	{method: "PUT", path: "/nodes/{id}", id: "updateNode", tag: "nodes", summary: "Replace the editable fields of a node", auth: authBearer,
+		params: []Parameter{ifMatch, restart}, body: nodeUpdate{}, response: models.Node{}, etag: true,
+		errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusForbidden, http.StatusConflict, http.StatusPreconditionFailed}},
+	{method: "PATCH", path: "/nodes/{id}", id: "patchNode", tag: "nodes", summary: "Partially update a node", auth: authBearer,
+		params: []Parameter{ifMatch, restart},
+		body:   nodeUpdate{}, bodyTypes: []string{services.MergePatchContentType, services.JSONPatchContentType},
+		response: models.Node{}, etag: true,
+		errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusForbidden, http.StatusConflict, http.StatusPreconditionFailed, http.StatusUnsupportedMediaType}},
+	{method: "DELETE", path: "/nodes/{id}", id: "deleteNode", tag: "nodes", summary: "Delete a node", auth: authBearer,
+		params: []Parameter{ifMatch}, response: message{}, errors: []int{http.StatusNotFound, http.StatusForbidden, http.StatusPreconditionFailed}},
+	{method: "POST", path: "/nodes/{id}/start", id: "startNode", tag: "nodes", summary: "Start the node's server", auth: authBearer,
+		params: asyncParams, response: nodeAction{}, async: true, errors: []int{http.StatusNotFound, http.StatusForbidden, http.StatusConflict}},
+	{method: "POST", path: "/nodes/{id}/stop", id: "stopNode", tag: "nodes", summary: "Stop the node's server", auth: authBearer,
+		params: asyncParams, response: nodeAction{}, async: true, errors: []int{http.StatusNotFound, http.StatusForbidden, http.StatusConflict}},
+	{method: "GET", path: "/nodes/{id}/health", id: "checkNodeHealth", tag: "nodes", summary: "Run a health check now", auth: authBearer,
+		response: nodeHealth{}, errors: []int{http.StatusNotFound, http.StatusForbidden, http.StatusTooManyRequests}},
+	{method: "GET", path: "/nodes/{id}/stats", id: "getNodeStats", tag: "nodes", summary: "Get the traffic statistics of the node's server", auth: authBearer,
+		response: traffic.Stats{}, errors: []int{http.StatusNotFound}},
+
+	{method: "GET", path: "/nodes/{id}/grants", id: "listNodeGrants", tag: "nodes", summary: "List the users the node is shared with", auth: authBearer,
+		response: []models.NodeGrant{}, errors: []int{http.StatusNotFound, http.StatusForbidden}},
+	{method: "PUT", path: "/nodes/{id}/grants/{userId}", id: "grantNodeAccess", tag: "nodes", summary: "Share the node with a user", auth: authBearer,
+		body: grantLevel{}, bodyNote: "View reads the node, operate also starts, stops and checks it, and admin also edits, deletes and shares it.",
+		response: models.NodeGrant{}, errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusForbidden}},
+	{method: "DELETE", path: "/nodes/{id}/grants/{userId}", id: "revokeNodeAccess", tag: "nodes", summary: "Stop sharing the node with a user", auth: authBearer,
+		response: message{}, errors: []int{http.StatusNotFound, http.StatusForbidden}},
+	{method: "POST", path: "/nodes/{id}/transfer", id: "transferNode", tag: "nodes", summary: "Hand the node to another owner or team", auth: authBearer,
+		params: []Parameter{ifMatch}, body: nodeTransfer{},
+		bodyNote: "Only the owner or an admin of the node's team may transfer it, and only into a team they belong to. team_id 0 takes the node out of its team.",
+		response: models.Node{}, etag: true, errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusForbidden, http.StatusPreconditionFailed}},
+
+	// Teams
+	{method: "GET", path: "/teams", id: "listTeams", tag: "teams", summary: "List your teams", auth: authBearer,
+		response: []models.Team{}},
+	{method: "POST", path: "/teams", id: "createTeam", tag: "teams", summary: "Create a team with you as its admin", auth: authBearer,
+		body: teamCreate{}, response: models.Team{}, errors: []int{http.StatusBadRequest, http.StatusConflict}},
+	{method: "GET", path: "/teams/{id}", id: "getTeam", tag: "teams", summary: "Get a team and its members", auth: authBearer,
+		response: models.Team{}, errors: []int{http.StatusNotFound}},
+	{method: "DELETE", path: "/teams/{id}", id: "deleteTeam", tag: "teams", summary: "Delete a team that owns no nodes", auth: authBearer,
+		response: message{}, errors: []int{http.StatusNotFound, http.StatusForbidden, http.StatusConflict}},
+	{method: "PUT", path: "/teams/{id}/members/{userId}", id: "setTeamMember", tag: "teams", summary: "Add a member or change their role", auth: authBearer,
+		body: teamMemberRole{}, bodyNote: "Team admins have admin access to the team's nodes and members operate access.",
+		response: models.TeamMember{}, errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusForbidden, http.StatusConflict}},
+	{method: "DELETE", path: "/teams/{id}/members/{userId}", id: "removeTeamMember", tag: "teams", summary: "Remove a member, or leave the team", auth: authBearer,
+		response: message{}, errors: []int{http.StatusNotFound, http.StatusForbidden, http.StatusConflict}},
+
+	// Background operations
+	{method: "GET", path: "/operations", id: "listOperations", tag: "background", summary: "List your operations, newest first", auth: authBearer,
+		params: []Parameter{
+			{Name: "node_id", In: "query", Description: "Only operations on this node", Schema: &Schema{Type: "integer", Format: "int64"}},
+			{Name: "status", In: "query", Description: "Only operations in this status", Schema: &Schema{Type: "string", Enum: operationStatuses}},
+			{Name: "limit", In: "query", Description: "Return at most this many, up to 500", Schema: &Schema{Type: "integer"}},
+		},
+		response: []models.Operation{}, errors: []int{http.StatusBadRequest}},
+	{method: "GET", path: "/operations/{id}", id: "getOperation", tag: "background", summary: "Get the progress and outcome of an operation", auth: authBearer,
+		response: models.Operation{}, errors: []int{http.StatusNotFound}},
+	{method: "POST", path: "/operations/{id}/cancel", id: "cancelOperation", tag: "background", summary: "Cancel a pending or running operation", auth: authBearer,
+		response: models.Operation{}, async: true, errors: []int{http.StatusNotFound, http.StatusConflict}},
+
+	// Quotas
+	{method: "GET", path: "/usage", id: "getUsage", tag: "quotas", summary: "Show the quotas of you and your teams next to what they use", auth: authBearer,
+		response: services.Usage{}},
+
+	// Subnets
+	{method: "GET", path: "/subnets", id: "listSubnets", tag: "subnets", summary: "List subnets", auth: authBearer,
+		response: []models.Subnet{}},
+
+	// Administration
+	{method: "GET", path: "/admin/port-ranges", id: "listPortRanges", tag: "admin", summary: "List port ranges", auth: authAdmin,
+		response: []models.PortRange{}},
+	{method: "POST", path: "/admin/port-ranges", id: "createPortRange", tag: "admin", summary: "Add a port range", auth: authAdmin,
+		body: models.PortRange{}, response: models.PortRange{}, errors: []int{http.StatusBadRequest}},
+	{method: "DELETE", path: "/admin/port-ranges/{id}", id: "deletePortRange", tag: "admin", summary: "Delete a port range", auth: authAdmin,
+		response: message{}, errors: []int{http.StatusNotFound}},
+	{method: "GET", path: "/admin/port-reservations", id: "listPortReservations", tag: "admin", summary: "List reserved ports", auth: authAdmin,
+		response: []models.PortReservation{}},
+	{method: "POST", path: "/admin/subnets", id: "createSubnet", tag: "admin", summary: "Add a subnet", auth: authAdmin,
+		body: models.Subnet{}, response: models.Subnet{}, errors: []int{http.StatusBadRequest, http.StatusConflict}},
+	{method: "DELETE", path: "/admin/subnets/{id}", id: "deleteSubnet", tag: "admin", summary: "Delete a subnet", auth: authAdmin,
+		response: message{}, errors: []int{http.StatusNotFound}},
+	{method: "GET", path: "/admin/subnets/utilization", id: "listSubnetUtilization", tag: "admin", summary: "Report the usage of every subnet", auth: authAdmin,
+		response: []services.SubnetUtilization{}},
+	{method: "GET", path: "/admin/subnets/{id}/utilization", id: "getSubnetUtilization", tag: "admin", summary: "Report the usage of a subnet", auth: authAdmin,
+		response: services.SubnetUtilization{}, errors: []int{http.StatusNotFound}},
+	{method: "GET", path: "/admin/websocket/stats", id: "getWebSocketStats", tag: "admin", summary: "Get WebSocket connection statistics", auth: authAdmin,
+		response: websocket.Stats{}},
+	{method: "GET", path: "/admin/log-level", id: "getLogLevels", tag: "admin", summary: "Get the log levels in effect", auth: authAdmin,
+		response: logging.Levels{}},
+	{method: "PUT", path: "/admin/log-level", id: "setLogLevel", tag: "admin", summary: "Change the log level of the application or of one component", auth: authAdmin,
+		body: logLevelChange{}, response: logging.Levels{}, errors: []int{http.StatusBadRequest}},
+	{method: "GET", path: "/admin/quotas", id: "listQuotas", tag: "quotas", summary: "List the default, user and team quotas", auth: authAdmin,
+		response: []models.Quota{}},
+	{method: "PUT", path: "/admin/quotas/default", id: "setDefaultQuota", tag: "quotas", summary: "Set the quota of everyone without a quota of their own", auth: authAdmin,
+		body: quotaLimits{}, bodyNote: quotaNote, response: models.Quota{}, errors: []int{http.StatusBadRequest}},
+	{method: "DELETE", path: "/admin/quotas/default", id: "deleteDefaultQuota", tag: "quotas", summary: "Remove the default quota", auth: authAdmin,
+		response: message{}, errors: []int{http.StatusNotFound}},
+	{method: "PUT", path: "/admin/quotas/users/{id}", id: "setUserQuota", tag: "quotas", summary: "Set the quota of the nodes a user owns outside any team", auth: authAdmin,
+		body: quotaLimits{}, bodyNote: quotaNote, response: models.Quota{}, errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+	{method: "DELETE", path: "/admin/quotas/users/{id}", id: "deleteUserQuota", tag: "quotas", summary: "Remove the quota of a user", auth: authAdmin,
+		response: message{}, errors: []int{http.StatusNotFound}},
+	{method: "PUT", path: "/admin/quotas/teams/{id}", id: "setTeamQuota", tag: "quotas", summary: "Set the quota of the nodes a team owns", auth: authAdmin,
+		body: quotaLimits{}, bodyNote: quotaNote, response: models.Quota{}, errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+	{method: "DELETE", path: "/admin/quotas/teams/{id}", id: "deleteTeamQuota", tag: "quotas", summary: "Remove the quota of a team", auth: authAdmin,
+		response: message{}, errors: []int{http.StatusNotFound}},
+
+	// Manifests
+	{method: "POST", path: "/apply", id: "applyManifest", tag: "manifests", summary: "Converge the user's nodes on a manifest", auth: authBearer,
+		params: []Parameter{
+			{Name: "prune", In: "query", Description: "Delete nodes missing from the manifest", Schema: &Schema{Type: "boolean"}},
+			dryRun,
+			{Name: "output", In: "query", Description: "Set to text for a human readable plan", Schema: &Schema{Type: "string", Enum: []string{"text"}}},
+		},
+		body: services.Manifest{}, bodyTypes: []string{"application/yaml", "application/json"},
+		response: services.ApplyResult{}, responseType: "application/json,text/plain",
+		errors: []int{http.StatusBadRequest, http.StatusForbidden, http.StatusConflict, http.StatusPreconditionFailed}},
+
+	// Events
+	{method: "GET", path: "/events", id: "streamEvents", tag: "events", summary: "Stream events as Server-Sent Events", auth: authStream,
+		params: []Parameter{
+			{Name: "nodes", In: "query", Description: "Comma separated node IDs to receive events of", Schema: &Schema{Type: "string"}},
+			{Name: "groups", In: "query", Description: "Comma separated node groups, set by manifests, to receive events of", Schema: &Schema{Type: "string"}},
+			{Name: "events", In: "query", Description: "Comma separated event types to receive", Schema: &Schema{Type: "string"}},
+			{Name: "Last-Event-ID", In: "header", Description: "Resume after this event", Schema: &Schema{Type: "string"}},
+			{Name: "last_event_id", In: "query", Description: "Resume after this event, for clients that cannot set headers", Schema: &Schema{Type: "string"}},
+		},
+		response: events.Event{}, responseType: "text/event-stream", errors: []int{http.StatusBadRequest}},
+	{method: "GET", path: "/ws", id: "openWebSocket", tag: "events", summary: "Receive events over a WebSocket", auth: authStream,
+		params: []Parameter{{Name: "since", In: "query", Description: "Replay the events after this ID before live ones", Schema: &Schema{Type: "integer", Format: "int64"}}},
+		status: http.StatusSwitchingProtocols, errors: []int{http.StatusBadRequest}},
+
+	// Operations
+	{method: "GET", path: "/metrics", unversioned: true, id: "getMetrics", tag: "operations", summary: "Prometheus metrics", auth: authMetrics,
+		response: "", responseType: "text/plain"},
+	{method: "GET", path: "/ping", unversioned: true, id: "ping", tag: "operations", summary: "Liveness probe",
+		response: "", responseType: "text/plain"},
+	{method: "GET", path: "/openapi.json", unversioned: true, id: "getOpenAPI", tag: "operations", summary: "This document",
+		response: map[string]interface{}{}},
+	{method: "GET", path: "/docs", unversioned: true, id: "getDocs", tag: "operations", summary: "Interactive API documentation",
+		response: "", responseType: "text/html"},
+}
@@ -0,0 +1,236 @@
+package openapi_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"node_management_application/openapi"
+	"node_management_application/testutil"
+)
+
+func TestDocumentCoversRoutes(t *testing.T) {
+	app := testutil.NewApp()
+	if err := openapi.Verify(app.GetRoutes()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestResponsesMatchDocument(t *testing.T) {
+	testutil.UseDatabase(t)
+	app := testutil.NewApp()
+	if err := app.Build(); err != nil {
+		t.Fatalf("build app: %v", err)
+	}
+	server := httptest.NewServer(app)
+	defer server.Close()
+
+	api := &apiClient{t: t, base: server.URL, doc: openapi.Spec()}
+
+	api.check("POST", "/api/v1/register", "/api/v1/register", map[string]string{
+		"name": "Ada", "email": "ada@example.com", "password": "correct horse battery",
+	}, http.StatusOK)
+	var login struct {
+		Token string `json:"token"`
+		User  struct {
+			ID uint `json:"id"`
+		} `json:"user"`
+	}
+	api.decode(api.check("POST", "/api/v1/login", "/api/v1/login", map[string]string{
+		"email": "ada@example.com", "password": "correct horse battery",
+	}, http.StatusOK), &login)
+	api.token = login.Token
+
+	api.check("GET", "/api/v1/users/profile", "/api/v1/users/profile", nil, http.StatusOK)
+
+	var node struct {
+		ID uint `json:"ID"`
+	}
+	api.decode(api.check("POST", "/api/v1/nodes", "/api/v1/nodes", map[string]interface{}{
+		"Name": "alpha", "IP": "127.0.0.1", "Port": 18080, "Location": "lab",
+	}, http.StatusOK), &node)
+
+	api.check("GET", fmt.Sprintf("/api/v1/nodes/%d", node.ID), "/api/v1/nodes/{id}", nil, http.StatusOK)
+	api.check("GET", "/api/v1/nodes", "/api/v1/nodes", nil, http.StatusOK)
+	api.check("GET", "/api/v1/usage", "/api/v1/usage", nil, http.StatusOK)
+
+	// Errors are problem documents
+	api.check("GET", "/api/v1/nodes/999", "/api/v1/nodes/{id}", nil, http.StatusNotFound)
+	other := fmt.Sprintf("/api/v1/users/%d", login.User.ID+1)
+	api.check("PATCH", other, "/api/v1/users/{id}", map[string]string{"name": "Eve"}, http.StatusForbidden)
+	api.check("PUT", other, "/api/v1/users/{id}", map[string]string{"name": "Eve", "email": "eve@example.com"}, http.StatusForbidden)
+	api.check("DELETE", other, "/api/v1/users/{id}", nil, http.StatusForbidden)
+}
+
+// apiClient calls the test server and checks each response against the document
+type apiClient struct {
+	t     *testing.T
+	base  string
+	token string
+	doc   *openapi.Document
+}
+
+// check sends a request, expects the status and checks the body against the schema documented
+// for that status of the operation at template
+func (c *apiClient) check(method, path, template string, body interface{}, status int) []byte {
+	c.t.Helper()
+
+	var reader *bytes.Reader
+	if body == nil {
+		reader = bytes.NewReader(nil)
+	} else {
+		data, err := json.Marshal(body)
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	request, err := http.NewRequest(method, c.base+path, reader)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if method == http.MethodPatch {
+		request.Header.Set("Content-Type", "application/merge-patch+json")
+	}
+	if c.token != "" {
+		request.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		c.t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer response.Body.Close()
+	var buf bytes.Buffer
+	buf.ReadFrom(response.Body)
+	if response.StatusCode != status {
+		c.t.Fatalf("%s %s: status %d, want %d: %s", method, path, response.StatusCode, status, buf.String())
+	}
+
+	operation, ok := c.doc.Paths[template][strings.ToLower(method)]
+	if !ok {
+		c.t.Fatalf("%s %s is not documented", method, template)
+	}
+	documented, ok := operation.Responses[fmt.Sprint(status)]
+	if !ok {
+		c.t.Fatalf("%s %s: status %d is not documented", method, template, status)
+	}
+	contentType := strings.TrimSpace(strings.Split(response.Header.Get("Content-Type"), ";")[0])
+	media, ok := documented.Content[contentType]
+	if !ok {
+		c.t.Fatalf("%s %s: content type %q is not documented for status %d", method, template, contentType, status)
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(buf.Bytes(), &value); err != nil {
+		c.t.Fatalf("%s %s: invalid JSON: %v", method, path, err)
+	}
+	if problems := conforms(c.doc, media.Schema, value, "body", true); len(problems) > 0 {
+		c.t.Errorf("%s %s does not match its documented %d response:\n  %s", method, path, status, strings.Join(problems, "\n  "))
+	}
+	return buf.Bytes()
+}
+
+func (c *apiClient) decode(data []byte, into interface{}) {
+	c.t.Helper()
+	if err := json.Unmarshal(data, into); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// conforms lists the ways a decoded JSON value differs from the schema: missing required
+// members, undocumented members and scalars of the wrong type
+func conforms(doc *openapi.Document, schema *openapi.Schema, value interface{}, at string, required bool) []string {
+	if schema == nil {
+		return nil
+	}
+	if schema.Ref != "" {
+		name := strings.TrimPrefix(schema.Ref, "#/components/schemas/")
+		resolved, ok := doc.Components.Schemas[name]
+		if !ok {
+			return []string{at + ": unknown schema " + schema.Ref}
+		}
+		schema = resolved
+	}
+
+	if value == nil {
+		// Go encodes nil pointers, slices and maps as null
+		if !required || schema.Nullable || schema.Type == "array" || schema.AdditionalProperties != nil {
+			return nil
+		}
+		return []string{at + ": null where " + schema.Type + " is required"}
+	}
+
+	var problems []string
+	switch schema.Type {
+	case "":
+		// Undescribed values, such as interface{} fields, may hold anything
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: %T where object is documented", at, value)}
+		}
+		for _, name := range schema.Required {
+			if _, ok := object[name]; !ok {
+				problems = append(problems, at+"."+name+": required member is missing")
+			}
+		}
+		names := make([]string, 0, len(object))
+		for name := range object {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			property, ok := schema.Properties[name]
+			if !ok {
+				if schema.AdditionalProperties == nil {
+					problems = append(problems, at+"."+name+": member is not documented")
+					continue
+				}
+				property = schema.AdditionalProperties
+			}
+			problems = append(problems, conforms(doc, property, object[name], at+"."+name, contains(schema.Required, name))...)
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: %T where array is documented", at, value)}
+		}
+		for i, item := range items {
+			problems = append(problems, conforms(doc, schema.Items, item, fmt.Sprintf("%s[%d]", at, i), true)...)
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			problems = append(problems, fmt.Sprintf("%s: %T where string is documented", at, value))
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			problems = append(problems, fmt.Sprintf("%s: %T where boolean is documented", at, value))
+		}
+	case "integer":
+		if number, ok := value.(float64); !ok || number != math.Trunc(number) {
+			problems = append(problems, fmt.Sprintf("%s: %v where integer is documented", at, value))
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			problems = append(problems, fmt.Sprintf("%s: %T where number is documented", at, value))
+		}
+	}
+	return problems
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
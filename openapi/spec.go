@@ -0,0 +1,298 @@
+package openapi
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"node_management_application/models"
+	"node_management_application/services"
+	"node_management_application/utils"
+)
+
+// Document is an OpenAPI 3.0 document
+type Document struct {
+	OpenAPI    string                          `json:"openapi"`
+	Info       Info                            `json:"info"`
+	Tags       []Tag                           `json:"tags,omitempty"`
+	Paths      map[string]map[string]Operation `json:"paths"`
+	Components Components                      `json:"components"`
+}
+
+// Info describes the API
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Tag groups operations in the documentation
+type Tag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// Components holds the schemas and security schemes operations refer to
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes"`
+}
+
+// SecurityScheme describes how a client authenticates
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	Name         string `json:"name,omitempty"`
+	In           string `json:"in,omitempty"`
+	Description  string `json:"description,omitempty"`
+}
+
+// Operation is a single method on a path
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+// Parameter is a path, query or header parameter
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody lists the accepted media types of a request
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response describes one status code of an operation
+type Response struct {
+	Description string               `json:"description"`
+	Headers     map[string]Header    `json:"headers,omitempty"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// Header is a response header
+type Header struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// MediaType is the schema of one content type
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+// Ways an endpoint authenticates its caller
+const (
+	authNone    = ""
+	authBearer  = "bearer"
+	authAdmin   = "admin"
+	authStream  = "stream"
+	authMetrics = "metrics"
+)
+
+// endpoint is the description of one route; the document is generated from the endpoints table
+type endpoint struct {
+	method  string
+	path    string // OpenAPI template relative to the version prefix, e.g. /nodes/{id}
+	id      string
+	tag     string
+	summary string
+	auth    string
+
+	unversioned bool // Served at the root instead of under the version prefix
+
+	params    []Parameter
+	body      interface{} // Zero value of the type the handler reads
+	bodyTypes []string    // Defaults to application/json
+	bodyNote  string
+
+	status       int         // Defaults to 200
+	response     interface{} // Zero value of the type the handler writes
+	responseType string      // Defaults to application/json
+	etag         bool        // Response carries the node's ETag
+	async        bool        // Also answers 202 with the operation running the request in the background
+	errors       []int
+}
+
+// basePath is the prefix the documented version of the API is served under
+const basePath = "/api/v1"
+
+var pathParamPattern = regexp.MustCompile(`\{(\w+)\}`)
+
+var (
+	documentOnce sync.Once
+	document     *Document
+)
+
+// Spec returns the OpenAPI document of the API
+func Spec() *Document {
+	documentOnce.Do(func() {
+		document = build(endpoints)
+	})
+	return document
+}
+
+func build(endpoints []endpoint) *Document {
+	schemas := newRegistry()
+	problem := schemas.schemaOf(utils.Problem{})
+
+	doc := &Document{
+		OpenAPI: "3.0.3",
+		Info: Info{
+			Title:   "Node Management API",
+			Version: "1.0.0",
+			Description: "Manage node servers, their addresses and health. Errors are RFC 7807 problem documents " +
+				"(" + utils.ProblemContentType + ") with a stable `code` and the `correlation_id` of the request. " +
+				"Every " + basePath + " path is also served without the prefix for older clients; those aliases are " +
+				"deprecated and answer with Deprecation, Sunset and Link headers.",
+		},
+		Tags:  tags,
+		Paths: map[string]map[string]Operation{},
+		Components: Components{
+			SecuritySchemes: map[string]SecurityScheme{
+				"bearerAuth": {Type: "http", Scheme: "bearer", BearerFormat: "JWT", Description: "Token returned by POST /login"},
+				"accessToken": {Type: "apiKey", In: "query", Name: "access_token",
+					Description: "The login token as a query parameter, for clients that cannot set headers on streams"},
+				"metricsToken": {Type: "http", Scheme: "bearer", Description: "The METRICS_TOKEN of the server, when configured"},
+			},
+		},
+	}
+
+	for _, e := range endpoints {
+		op := Operation{
+			OperationID: e.id,
+			Summary:     e.summary,
+			Tags:        []string{e.tag},
+			Responses:   map[string]Response{},
+		}
+
+		for _, match := range pathParamPattern.FindAllStringSubmatch(e.path, -1) {
+			op.Parameters = append(op.Parameters, Parameter{Name: match[1], In: "path", Required: true, Schema: &Schema{Type: "integer", Format: "int64"}})
+		}
+		op.Parameters = append(op.Parameters, e.params...)
+		if e.acceptsIdempotencyKey() {
+			op.Parameters = append(op.Parameters, idempotencyKey)
+		}
+
+		if e.body != nil {
+			op.RequestBody = &RequestBody{Required: true, Content: map[string]MediaType{}}
+			bodyTypes := e.bodyTypes
+			if len(bodyTypes) == 0 {
+				bodyTypes = []string{"application/json"}
+			}
+			for _, contentType := range bodyTypes {
+				op.RequestBody.Content[contentType] = MediaType{Schema: bodySchema(schemas, contentType, e.body)}
+			}
+			op.Description = e.bodyNote
+		}
+
+		status := e.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		response := Response{Description: http.StatusText(status)}
+		if e.response != nil {
+			responseType := e.responseType
+			if responseType == "" {
+				responseType = "application/json"
+			}
+			response.Content = map[string]MediaType{}
+			for _, contentType := range strings.Split(responseType, ",") {
+				response.Content[contentType] = MediaType{Schema: bodySchema(schemas, contentType, e.response)}
+			}
+		}
+		if e.etag {
+			response.Headers = map[string]Header{"ETag": {Description: "Version of the node, for If-Match and If-None-Match", Schema: &Schema{Type: "string"}}}
+		}
+		op.Responses[strconv.Itoa(status)] = response
+		if e.async {
+			op.Responses[strconv.Itoa(http.StatusAccepted)] = Response{
+				Description: http.StatusText(http.StatusAccepted),
+				Headers:     map[string]Header{"Location": {Description: "URL to poll for the progress of the operation", Schema: &Schema{Type: "string"}}},
+				Content:     map[string]MediaType{"application/json": {Schema: schemas.schemaOf(models.Operation{})}},
+			}
+		}
+
+		errors := append([]int{}, e.errors...)
+		switch e.auth {
+		case authBearer:
+			op.Security = []map[string][]string{{"bearerAuth": {}}}
+			errors = append(errors, http.StatusUnauthorized)
+		case authAdmin:
+			op.Security = []map[string][]string{{"bearerAuth": {}}}
+			errors = append(errors, http.StatusUnauthorized, http.StatusForbidden)
+		case authStream:
+			op.Security = []map[string][]string{{"bearerAuth": {}}, {"accessToken": {}}}
+			errors = append(errors, http.StatusUnauthorized)
+		case authMetrics:
+			op.Security = []map[string][]string{{"metricsToken": {}}, {}}
+			errors = append(errors, http.StatusUnauthorized)
+		}
+		if e.acceptsIdempotencyKey() {
+			errors = append(errors, http.StatusConflict, http.StatusUnprocessableEntity)
+		}
+		errors = append(errors, http.StatusInternalServerError)
+		for _, code := range errors {
+			op.Responses[strconv.Itoa(code)] = Response{
+				Description: http.StatusText(code),
+				Content:     map[string]MediaType{utils.ProblemContentType: {Schema: problem}},
+			}
+		}
+
+		path := e.fullPath()
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = map[string]Operation{}
+		}
+		doc.Paths[path][strings.ToLower(e.method)] = op
+	}
+
+	doc.Components.Schemas = schemas.schemas
+	return doc
+}
+
+// acceptsIdempotencyKey reports whether the endpoint runs behind the Idempotency middleware,
+// which covers the mutating authenticated routes
+func (e endpoint) acceptsIdempotencyKey() bool {
+	if e.auth != authBearer && e.auth != authAdmin {
+		return false
+	}
+	switch e.method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// fullPath is the path the endpoint is served at
+func (e endpoint) fullPath() string {
+	if e.unversioned {
+		return e.path
+	}
+	return basePath + e.path
+}
+
+// bodySchema documents JSON Patch bodies as operation lists and text media types as plain strings
+func bodySchema(schemas *registry, contentType string, value interface{}) *Schema {
+	switch {
+	case contentType == services.JSONPatchContentType:
+		return schemas.schemaOf([]jsonPatchOperation{})
+	case strings.HasPrefix(contentType, "text/"):
+		return &Schema{Type: "string"}
+	default:
+		return schemas.schemaOf(value)
+	}
+}
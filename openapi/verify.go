@@ -0,0 +1,63 @@
+package openapi
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/kataras/iris/v12/core/router"
+)
+
+// irisParamPattern matches a path parameter in the form Iris reports registered routes in, e.g. :id
+var irisParamPattern = regexp.MustCompile(`:(\w+)`)
+
+// Verify compares the registered routes with the documented endpoints and reports every difference,
+// so a route added, removed or moved without updating the document is caught by the tests and
+// logged at startup.
+// The deprecated unprefixed aliases of versioned endpoints are accepted without being documented.
+func Verify(routes []*router.Route) error {
+	registered := map[string]bool{}
+	for _, route := range routes {
+		if route.Method == "OPTIONS" || route.Method == "HEAD" {
+			continue
+		}
+		registered[route.Method+" "+templatePath(route.Path)] = true
+	}
+
+	documented := map[string]bool{}
+	aliases := map[string]bool{}
+	for _, e := range endpoints {
+		documented[e.method+" "+e.fullPath()] = true
+		if !e.unversioned {
+			aliases[e.method+" "+e.path] = true
+		}
+	}
+
+	var problems []string
+	for key := range registered {
+		if !documented[key] && !aliases[key] {
+			problems = append(problems, "undocumented route "+key)
+		}
+	}
+	for key := range documented {
+		if !registered[key] {
+			problems = append(problems, "documented route "+key+" is not registered")
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+
+	sort.Strings(problems)
+	return fmt.Errorf("OpenAPI document is out of date: %s", strings.Join(problems, "; "))
+}
+
+// templatePath converts an Iris route path to an OpenAPI path template
+func templatePath(path string) string {
+	path = irisParamPattern.ReplaceAllString(path, "{$1}")
+	if len(path) > 1 {
+		path = strings.TrimSuffix(path, "/")
+	}
+	return path
+}
@@ -0,0 +1,32 @@
+package routes
+
+import (
+	"node_management_application/openapi"
+
+	"github.com/kataras/iris/v12"
+)
+
+// docsPage renders /openapi.json with Redoc
+const docsPage = `<!DOCTYPE html>
+<html>
+<head>
+  <title>Node Management API</title>
+  <meta charset="utf-8"/>
+  <meta name="viewport" content="width=device-width, initial-scale=1">
+</head>
+<body>
+  <redoc spec-url="/openapi.json"></redoc>
+  <script src="https://cdn.redoc.ly/redoc/latest/bundles/redoc.standalone.js"></script>
+</body>
+</html>
+`
+
+// RegisterDocsRoutes serves the OpenAPI document and a browsable rendering of it
+func RegisterDocsRoutes(app *iris.Application) {
+	app.Get("/openapi.json", func(ctx iris.Context) {
+		ctx.JSON(openapi.Spec())
+	})
+	app.Get("/docs", func(ctx iris.Context) {
+		ctx.HTML(docsPage)
+	})
+}
@@ -0,0 +1,160 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"node_management_application/events"
+	"node_management_application/logging"
+	"node_management_application/middlewares"
+	"node_management_application/utils"
+	"node_management_application/websocket"
+
+	"github.com/kataras/iris/v12"
+)
+
+var streamLogger = logging.For("sse")
+
+const (
+	keepAliveInterval = 15 * time.Second // Comment lines keep proxies from closing idle streams
+	streamQueueSize   = 256              // Events buffered per stream before new ones are dropped
+)
+
+// EventStreamHandler streams the user's events as Server-Sent Events.
+// ?nodes=1,2, ?groups=web and ?events=node.started,node.stopped narrow the stream like a
+// WebSocket subscription, and Last-Event-ID (or ?last_event_id=) replays what was missed
+// since a disconnect.
+func EventStreamHandler(ctx iris.Context) {
+	// Set by middlewares.AuthenticateStream
+	filter := events.NewFilter(ctx.Values().GetUintDefault("user_id", 0))
+	for _, value := range splitParam(ctx.URLParam("nodes")) {
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			utils.ProblemResponse(ctx, http.StatusBadRequest, utils.CodeInvalidRequest, "nodes must be a comma separated list of node IDs")
+			return
+		}
+		filter.Nodes[uint(id)] = true
+	}
+	for _, value := range splitParam(ctx.URLParam("groups")) {
+		filter.Groups[value] = true
+	}
+	for _, value := range splitParam(ctx.URLParam("events")) {
+		filter.Types[events.Type(value)] = true
+	}
+
+	lastEventID := ctx.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = ctx.URLParam("last_event_id")
+	}
+	var since uint64
+	resume := lastEventID != ""
+	if resume {
+		var err error
+		if since, err = strconv.ParseUint(lastEventID, 10, 64); err != nil {
+			utils.ProblemResponse(ctx, http.StatusBadRequest, utils.CodeInvalidRequest, "Last-Event-ID must be an event ID")
+			return
+		}
+	}
+
+	// Subscribe before replaying so no event falls between the replay and the live stream
+	live := make(chan events.Event, streamQueueSize)
+	unsubscribe := events.Subscribe("sse", func(event events.Event) {
+		if !filter.Matches(event) {
+			return
+		}
+		select {
+		case live <- event:
+		default:
+			websocket.CountDropped()
+			streamLogger.Warn("Event stream queue is full; dropping event", "user_id", filter.UserID, "event_id", event.ID)
+		}
+	})
+	defer unsubscribe()
+
+	ctx.ContentType("text/event-stream")
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("Connection", "keep-alive")
+	ctx.Header("X-Accel-Buffering", "no") // Disable buffering in nginx
+	ctx.StatusCode(http.StatusOK)
+
+	writer := ctx.ResponseWriter()
+	fmt.Fprintf(writer, "retry: %d\n\n", (5 * time.Second).Milliseconds())
+
+	last := since
+	if resume {
+		missed, err := events.Since(since, filter.UserID)
+		switch {
+		case err == events.ErrResyncRequired:
+			last = events.LatestID()
+			writeStreamMessage(writer, 0, "resync_required", iris.Map{"latest_id": last})
+		case err != nil:
+			streamLogger.ErrorContext(ctx.Request().Context(), "Failed to replay events", "user_id", filter.UserID, "error", err)
+			writeStreamMessage(writer, 0, "error", iris.Map{"error": "failed to replay events"})
+		default:
+			for _, event := range missed {
+				if filter.Matches(event) {
+					writeStreamMessage(writer, event.ID, string(event.Type), event)
+				}
+				last = event.ID
+			}
+		}
+	}
+	writer.Flush()
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-ctx.Request().Context().Done():
+			return
+		case event := <-live:
+			// Skip live events that were already part of the replay
+			if event.ID <= last {
+				continue
+			}
+			last = event.ID
+			writeStreamMessage(writer, event.ID, string(event.Type), event)
+			writer.Flush()
+		case <-keepAlive.C:
+			fmt.Fprint(writer, ": keepalive\n\n")
+			writer.Flush()
+		}
+	}
+}
+
+// writeStreamMessage writes one Server-Sent Events message; an id of 0 is omitted
+func writeStreamMessage(writer http.ResponseWriter, id uint64, event string, payload interface{}) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		streamLogger.Error("Failed to encode event stream message", "error", err)
+		return
+	}
+
+	if id != 0 {
+		fmt.Fprintf(writer, "id: %d\n", id)
+	}
+	fmt.Fprintf(writer, "event: %s\ndata: %s\n\n", event, data)
+}
+
+// splitParam splits a comma separated query parameter, dropping empty values
+func splitParam(value string) []string {
+	var values []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			values = append(values, item)
+		}
+	}
+	return values
+}
+
+// RegisterEventStreamRoute registers the Server-Sent Events route
+func RegisterEventStreamRoute(app *iris.Application) {
+	for _, api := range apiParties(app) {
+		api.Get("/events", middlewares.AuthenticateStream, EventStreamHandler)
+	}
+}
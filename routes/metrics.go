@@ -0,0 +1,15 @@
+package routes
+
+import (
+	"node_management_application/metrics"
+	"node_management_application/middlewares"
+
+	"github.com/kataras/iris/v12"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// RegisterMetricsRoute instruments every request and exposes /metrics in Prometheus text format
+func RegisterMetricsRoute(app *iris.Application) {
+	app.UseGlobal(metrics.Middleware)
+	app.Get("/metrics", middlewares.RequireMetricsToken, iris.FromStd(promhttp.Handler()))
+}
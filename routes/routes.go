@@ -1,6 +1,7 @@
 package routes
 
 import (
+	"node_management_application/config"
 	"node_management_application/controllers"
 	"node_management_application/middlewares"
 
@@ -9,46 +10,115 @@ import (
 )
 
 func RegisterRoutes(app *iris.Application) {
-    // Enable CORS middleware
+	// Enable CORS middleware
 	corsMiddleware := cors.New(cors.Options{
-		AllowedOrigins:   []string{"http://localhost:8081"}, // Replace with the frontend's origin
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE"},
-		AllowedHeaders:   []string{"Content-Type", "Authorization"},
+		AllowedOrigins:   config.AllowedOrigins,
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+		AllowedHeaders:   []string{"Content-Type", "Authorization", "If-Match", "If-None-Match", "Last-Event-ID", "X-Request-ID", "Idempotency-Key", "Prefer"},
+		ExposedHeaders:   []string{"ETag", "X-Request-ID", "Deprecation", "Sunset", "Link", "Idempotent-Replayed", "Location", "Preference-Applied"},
 		AllowCredentials: true,
 	})
 
 	app.UseRouter(corsMiddleware)
 
-    // Authentication routes
-    app.Post("/register", controllers.RegisterUser)
-	app.Post("/login", controllers.Login)
-
-
-	 // User routes
-     userAPI := app.Party("/users",middlewares.Authenticate)
-     {
-        userAPI.Get("/", controllers.GetUsers)
-        // userAPI.Post("/", controllers.CreateUser)
-        userAPI.Put("/{id:uint}", controllers.UpdateUser)   // Update user
-        userAPI.Delete("/{id:uint}", controllers.DeleteUser) // Delete user
-        // userAPI.Get("/{userId:uint}/nodes", controllers.GetUserNodes) // Get User nodes
-        userAPI.Get("/profile", controllers.GetUserProfile)
-
-     }
- 
-     // Node routes
-     nodeAPI := app.Party("/nodes",middlewares.Authenticate)
-     {
-        nodeAPI.Get("/", controllers.GetNodes)
-        nodeAPI.Post("/", controllers.CreateNode)
-         nodeAPI.Put("/{id:uint}", controllers.UpdateNode)
-         nodeAPI.Delete("/{id:uint}", controllers.DeleteNode)
-         nodeAPI.Post("/{id:uint}/start", controllers.StartNode)
-         nodeAPI.Post("/{id:uint}/stop", controllers.StopNode)
-         nodeAPI.Get("/{id:uint}/health", controllers.HealthCheck)
-     }
-     
-    app.Get("/ping", func(ctx iris.Context) {
-        ctx.WriteString("pong")
-    })
+	// Mount the API under every version prefix, and at the root for older clients
+	for _, api := range apiParties(app) {
+		registerAPIRoutes(api)
+	}
+
+	app.Get("/ping", func(ctx iris.Context) {
+		ctx.WriteString("pong")
+	})
+}
+
+// registerAPIRoutes registers the versioned API on a version's party
+func registerAPIRoutes(api iris.Party) {
+	// Authentication routes
+	api.Post("/register", controllers.RegisterUser)
+	api.Post("/login", controllers.Login)
+
+	// User routes
+	userAPI := api.Party("/users", middlewares.Authenticate, middlewares.Idempotency)
+	{
+		userAPI.Get("/", controllers.GetUsers)
+		// userAPI.Post("/", controllers.CreateUser)
+		userAPI.Put("/{id:uint}", controllers.UpdateUser)    // Update user
+		userAPI.Patch("/{id:uint}", controllers.PatchUser)   // Partially update user
+		userAPI.Delete("/{id:uint}", controllers.DeleteUser) // Delete user
+		// userAPI.Get("/{userId:uint}/nodes", controllers.GetUserNodes) // Get User nodes
+		userAPI.Get("/profile", controllers.GetUserProfile)
+
+	}
+
+	// Node routes
+	nodeAPI := api.Party("/nodes", middlewares.Authenticate, middlewares.Idempotency)
+	{
+		nodeAPI.Get("/", controllers.GetNodes)
+		nodeAPI.Post("/", controllers.CreateNode)
+		nodeAPI.Post("/import", controllers.ImportNodes)
+		nodeAPI.Get("/export", controllers.ExportNodes)
+		nodeAPI.Get("/{id:uint}", controllers.GetNode)
+		nodeAPI.Put("/{id:uint}", controllers.UpdateNode)
+		nodeAPI.Patch("/{id:uint}", controllers.PatchNode)
+		nodeAPI.Delete("/{id:uint}", controllers.DeleteNode)
+		nodeAPI.Post("/{id:uint}/start", controllers.StartNode)
+		nodeAPI.Post("/{id:uint}/stop", controllers.StopNode)
+		nodeAPI.Get("/{id:uint}/health", controllers.HealthCheck)
+		nodeAPI.Get("/{id:uint}/stats", controllers.GetNodeStats)
+		nodeAPI.Get("/{id:uint}/grants", controllers.GetNodeGrants)
+		nodeAPI.Put("/{id:uint}/grants/{userId:uint}", controllers.GrantNodeAccess)
+		nodeAPI.Delete("/{id:uint}/grants/{userId:uint}", controllers.RevokeNodeAccess)
+		nodeAPI.Post("/{id:uint}/transfer", controllers.TransferNode)
+	}
+
+	// Teams share the nodes they own among their members
+	teamAPI := api.Party("/teams", middlewares.Authenticate, middlewares.Idempotency)
+	{
+		teamAPI.Get("/", controllers.GetTeams)
+		teamAPI.Post("/", controllers.CreateTeam)
+		teamAPI.Get("/{id:uint}", controllers.GetTeam)
+		teamAPI.Delete("/{id:uint}", controllers.DeleteTeam)
+		teamAPI.Put("/{id:uint}/members/{userId:uint}", controllers.SetTeamMember)
+		teamAPI.Delete("/{id:uint}/members/{userId:uint}", controllers.RemoveTeamMember)
+	}
+
+	// Background operations started by node actions
+	operationAPI := api.Party("/operations", middlewares.Authenticate, middlewares.Idempotency)
+	{
+		operationAPI.Get("/", controllers.GetOperations)
+		operationAPI.Get("/{id:uint}", controllers.GetOperation)
+		operationAPI.Post("/{id:uint}/cancel", controllers.CancelOperation)
+	}
+
+	// Quotas of the authenticated user and their teams, with what they use
+	api.Get("/usage", middlewares.Authenticate, controllers.GetUsage)
+
+	// Subnets are readable by every user so they can request addresses from them
+	api.Get("/subnets", middlewares.Authenticate, controllers.GetSubnets)
+
+	// Admin routes
+	adminAPI := api.Party("/admin", middlewares.Authenticate, middlewares.RequireAdmin, middlewares.Idempotency)
+	{
+		adminAPI.Get("/port-ranges", controllers.GetPortRanges)
+		adminAPI.Post("/port-ranges", controllers.CreatePortRange)
+		adminAPI.Delete("/port-ranges/{id:uint}", controllers.DeletePortRange)
+		adminAPI.Get("/port-reservations", controllers.GetPortReservations)
+		adminAPI.Post("/subnets", controllers.CreateSubnet)
+		adminAPI.Delete("/subnets/{id:uint}", controllers.DeleteSubnet)
+		adminAPI.Get("/subnets/utilization", controllers.GetSubnetsUtilization)
+		adminAPI.Get("/subnets/{id:uint}/utilization", controllers.GetSubnetUtilization)
+		adminAPI.Get("/websocket/stats", controllers.GetWebSocketStats)
+		adminAPI.Get("/log-level", controllers.GetLogLevels)
+		adminAPI.Put("/log-level", controllers.SetLogLevel)
+		adminAPI.Get("/quotas", controllers.GetQuotas)
+		adminAPI.Put("/quotas/default", controllers.SetDefaultQuota)
+		adminAPI.Delete("/quotas/default", controllers.DeleteDefaultQuota)
+		adminAPI.Put("/quotas/users/{id:uint}", controllers.SetUserQuota)
+		adminAPI.Delete("/quotas/users/{id:uint}", controllers.DeleteUserQuota)
+		adminAPI.Put("/quotas/teams/{id:uint}", controllers.SetTeamQuota)
+		adminAPI.Delete("/quotas/teams/{id:uint}", controllers.DeleteTeamQuota)
+	}
+
+	// Declarative fleet manifests
+	api.Post("/apply", middlewares.Authenticate, middlewares.Idempotency, controllers.ApplyManifest)
 }
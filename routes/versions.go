@@ -0,0 +1,43 @@
+package routes
+
+import (
+	"node_management_application/config"
+	"node_management_application/middlewares"
+
+	"github.com/kataras/iris/v12"
+)
+
+// APIv1Prefix is the path the first version of the API is served under
+const APIv1Prefix = "/api/v1"
+
+// apiMount is one place the API is served from. Versions share the controllers; a later
+// version, e.g. {prefix: "/api/v2", version: 2}, reuses them and the handlers whose
+// representation changes branch on utils.APIVersion instead of being copied.
+type apiMount struct {
+	prefix  string
+	version int
+
+	// successor is set on deprecated mounts and names the prefix that replaces them
+	successor string
+}
+
+var apiMounts = []apiMount{
+	{prefix: APIv1Prefix, version: 1},
+
+	// Unprefixed aliases for clients written before the API was versioned
+	{prefix: "/", version: 1, successor: APIv1Prefix},
+}
+
+// apiParties returns a party for every mount of the API, tagged with its version
+// and carrying deprecation headers where it has a successor
+func apiParties(app *iris.Application) []iris.Party {
+	parties := make([]iris.Party, 0, len(apiMounts))
+	for _, mount := range apiMounts {
+		handlers := []iris.Handler{middlewares.APIVersion(mount.version, mount.prefix)}
+		if mount.successor != "" {
+			handlers = append(handlers, middlewares.Deprecated(mount.successor, config.LegacyAPISunset))
+		}
+		parties = append(parties, app.Party(mount.prefix, handlers...))
+	}
+	return parties
+}
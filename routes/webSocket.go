@@ -1,47 +1,80 @@
 package routes
 
 import (
-	"log"
 	"net/http"
+	"node_management_application/config"
+	"node_management_application/logging"
+	"node_management_application/middlewares"
+	"node_management_application/utils"
 	websocket "node_management_application/websocket"
+	"strconv"
 
 	WebSocket "github.com/gorilla/websocket"
 
 	"github.com/kataras/iris/v12"
 )
 
+var wsLogger = logging.For("websocket")
+
 var upgrader = WebSocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		return true // Allow all origins for development (update for production)
-	},
+	CheckOrigin: checkOrigin,
+}
+
+// checkOrigin only lets browsers on the allowed frontend origins connect.
+// Requests without an Origin header come from non-browser clients and are allowed.
+func checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	for _, allowed := range config.AllowedOrigins {
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+	}
+	return false
 }
 
 // WebSocketHandler handles WebSocket connections
 func WebSocketHandler(ctx iris.Context) {
+	// Set by middlewares.AuthenticateStream before the upgrade
+	userID := ctx.Values().GetUintDefault("user_id", 0)
+
+	// Validate ?since= before upgrading so a bad value can still get an HTTP error
+	var since uint64
+	resume := ctx.URLParamExists("since")
+	if resume {
+		var err error
+		if since, err = strconv.ParseUint(ctx.URLParam("since"), 10, 64); err != nil {
+			utils.ProblemResponse(ctx, http.StatusBadRequest, utils.CodeInvalidRequest, "since must be an event ID")
+			return
+		}
+	}
+
 	conn, err := upgrader.Upgrade(ctx.ResponseWriter(), ctx.Request(), nil)
 	if err != nil {
-		log.Printf("Failed to upgrade connection: %v", err)
+		wsLogger.WarnContext(ctx.Request().Context(), "Failed to upgrade connection", "error", err)
 		return
 	}
 
 	// Add the client to the websocket package's client list
-	websocket.AddClient(conn)
-	defer websocket.RemoveClient(conn) // Ensure client removal on disconnect
+	client := websocket.AddClient(conn, userID, resume)
+	defer websocket.RemoveClient(client) // Ensure client removal on disconnect
 
-	log.Println("New WebSocket client connected")
+	wsLogger.DebugContext(ctx.Request().Context(), "New WebSocket client connected", "user_id", userID)
 
-	// Keep connection open by reading messages (or handling pings)
-	for {
-		_, _, err := conn.ReadMessage()
-		if err != nil {
-			log.Printf("Error reading WebSocket message: %v", err)
-			break
-		}
+	// Reconnecting clients pass the last event ID they saw to receive what they missed
+	if resume {
+		client.Resume(since)
 	}
-}
 
+	// Keep connection open by reading subscription messages and pongs
+	client.ReadMessages()
+}
 
 // RegisterWebSocketRoute registers the WebSocket route
 func RegisterWebSocketRoute(app *iris.Application) {
-	app.Get("/ws", WebSocketHandler)
+	for _, api := range apiParties(app) {
+		api.Get("/ws", middlewares.AuthenticateStream, WebSocketHandler)
+	}
 }
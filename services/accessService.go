@@ -0,0 +1,384 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"node_management_application/config"
+	"node_management_application/events"
+	"node_management_application/logging"
+	"node_management_application/models"
+
+	"gorm.io/gorm"
+)
+
+var accessLogger = logging.For("access")
+
+var (
+	// ErrAccessDenied is returned when a user can see a node but lacks the access level an action requires
+	ErrAccessDenied = errors.New("you do not have the access this requires")
+
+	// ErrUserNotFound is returned when a grant, membership or transfer names a user that does not exist
+	ErrUserNotFound = errors.New("user not found")
+
+	// ErrGrantNotFound is returned when revoking access a user was never granted
+	ErrGrantNotFound = errors.New("the user has no grant to this node")
+
+	// ErrUserHasNodes is returned when deleting a user who still owns nodes
+	ErrUserHasNodes = errors.New("user still owns nodes; transfer or delete them first")
+)
+
+// accessRank orders the access levels; a user's access is the highest of everything that grants it
+var accessRank = map[string]int{
+	models.AccessView:    1,
+	models.AccessOperate: 2,
+	models.AccessAdmin:   3,
+}
+
+// NodeTransfer names the new owner of a node, its new team, or both. A TeamID of 0 takes the
+// node out of its team.
+type NodeTransfer struct {
+	UserID *uint
+	TeamID *uint
+}
+
+// ListUserNodes returns the nodes a user owns, shares through a team or was granted access to
+func ListUserNodes(ctx context.Context, userID uint) ([]models.Node, error) {
+	var nodes []models.Node
+	if err := visibleNodesQuery(config.DB.WithContext(ctx), userID).Order("id").Find(&nodes).Error; err != nil {
+		return nil, err
+	}
+	return nodes, nil
+}
+
+// GetUserNode returns a node the user has at least the required access to. A node the user
+// cannot see at all is reported as not found, and one they can only see with ErrAccessDenied.
+func GetUserNode(ctx context.Context, userID uint, id uint, required string) (*models.Node, error) {
+	var node models.Node
+	if err := config.DB.WithContext(ctx).First(&node, id).Error; err != nil {
+		return nil, err
+	}
+
+	level, err := NodeAccess(ctx, userID, &node)
+	if err != nil {
+		return nil, err
+	}
+	if level == "" {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if accessRank[level] < accessRank[required] {
+		return nil, fmt.Errorf("%w: %s access to the node is required", ErrAccessDenied, required)
+	}
+	return &node, nil
+}
+
+// NodeAccess returns the access level a user has to a node, or "" when they have none.
+// The owner has admin access, team admins admin and team members operate access, and grants
+// add the level they name.
+func NodeAccess(ctx context.Context, userID uint, node *models.Node) (string, error) {
+	if node.UserID == userID {
+		return models.AccessAdmin, nil
+	}
+	db := config.DB.WithContext(ctx)
+
+	level := ""
+	if node.TeamID != nil {
+		role, err := teamRole(db, *node.TeamID, userID)
+		if err != nil {
+			return "", err
+		}
+		switch role {
+		case models.TeamRoleAdmin:
+			return models.AccessAdmin, nil
+		case models.TeamRoleMember:
+			level = models.AccessOperate
+		}
+	}
+
+	var grants []models.NodeGrant
+	if err := db.Where("node_id = ? AND user_id = ?", node.ID, userID).Limit(1).Find(&grants).Error; err != nil {
+		return "", err
+	}
+	if len(grants) > 0 && accessRank[grants[0].Level] > accessRank[level] {
+		level = grants[0].Level
+	}
+	return level, nil
+}
+
+// visibleNodesQuery selects the nodes a user owns, shares through a team or was granted access to
+func visibleNodesQuery(db *gorm.DB, userID uint) *gorm.DB {
+	return db.Model(&models.Node{}).Where("user_id = ? OR team_id IN (?) OR id IN (?)", userID,
+		db.Session(&gorm.Session{NewDB: true}).Model(&models.TeamMember{}).Select("team_id").Where("user_id = ?", userID),
+		db.Session(&gorm.Session{NewDB: true}).Model(&models.NodeGrant{}).Select("node_id").Where("user_id = ?", userID))
+}
+
+// ListNodeGrants returns the grants of a node
+func ListNodeGrants(ctx context.Context, nodeID uint) ([]models.NodeGrant, error) {
+	grants := []models.NodeGrant{}
+	if err := config.DB.WithContext(ctx).Where("node_id = ?", nodeID).Order("id").Find(&grants).Error; err != nil {
+		return nil, err
+	}
+	return grants, nil
+}
+
+// GrantNodeAccess gives a user the access level to a node, replacing any earlier grant
+func GrantNodeAccess(ctx context.Context, node *models.Node, userID uint, level string, grantedBy uint) (*models.NodeGrant, error) {
+	if accessRank[level] == 0 {
+		return nil, invalid("level", "level must be view, operate or admin")
+	}
+	if userID == node.UserID {
+		return nil, invalid("user_id", "the user owns the node")
+	}
+
+	grant := models.NodeGrant{NodeID: node.ID, UserID: userID, Level: level, GrantedBy: grantedBy}
+	err := config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := requireUser(tx, userID); err != nil {
+			return err
+		}
+		var existing []models.NodeGrant
+		if err := tx.Where("node_id = ? AND user_id = ?", node.ID, userID).Limit(1).Find(&existing).Error; err != nil {
+			return err
+		}
+		if len(existing) > 0 {
+			grant.ID = existing[0].ID
+			grant.CreatedAt = existing[0].CreatedAt
+		}
+		return tx.Save(&grant).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	invalidateNodeAccess()
+	events.PublishAccess(events.NodeShared, node, userID, level)
+	return &grant, nil
+}
+
+// RevokeNodeAccess removes the grant of a user to a node; access through the node's team remains
+func RevokeNodeAccess(ctx context.Context, node *models.Node, userID uint) error {
+	result := config.DB.WithContext(ctx).Where("node_id = ? AND user_id = ?", node.ID, userID).Delete(&models.NodeGrant{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrGrantNotFound
+	}
+
+	invalidateNodeAccess()
+	events.PublishAccess(events.NodeUnshared, node, userID, "")
+	return nil
+}
+
+// TransferNode hands a node to a new owner or team. Only the owner or an admin of the node's
+// team may do so, and only into a team they are a member of. The node has to fit the node
+// and port quotas of its new owner or team. ErrVersionConflict means the node was changed
+// since it was loaded.
+func TransferNode(ctx context.Context, userID uint, node *models.Node, transfer NodeTransfer) error {
+	if transfer.UserID == nil && transfer.TeamID == nil {
+		return invalid("user_id", "name the new owner with user_id, the new team with team_id, or both")
+	}
+
+	updated := *node
+	columns := map[string]interface{}{}
+	unlock := func() {}
+	defer func() { unlock() }()
+	err := config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		owner, err := ownsNode(tx, userID, node)
+		if err != nil {
+			return err
+		}
+		if !owner {
+			return fmt.Errorf("%w: only the owner or an admin of the node's team may transfer it", ErrAccessDenied)
+		}
+
+		if transfer.UserID != nil {
+			if err := requireUser(tx, *transfer.UserID); err != nil {
+				return err
+			}
+			updated.UserID = *transfer.UserID
+			columns["user_id"] = updated.UserID
+		}
+		if transfer.TeamID != nil {
+			if *transfer.TeamID == 0 {
+				updated.TeamID = nil
+			} else {
+				role, err := teamRole(tx, *transfer.TeamID, userID)
+				if err != nil {
+					return err
+				}
+				if role == "" {
+					return fmt.Errorf("%w: %d", ErrTeamNotFound, *transfer.TeamID)
+				}
+				teamID := *transfer.TeamID
+				updated.TeamID = &teamID
+			}
+			columns["team_id"] = updated.TeamID
+		}
+
+		if err := UpdateNodeIfVersion(tx, &updated, columns); err != nil {
+			return err
+		}
+
+		// From now on the node counts against the quota of its new owner or team
+		if subject := nodeSubject(&updated); subject != nodeSubject(node) {
+			unlock = lockQuota(subject)
+			if err := checkPortQuota(subject, updated.Port); err != nil {
+				return err
+			}
+			if err := checkNodeQuota(tx, subject); err != nil {
+				return err
+			}
+		}
+
+		// The new owner needs no grant of their own
+		return tx.Where("node_id = ? AND user_id = ?", node.ID, updated.UserID).Delete(&models.NodeGrant{}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	previousOwner := node.UserID
+	*node = updated
+	invalidateNodeAccess()
+	events.PublishTransfer(node, previousOwner)
+	return nil
+}
+
+// ownsNode reports whether the user owns the node, in person or as an admin of its team
+func ownsNode(db *gorm.DB, userID uint, node *models.Node) (bool, error) {
+	if node.UserID == userID {
+		return true, nil
+	}
+	if node.TeamID == nil {
+		return false, nil
+	}
+	role, err := teamRole(db, *node.TeamID, userID)
+	return role == models.TeamRoleAdmin, err
+}
+
+// DeleteUser deletes a user together with their team memberships, node grants and quota.
+// It fails while the user owns nodes or is the last admin of a team.
+func DeleteUser(ctx context.Context, id uint) (*models.User, error) {
+	var user models.User
+	err := config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&user, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrUserNotFound
+			}
+			return err
+		}
+
+		var nodes int64
+		if err := tx.Model(&models.Node{}).Where("user_id = ?", id).Count(&nodes).Error; err != nil {
+			return err
+		}
+		if nodes > 0 {
+			return ErrUserHasNodes
+		}
+
+		var adminOf []uint
+		if err := tx.Model(&models.TeamMember{}).Where("user_id = ? AND role = ?", id, models.TeamRoleAdmin).Pluck("team_id", &adminOf).Error; err != nil {
+			return err
+		}
+		for _, teamID := range adminOf {
+			if err := requireOtherAdmin(tx, teamID, id); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Where("user_id = ?", id).Delete(&models.TeamMember{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", id).Delete(&models.NodeGrant{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("scope = ? AND subject_id = ?", models.QuotaScopeUser, id).Delete(&models.Quota{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&user).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	invalidateNodeAccess()
+	invalidateQuotas()
+	events.PublishUser("deleted", &user)
+	return &user, nil
+}
+
+// CheckUserEditAccess fails with ErrAccessDenied unless the user changes or deletes their own
+// account or is an administrator
+func CheckUserEditAccess(ctx context.Context, userID uint, id uint) error {
+	if userID == id {
+		return nil
+	}
+
+	var user models.User
+	if err := config.DB.WithContext(ctx).First(&user, userID).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if !user.IsAdmin {
+		return fmt.Errorf("%w: only administrators may edit other users", ErrAccessDenied)
+	}
+	return nil
+}
+
+// requireUser fails with ErrUserNotFound unless the user exists
+func requireUser(db *gorm.DB, userID uint) error {
+	var count int64
+	if err := db.Model(&models.User{}).Where("id = ?", userID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return fmt.Errorf("%w: %d", ErrUserNotFound, userID)
+	}
+	return nil
+}
+
+// The nodes each user may see are cached for event delivery, which checks every event
+// against them. Any change of ownership, membership or grants empties the cache.
+var (
+	visibleMu         sync.Mutex
+	visibleGeneration uint64
+	visibleCache      = map[uint]map[uint]bool{}
+)
+
+// VisibleNodes returns the IDs of the nodes a user owns, shares through a team or was granted
+// access to. The event streams use it to deliver the events of shared nodes.
+func VisibleNodes(userID uint) map[uint]bool {
+	visibleMu.Lock()
+	nodes, ok := visibleCache[userID]
+	generation := visibleGeneration
+	visibleMu.Unlock()
+	if ok {
+		return nodes
+	}
+
+	var ids []uint
+	if err := visibleNodesQuery(config.DB, userID).Pluck("id", &ids).Error; err != nil {
+		accessLogger.Error("Failed to load the nodes visible to a user", "user_id", userID, "error", err)
+		return nil
+	}
+	nodes = make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		nodes[id] = true
+	}
+
+	// Keep the result only if access did not change while it was loading
+	visibleMu.Lock()
+	if visibleGeneration == generation {
+		visibleCache[userID] = nodes
+	}
+	visibleMu.Unlock()
+	return nodes
+}
+
+// invalidateNodeAccess empties the cache of visible nodes after access to a node changed
+func invalidateNodeAccess() {
+	visibleMu.Lock()
+	visibleGeneration++
+	visibleCache = map[uint]map[uint]bool{}
+	visibleMu.Unlock()
+}
@@ -0,0 +1,163 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"node_management_application/config"
+	"node_management_application/models"
+	"node_management_application/services"
+	"node_management_application/testutil"
+
+	"gorm.io/gorm"
+)
+
+// createNode adds a stopped node of the user straight to the database
+func createNode(t *testing.T, userID uint, name string, port int) models.Node {
+	t.Helper()
+	node := models.Node{Name: name, IP: "127.0.0.1", Port: port, UserID: userID, Status: "Stopped"}
+	if err := config.DB.Create(&node).Error; err != nil {
+		t.Fatalf("create node %s: %v", name, err)
+	}
+	return node
+}
+
+// count returns the number of rows of the model matching the condition
+func count(t *testing.T, model interface{}, query string, args ...interface{}) int64 {
+	t.Helper()
+	var n int64
+	if err := config.DB.Model(model).Where(query, args...).Count(&n).Error; err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestCheckUserEditAccess(t *testing.T) {
+	testutil.UseDatabase(t)
+	ctx := context.Background()
+	ada := testutil.CreateUser(t, "ada", false)
+	eve := testutil.CreateUser(t, "eve", false)
+	root := testutil.CreateUser(t, "root", true)
+
+	if err := services.CheckUserEditAccess(ctx, ada.ID, ada.ID); err != nil {
+		t.Errorf("editing yourself: %v", err)
+	}
+	if err := services.CheckUserEditAccess(ctx, eve.ID, ada.ID); !errors.Is(err, services.ErrAccessDenied) {
+		t.Errorf("editing someone else: got %v, want ErrAccessDenied", err)
+	}
+	if err := services.CheckUserEditAccess(ctx, root.ID, ada.ID); err != nil {
+		t.Errorf("editing someone else as an administrator: %v", err)
+	}
+}
+
+func TestGetUserNodeAccessLevels(t *testing.T) {
+	testutil.UseDatabase(t)
+	ctx := context.Background()
+	ada := testutil.CreateUser(t, "ada", false)
+	bob := testutil.CreateUser(t, "bob", false)
+	eve := testutil.CreateUser(t, "eve", false)
+	node := createNode(t, ada.ID, "alpha", 18101)
+
+	if _, err := services.GetUserNode(ctx, bob.ID, node.ID, models.AccessView); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("node without access: got %v, want not found", err)
+	}
+
+	if _, err := services.GrantNodeAccess(ctx, &node, bob.ID, models.AccessView, ada.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := services.GetUserNode(ctx, bob.ID, node.ID, models.AccessView); err != nil {
+		t.Errorf("view with a view grant: %v", err)
+	}
+	if _, err := services.GetUserNode(ctx, bob.ID, node.ID, models.AccessOperate); !errors.Is(err, services.ErrAccessDenied) {
+		t.Errorf("operate with a view grant: got %v, want ErrAccessDenied", err)
+	}
+	if _, err := services.GetUserNode(ctx, ada.ID, node.ID, models.AccessAdmin); err != nil {
+		t.Errorf("owner: %v", err)
+	}
+	if _, err := services.GetUserNode(ctx, eve.ID, node.ID, models.AccessView); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("stranger: got %v, want not found", err)
+	}
+}
+
+func TestDeleteUserRefusedWhileOwningNodes(t *testing.T) {
+	testutil.UseDatabase(t)
+	ada := testutil.CreateUser(t, "ada", false)
+	createNode(t, ada.ID, "alpha", 18102)
+
+	if _, err := services.DeleteUser(context.Background(), ada.ID); !errors.Is(err, services.ErrUserHasNodes) {
+		t.Fatalf("got %v, want ErrUserHasNodes", err)
+	}
+	if err := config.DB.First(&models.User{}, ada.ID).Error; err != nil {
+		t.Errorf("user was deleted anyway: %v", err)
+	}
+}
+
+func TestDeleteUserRefusedForLastTeamAdmin(t *testing.T) {
+	testutil.UseDatabase(t)
+	ctx := context.Background()
+	ada := testutil.CreateUser(t, "ada", false)
+	bob := testutil.CreateUser(t, "bob", false)
+
+	team, err := services.CreateTeam(ctx, ada.ID, "ops")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := services.SetTeamMember(ctx, ada.ID, team.ID, bob.ID, models.TeamRoleMember); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := services.DeleteUser(ctx, ada.ID); !errors.Is(err, services.ErrLastTeamAdmin) {
+		t.Fatalf("got %v, want ErrLastTeamAdmin", err)
+	}
+
+	// Once someone else administers the team the user may go
+	if _, err := services.SetTeamMember(ctx, ada.ID, team.ID, bob.ID, models.TeamRoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := services.DeleteUser(ctx, ada.ID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeleteUserRemovesAccessAndQuota(t *testing.T) {
+	testutil.UseDatabase(t)
+	ctx := context.Background()
+	ada := testutil.CreateUser(t, "ada", false)
+	bob := testutil.CreateUser(t, "bob", false)
+	node := createNode(t, ada.ID, "alpha", 18103)
+
+	team, err := services.CreateTeam(ctx, ada.ID, "ops")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := services.SetTeamMember(ctx, ada.ID, team.ID, bob.ID, models.TeamRoleMember); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := services.GrantNodeAccess(ctx, &node, bob.ID, models.AccessOperate, ada.ID); err != nil {
+		t.Fatal(err)
+	}
+	limit := 5
+	if _, err := services.SetQuota(ctx, models.QuotaScopeUser, bob.ID, models.Quota{MaxNodes: &limit}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := services.DeleteUser(ctx, bob.ID); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(t, &models.User{}, "id = ?", bob.ID); n != 0 {
+		t.Error("the user is left")
+	}
+	if n := count(t, &models.TeamMember{}, "user_id = ?", bob.ID); n != 0 {
+		t.Errorf("%d team memberships of the deleted user are left", n)
+	}
+	if n := count(t, &models.NodeGrant{}, "user_id = ?", bob.ID); n != 0 {
+		t.Errorf("%d node grants of the deleted user are left", n)
+	}
+	if n := count(t, &models.Quota{}, "scope = ? AND subject_id = ?", models.QuotaScopeUser, bob.ID); n != 0 {
+		t.Error("the quota of the deleted user is left")
+	}
+
+	if _, err := services.DeleteUser(ctx, bob.ID); !errors.Is(err, services.ErrUserNotFound) {
+		t.Errorf("deleting again: got %v, want ErrUserNotFound", err)
+	}
+}
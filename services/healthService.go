@@ -1,21 +1,49 @@
 package services
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"net"
+	"net/http"
 	"node_management_application/config"
+	"node_management_application/events"
+	"node_management_application/logging"
+	"node_management_application/metrics"
 	"node_management_application/models"
-	"node_management_application/websocket"
+	"node_management_application/tracing"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var healthLogger = logging.For("health")
+
+// ErrNodeUnhealthy is returned when a node did not answer its health check
+var ErrNodeUnhealthy = errors.New("node is unhealthy")
+
 // Global map to lock health checks by Node ID
 var healthCheckLocks = sync.Map{}
 
+// healthClient probes node servers, carrying the trace of the check into the node
+var healthClient = &http.Client{
+	Timeout:   3 * time.Second,
+	Transport: otelhttp.NewTransport(http.DefaultTransport),
+}
+
+// failedChecks counts the consecutive failed health checks of each node
+var failedChecks = sync.Map{}
+
 // PerformHealthCheckConcurrently checks the health of a node with concurrency control
-func PerformHealthCheckConcurrently(node *models.Node) error {
+func PerformHealthCheckConcurrently(ctx context.Context, node *models.Node) error {
 	// Acquire lock for the node
 	lock, _ := healthCheckLocks.LoadOrStore(node.ID, &sync.Mutex{})
 	mutex := lock.(*sync.Mutex)
@@ -25,42 +53,107 @@ func PerformHealthCheckConcurrently(node *models.Node) error {
 		healthCheckLocks.Delete(node.ID) // Clean up lock after health check
 	}()
 
+	// Count the check against the quota of the node's owner or team
+	if err := takeHealthCheck(node); err != nil {
+		return err
+	}
+
 	// Perform the health check
-	status, err := checkHealth(node.IP, node.Port)
+	previousStatus := node.HealthStatus
+	started := time.Now()
+	status, err := checkHealth(ctx, node)
+	metrics.ObserveHealthCheck(node.ID, status, time.Since(started))
 	node.HealthStatus = status
 	node.LastChecked = time.Now()
 
-	// Save the updated health status to the database
-	if dbErr := config.DB.Save(node).Error; dbErr != nil {
-		log.Printf("Failed to update health status for node %s: %v", node.Name, dbErr)
+	// Save only the health columns so concurrent edits of the node are not overwritten
+	if dbErr := config.DB.WithContext(ctx).Model(node).UpdateColumns(map[string]interface{}{
+		"health_status": node.HealthStatus,
+		"last_checked":  node.LastChecked,
+	}).Error; dbErr != nil {
+		healthLogger.ErrorContext(ctx, "Failed to update health status", "node_id", node.ID, "error", dbErr)
 		return fmt.Errorf("database error: %v", dbErr)
 	}
 
-	// Broadcast the health update to WebSocket clients
-	websocket.BroadcastHealthStatus(node.ID, status)
-	
+	// Announce transitions to event subscribers such as WebSocket clients
+	if status != previousStatus {
+		data := events.HealthChangedData{
+			HealthStatus:   status,
+			PreviousStatus: previousStatus,
+			LastChecked:    node.LastChecked,
+		}
+		if err != nil {
+			data.Error = err.Error()
+		}
+		events.Publish(events.Event{Type: events.NodeHealthChanged, UserID: node.UserID, NodeID: node.ID, Data: data})
+	}
+
+	// A node failing check after check raises an alert
+	countFailedCheck(node, err)
+
+	// Only transitions are worth a line at the default level; repeated results are debug output
+	level := slog.LevelDebug
+	if status != previousStatus {
+		level = slog.LevelInfo
+		if err != nil {
+			level = slog.LevelWarn
+		}
+	}
 	if err != nil {
-		log.Printf("Health check failed for node %s (%s:%d): %v", node.Name, node.IP, node.Port, err)
-		return fmt.Errorf("health check failed: %v", err)
+		healthLogger.Log(ctx, level, "Health check failed", "node_id", node.ID, "node", node.Name, "address", net.JoinHostPort(node.IP, strconv.Itoa(node.Port)), "error", err)
+		return fmt.Errorf("%w: %v", ErrNodeUnhealthy, err)
 	}
 
-	log.Printf("Health check successful for node %s (%s:%d): Status is %s", node.Name, node.IP, node.Port, status)
+	healthLogger.Log(ctx, level, "Health check succeeded", "node_id", node.ID, "node", node.Name, "status", status)
 	return nil
 }
 
-// checkHealth checks if a node is healthy by trying to connect to its port
-func checkHealth(ip string, port int) (string, error) {
-	address := fmt.Sprintf("%s:%d", ip, port)
-	log.Printf("Performing health check on %s...", address)
+// checkHealth checks if a node is healthy by requesting its /healthz endpoint
+func checkHealth(ctx context.Context, node *models.Node) (status string, err error) {
+	address := net.JoinHostPort(node.IP, strconv.Itoa(node.Port))
+	ctx, span := tracing.Tracer().Start(ctx, "checkHealth", trace.WithAttributes(tracing.NodeAttributes(node.ID, node.UserID)...))
+	defer func() {
+		span.SetAttributes(attribute.String("node.health_status", status))
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+	healthLogger.DebugContext(ctx, "Performing health check", "node_id", node.ID, "address", address)
 
-	// Attempt to connect to the node's address
-	conn, err := net.DialTimeout("tcp", address, 3*time.Second)
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+address+"/healthz", nil)
+	if err != nil {
+		return "Unhealthy", err
+	}
+	response, err := healthClient.Do(request)
 	if err != nil {
-		log.Printf("Health check failed for %s: %v", address, err)
 		return "Unhealthy", err
 	}
-	defer conn.Close()
+	io.Copy(io.Discard, response.Body)
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return "Unhealthy", fmt.Errorf("health endpoint answered %s", response.Status)
+	}
 
-	log.Printf("Node %s is responsive", address)
 	return "Healthy", nil
 }
+
+// countFailedCheck counts the failed checks of a node in a row and fires an alert when they
+// reach config.AlertAfterFailedChecks. A successful check starts the count over.
+func countFailedCheck(node *models.Node, checkErr error) {
+	if checkErr == nil {
+		failedChecks.Delete(node.ID)
+		return
+	}
+
+	count, _ := failedChecks.LoadOrStore(node.ID, new(atomic.Int64))
+	if count.(*atomic.Int64).Add(1) != int64(config.AlertAfterFailedChecks) {
+		return
+	}
+	events.Publish(events.Event{Type: events.AlertFired, UserID: node.UserID, NodeID: node.ID, Data: events.AlertData{
+		Name:     "node_unhealthy",
+		Severity: "critical",
+		Message:  fmt.Sprintf("Node %s failed %d health checks in a row: %v", node.Name, config.AlertAfterFailedChecks, checkErr),
+	}})
+}
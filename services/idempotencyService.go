@@ -0,0 +1,127 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"node_management_application/config"
+	"node_management_application/logging"
+	"node_management_application/models"
+
+	"gorm.io/gorm"
+)
+
+// idempotencyClaimTimeout is how long an unfinished request holds its key. A claim older
+// than this was left behind by a crashed server and is taken over by the next retry.
+const idempotencyClaimTimeout = 5 * time.Minute
+
+// idempotencyPruneInterval is how many claims are made between deletions of expired keys
+const idempotencyPruneInterval = 100
+
+var (
+	// ErrIdempotencyKeyReused means the key was used before for a different request
+	ErrIdempotencyKeyReused = errors.New("idempotency key was already used for a different request")
+
+	// ErrIdempotencyKeyInUse means the first request with the key has not finished yet
+	ErrIdempotencyKeyInUse = errors.New("a request with this idempotency key is still being processed")
+)
+
+var idempotencyLogger = logging.For("idempotency")
+
+var idempotencyClaims atomic.Uint64
+
+// StoredResponse is a response kept for the retries of an idempotent request
+type StoredResponse struct {
+	StatusCode int
+	Header     map[string]string
+	Body       []byte
+}
+
+// ClaimIdempotencyKey reserves a user's key for a request identified by its fingerprint.
+// It returns the stored response when the same request already completed, and nil when
+// the caller should process the request and then complete or release the key.
+func ClaimIdempotencyKey(ctx context.Context, userID uint, key, fingerprint string) (*StoredResponse, error) {
+	db := config.DB.WithContext(ctx)
+	now := time.Now()
+
+	if idempotencyClaims.Add(1)%idempotencyPruneInterval == 0 {
+		if err := db.Where("expires_at < ?", now).Delete(&models.IdempotencyKey{}).Error; err != nil {
+			idempotencyLogger.Warn("Failed to prune idempotency keys", "error", err)
+		}
+	}
+
+	claim := models.IdempotencyKey{UserID: userID, Key: key, Fingerprint: fingerprint, ExpiresAt: now.Add(config.IdempotencyKeyTTL)}
+	err := db.Create(&claim).Error
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.Is(err, gorm.ErrDuplicatedKey) {
+		return nil, err
+	}
+
+	var existing models.IdempotencyKey
+	if err := db.Where("user_id = ? AND idempotency_key = ?", userID, key).First(&existing).Error; err != nil {
+		return nil, err
+	}
+
+	expired := existing.ExpiresAt.Before(now)
+	abandoned := existing.StatusCode == 0 && existing.CreatedAt.Before(now.Add(-idempotencyClaimTimeout))
+	switch {
+	case expired || abandoned:
+		// Take the key over, unless a concurrent retry got to it first
+		result := db.Model(&models.IdempotencyKey{}).
+			Where("id = ? AND created_at = ? AND status_code = ?", existing.ID, existing.CreatedAt, existing.StatusCode).
+			Updates(map[string]interface{}{
+				"fingerprint": fingerprint,
+				"status_code": 0,
+				"header":      "",
+				"body":        nil,
+				"created_at":  now,
+				"expires_at":  claim.ExpiresAt,
+			})
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil, ErrIdempotencyKeyInUse
+		}
+		return nil, nil
+	case existing.Fingerprint != fingerprint:
+		return nil, ErrIdempotencyKeyReused
+	case existing.StatusCode == 0:
+		return nil, ErrIdempotencyKeyInUse
+	}
+
+	stored := &StoredResponse{StatusCode: existing.StatusCode, Body: existing.Body}
+	if existing.Header != "" {
+		if err := json.Unmarshal([]byte(existing.Header), &stored.Header); err != nil {
+			return nil, err
+		}
+	}
+	return stored, nil
+}
+
+// CompleteIdempotencyKey stores the response of a claimed key for replay
+func CompleteIdempotencyKey(ctx context.Context, userID uint, key string, response StoredResponse) error {
+	header, err := json.Marshal(response.Header)
+	if err != nil {
+		return err
+	}
+	return config.DB.WithContext(ctx).Model(&models.IdempotencyKey{}).
+		Where("user_id = ? AND idempotency_key = ?", userID, key).
+		Updates(map[string]interface{}{
+			"status_code": response.StatusCode,
+			"header":      string(header),
+			"body":        response.Body,
+		}).Error
+}
+
+// ReleaseIdempotencyKey forgets a claim whose request failed on the server side, so a retry runs it again
+func ReleaseIdempotencyKey(ctx context.Context, userID uint, key string) error {
+	return config.DB.WithContext(ctx).
+		Where("user_id = ? AND idempotency_key = ? AND status_code = 0", userID, key).
+		Delete(&models.IdempotencyKey{}).Error
+}
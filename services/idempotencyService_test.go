@@ -0,0 +1,105 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"node_management_application/config"
+	"node_management_application/models"
+	"node_management_application/services"
+	"node_management_application/testutil"
+)
+
+// claim claims the key of user 1 and fails the test unless the caller should process the request
+func claim(t *testing.T, key, fingerprint string) {
+	t.Helper()
+	stored, err := services.ClaimIdempotencyKey(context.Background(), 1, key, fingerprint)
+	if err != nil || stored != nil {
+		t.Fatalf("claim %s: got %v, %v, want the key to process the request with", key, stored, err)
+	}
+}
+
+func TestIdempotencyKeyReplay(t *testing.T) {
+	testutil.UseDatabase(t)
+	ctx := context.Background()
+	claim(t, "create-alpha", "post-nodes")
+
+	if _, err := services.ClaimIdempotencyKey(ctx, 1, "create-alpha", "post-nodes"); !errors.Is(err, services.ErrIdempotencyKeyInUse) {
+		t.Fatalf("retry while processing: got %v, want ErrIdempotencyKeyInUse", err)
+	}
+
+	response := services.StoredResponse{StatusCode: 201, Header: map[string]string{"Content-Type": "application/json"}, Body: []byte(`{"ID":1}`)}
+	if err := services.CompleteIdempotencyKey(ctx, 1, "create-alpha", response); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := services.ClaimIdempotencyKey(ctx, 1, "create-alpha", "post-nodes")
+	if err != nil || stored == nil {
+		t.Fatalf("retry after completion: got %v, %v, want the stored response", stored, err)
+	}
+	if stored.StatusCode != 201 || string(stored.Body) != `{"ID":1}` || stored.Header["Content-Type"] != "application/json" {
+		t.Errorf("replayed %+v, want %+v", stored, response)
+	}
+
+	if _, err := services.ClaimIdempotencyKey(ctx, 1, "create-alpha", "delete-node"); !errors.Is(err, services.ErrIdempotencyKeyReused) {
+		t.Errorf("different request: got %v, want ErrIdempotencyKeyReused", err)
+	}
+
+	// Keys belong to one user
+	if stored, err := services.ClaimIdempotencyKey(ctx, 2, "create-alpha", "delete-node"); err != nil || stored != nil {
+		t.Errorf("another user's key: got %v, %v", stored, err)
+	}
+}
+
+func TestIdempotencyKeyRelease(t *testing.T) {
+	testutil.UseDatabase(t)
+	ctx := context.Background()
+	claim(t, "start", "post-start")
+
+	if err := services.ReleaseIdempotencyKey(ctx, 1, "start"); err != nil {
+		t.Fatal(err)
+	}
+	claim(t, "start", "post-start")
+
+	// A completed key is kept for replay
+	if err := services.CompleteIdempotencyKey(ctx, 1, "start", services.StoredResponse{StatusCode: 200}); err != nil {
+		t.Fatal(err)
+	}
+	if err := services.ReleaseIdempotencyKey(ctx, 1, "start"); err != nil {
+		t.Fatal(err)
+	}
+	if stored, err := services.ClaimIdempotencyKey(ctx, 1, "start", "post-start"); err != nil || stored == nil {
+		t.Errorf("got %v, %v, want the stored response", stored, err)
+	}
+}
+
+func TestIdempotencyKeyTakeover(t *testing.T) {
+	testutil.UseDatabase(t)
+	ctx := context.Background()
+
+	// A claim left behind by a crashed server is taken over, even by a different request
+	claim(t, "abandoned", "first")
+	config.DB.Model(&models.IdempotencyKey{}).Where("idempotency_key = ?", "abandoned").
+		Update("created_at", time.Now().Add(-time.Hour))
+	claim(t, "abandoned", "second")
+	var row models.IdempotencyKey
+	if err := config.DB.Where("idempotency_key = ?", "abandoned").First(&row).Error; err != nil {
+		t.Fatal(err)
+	}
+	if row.Fingerprint != "second" || time.Since(row.CreatedAt) > time.Minute {
+		t.Errorf("claim was not taken over: %+v", row)
+	}
+	if _, err := services.ClaimIdempotencyKey(ctx, 1, "abandoned", "second"); !errors.Is(err, services.ErrIdempotencyKeyInUse) {
+		t.Errorf("after takeover: got %v, want ErrIdempotencyKeyInUse", err)
+	}
+
+	// An expired key starts over instead of replaying
+	claim(t, "expired", "first")
+	if err := services.CompleteIdempotencyKey(ctx, 1, "expired", services.StoredResponse{StatusCode: 200}); err != nil {
+		t.Fatal(err)
+	}
+	config.DB.Model(&models.IdempotencyKey{}).Where("idempotency_key = ?", "expired").
+		Update("expires_at", time.Now().Add(-time.Minute))
+	claim(t, "expired", "first")
+}
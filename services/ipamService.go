@@ -0,0 +1,253 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"net"
+	"sort"
+	"sync"
+
+	"node_management_application/config"
+	"node_management_application/models"
+)
+
+var (
+	// ErrSubnetExhausted is returned when a subnet has no unused address left
+	ErrSubnetExhausted = errors.New("no free address left in subnet")
+	// ErrSubnetNotFound is returned when allocating from a subnet that does not exist
+	ErrSubnetNotFound = errors.New("subnet not found")
+)
+
+// maxScannedHosts bounds the addresses inspected when allocating from very large subnets
+const maxScannedHosts = 1 << 16
+
+// ipAllocationMutex serializes allocations, from picking an address until the node using it is
+// saved, so concurrent requests do not pick the same address
+var ipAllocationMutex sync.Mutex
+
+// AddressUsage lists the nodes using one address of a subnet
+type AddressUsage struct {
+	IP    string `json:"ip"`
+	Nodes []uint `json:"nodes"`
+}
+
+// SubnetUtilization is the usage report of a subnet
+type SubnetUtilization struct {
+	Subnet             models.Subnet  `json:"subnet"`
+	TotalAddresses     uint64         `json:"total_addresses"`
+	UsedAddresses      int            `json:"used_addresses"`
+	FreeAddresses      uint64         `json:"free_addresses"`
+	UtilizationPercent float64        `json:"utilization_percent"`
+	Addresses          []AddressUsage `json:"addresses"`
+}
+
+// ValidateSubnet normalizes the CIDR of an admin supplied subnet
+func ValidateSubnet(subnet *models.Subnet) error {
+	if subnet.Name == "" {
+		return invalid("name", "name is required")
+	}
+	_, network, err := net.ParseCIDR(subnet.CIDR)
+	if err != nil {
+		return invalid("cidr", "invalid CIDR format")
+	}
+	subnet.CIDR = network.String()
+	return nil
+}
+
+// ValidateLocalAddress checks that an in-process node can actually bind the IP. It lists the
+// host's interfaces, so it is only run when a node is created with an IP of the caller's choosing.
+func ValidateLocalAddress(ip string) error {
+	if config.NodeRuntime != "in-process" {
+		return nil
+	}
+
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return invalid("ip", "invalid IP address format")
+	}
+	if parsed.IsUnspecified() {
+		return nil
+	}
+
+	addresses, err := localAddresses()
+	if err != nil {
+		return fmt.Errorf("failed to list local interfaces: %v", err)
+	}
+	for _, local := range addresses {
+		if local.Equal(parsed) {
+			return nil
+		}
+	}
+	return invalid("ip", fmt.Sprintf("IP address %s is not assigned to any local interface", ip))
+}
+
+// AllocateAddress returns the first address of the subnet that no node uses yet.
+// For in-process nodes only addresses configured on this host are considered.
+// Callers hold lockAddressAllocation until the node using the address is saved.
+func AllocateAddress(subnetID uint) (string, error) {
+	var subnet models.Subnet
+	if err := config.DB.First(&subnet, subnetID).Error; err != nil {
+		return "", fmt.Errorf("%w: %d", ErrSubnetNotFound, subnetID)
+	}
+	_, network, err := net.ParseCIDR(subnet.CIDR)
+	if err != nil {
+		return "", fmt.Errorf("subnet %s has an invalid CIDR", subnet.Name)
+	}
+
+	used, err := usedAddresses(network)
+	if err != nil {
+		return "", err
+	}
+
+	if config.NodeRuntime == "in-process" {
+		addresses, err := localAddresses()
+		if err != nil {
+			return "", fmt.Errorf("failed to list local interfaces: %v", err)
+		}
+		for _, local := range addresses {
+			if network.Contains(local) && !isReservedHost(network, local) && used[local.String()] == nil {
+				return local.String(), nil
+			}
+		}
+		return "", fmt.Errorf("%w %s (no unused local address)", ErrSubnetExhausted, subnet.Name)
+	}
+
+	ip := nextIP(network.IP)
+	for scanned := 0; network.Contains(ip) && scanned < maxScannedHosts; scanned++ {
+		if !isReservedHost(network, ip) && used[ip.String()] == nil {
+			return ip.String(), nil
+		}
+		ip = nextIP(ip)
+	}
+	return "", fmt.Errorf("%w %s", ErrSubnetExhausted, subnet.Name)
+}
+
+// lockAddressAllocation takes the allocation lock and returns the function releasing it
+func lockAddressAllocation() func() {
+	ipAllocationMutex.Lock()
+	return ipAllocationMutex.Unlock
+}
+
+// GetSubnetUtilization reports how many addresses of a subnet are in use and by which nodes
+func GetSubnetUtilization(subnet models.Subnet) (*SubnetUtilization, error) {
+	_, network, err := net.ParseCIDR(subnet.CIDR)
+	if err != nil {
+		return nil, fmt.Errorf("subnet %s has an invalid CIDR", subnet.Name)
+	}
+
+	used, err := usedAddresses(network)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &SubnetUtilization{
+		Subnet:         subnet,
+		TotalAddresses: hostCount(network),
+		UsedAddresses:  len(used),
+		Addresses:      make([]AddressUsage, 0, len(used)),
+	}
+	if uint64(len(used)) < report.TotalAddresses {
+		report.FreeAddresses = report.TotalAddresses - uint64(len(used))
+	}
+	if report.TotalAddresses > 0 {
+		report.UtilizationPercent = math.Round(float64(len(used))/float64(report.TotalAddresses)*10000) / 100
+	}
+
+	for ip, nodes := range used {
+		report.Addresses = append(report.Addresses, AddressUsage{IP: ip, Nodes: nodes})
+	}
+	sort.Slice(report.Addresses, func(i, j int) bool {
+		return bytesLess(net.ParseIP(report.Addresses[i].IP), net.ParseIP(report.Addresses[j].IP))
+	})
+	return report, nil
+}
+
+// usedAddresses maps every node IP inside the network to the nodes using it
+func usedAddresses(network *net.IPNet) (map[string][]uint, error) {
+	var nodes []models.Node
+	if err := config.DB.Select("id", "ip").Find(&nodes).Error; err != nil {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+
+	used := make(map[string][]uint)
+	for _, node := range nodes {
+		ip := net.ParseIP(node.IP)
+		if ip != nil && network.Contains(ip) {
+			used[ip.String()] = append(used[ip.String()], node.ID)
+		}
+	}
+	return used, nil
+}
+
+// localAddresses lists the unicast addresses configured on this host's interfaces
+func localAddresses() ([]net.IP, error) {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil, err
+	}
+
+	var ips []net.IP
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok {
+			ips = append(ips, ipNet.IP)
+		}
+	}
+	return ips, nil
+}
+
+// hostCount returns the number of assignable addresses, saturating for huge IPv6 subnets
+func hostCount(network *net.IPNet) uint64 {
+	ones, bits := network.Mask.Size()
+	hostBits := bits - ones
+	if hostBits >= 64 {
+		return math.MaxUint64
+	}
+
+	count := new(big.Int).Lsh(big.NewInt(1), uint(hostBits)).Uint64()
+	// IPv4 networks larger than /31 lose their network and broadcast addresses
+	if bits == 32 && hostBits > 1 {
+		count -= 2
+	}
+	return count
+}
+
+// isReservedHost reports the IPv4 network and broadcast addresses, which cannot be assigned
+func isReservedHost(network *net.IPNet, ip net.IP) bool {
+	ones, bits := network.Mask.Size()
+	if bits != 32 || bits-ones <= 1 {
+		return false
+	}
+
+	ip4 := ip.To4()
+	broadcast := make(net.IP, len(ip4))
+	for i := range ip4 {
+		broadcast[i] = network.IP.To4()[i] | ^network.Mask[i]
+	}
+	return ip4.Equal(network.IP) || ip4.Equal(broadcast)
+}
+
+// nextIP returns the address following ip
+func nextIP(ip net.IP) net.IP {
+	next := make(net.IP, len(ip))
+	copy(next, ip)
+	for i := len(next) - 1; i >= 0; i-- {
+		next[i]++
+		if next[i] != 0 {
+			break
+		}
+	}
+	return next
+}
+
+// bytesLess orders IPs numerically
+func bytesLess(a, b net.IP) bool {
+	a, b = a.To16(), b.To16()
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return false
+}
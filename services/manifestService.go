@@ -0,0 +1,628 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"node_management_application/config"
+	"node_management_application/events"
+	"node_management_application/logging"
+	"node_management_application/models"
+
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+)
+
+// ManifestAPIVersion is the only manifest version understood by this server
+const ManifestAPIVersion = "v1"
+
+// ErrInvalidManifest is returned when a manifest cannot be parsed or describes an impossible fleet
+var ErrInvalidManifest = errors.New("invalid manifest")
+
+// Desired runtime states a manifest node can declare
+const (
+	StateRunning = "running"
+	StateStopped = "stopped"
+)
+
+// Plan action kinds, in the order they are applied
+const (
+	ActionStop   = "stop"
+	ActionDelete = "delete"
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionStart  = "start"
+)
+
+// Manifest is the declarative description of a user's fleet
+type Manifest struct {
+	APIVersion string             `yaml:"apiVersion" json:"apiVersion"`
+	Nodes      []ManifestNode     `yaml:"nodes" json:"nodes"`
+	Groups     []ManifestGroup    `yaml:"groups,omitempty" json:"groups,omitempty"`
+	Checks     []ManifestCheck    `yaml:"checks,omitempty" json:"checks,omitempty"`
+	Schedules  []ManifestSchedule `yaml:"schedules,omitempty" json:"schedules,omitempty"`
+}
+
+// ManifestNode describes one node; nodes are matched to the database by name
+type ManifestNode struct {
+	Name     string `yaml:"name" json:"name"`
+	IP       string `yaml:"ip" json:"ip"`
+	Port     int    `yaml:"port" json:"port"`
+	Location string `yaml:"location,omitempty" json:"location,omitempty"`
+	State    string `yaml:"state,omitempty" json:"state,omitempty"`
+
+	// Settings the groups, checks and schedules sections give the node
+	group         string
+	checkInterval int
+	startAt       string
+	stopAt        string
+}
+
+// ManifestGroup names a set of nodes that checks, schedules and event subscriptions can refer to.
+// A node belongs to at most one group.
+type ManifestGroup struct {
+	Name  string   `yaml:"name" json:"name"`
+	Nodes []string `yaml:"nodes" json:"nodes"`
+}
+
+// ManifestCheck sets how often the health monitor checks a node or every node of a group
+type ManifestCheck struct {
+	Node     string `yaml:"node,omitempty" json:"node,omitempty"`
+	Group    string `yaml:"group,omitempty" json:"group,omitempty"`
+	Interval string `yaml:"interval" json:"interval"` // Duration such as "30s" or "5m"
+}
+
+// ManifestSchedule starts and stops a node or every node of a group at daily UTC times.
+// The schedule takes over from the node's state until the next apply.
+type ManifestSchedule struct {
+	Node  string `yaml:"node,omitempty" json:"node,omitempty"`
+	Group string `yaml:"group,omitempty" json:"group,omitempty"`
+	Start string `yaml:"start,omitempty" json:"start,omitempty"` // HH:MM
+	Stop  string `yaml:"stop,omitempty" json:"stop,omitempty"`   // HH:MM
+}
+
+// FieldChange records a single attribute that differs between the manifest and the database
+type FieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+// PlanAction is one step needed to converge the database on the manifest
+type PlanAction struct {
+	Action  string        `json:"action"`
+	Node    string        `json:"node"`
+	NodeID  uint          `json:"node_id,omitempty"`
+	Changes []FieldChange `json:"changes,omitempty"`
+
+	spec    *ManifestNode
+	current *models.Node // Node as it was when the plan was made
+	version uint         // Version of the node when the plan was made
+	teamID  *uint        // Team of the node when the plan was made
+}
+
+// Plan is the ordered list of actions produced by diffing a manifest against the database
+type Plan struct {
+	Actions []PlanAction   `json:"actions"`
+	Summary map[string]int `json:"summary"`
+}
+
+// ApplyResult reports what happened when a plan was applied
+type ApplyResult struct {
+	Plan    *Plan    `json:"plan"`
+	Applied bool     `json:"applied"`
+	Errors  []string `json:"errors,omitempty"`
+}
+
+// ParseManifest decodes a YAML or JSON manifest and checks it for structural errors
+func ParseManifest(data []byte) (*Manifest, error) {
+	var manifest Manifest
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidManifest, err)
+	}
+
+	if manifest.APIVersion != ManifestAPIVersion {
+		return nil, fmt.Errorf("%w: unsupported apiVersion %q (expected %q)", ErrInvalidManifest, manifest.APIVersion, ManifestAPIVersion)
+	}
+	seen := make(map[string]bool, len(manifest.Nodes))
+	for i := range manifest.Nodes {
+		node := &manifest.Nodes[i]
+		node.State = strings.ToLower(node.State)
+
+		if err := ValidateNodeData(node.Name, node.IP, node.Port); err != nil {
+			return nil, fmt.Errorf("%w: node %d (%s): %v", ErrInvalidManifest, i+1, node.Name, err)
+		}
+		if node.State != "" && node.State != StateRunning && node.State != StateStopped {
+			return nil, fmt.Errorf("%w: node %d (%s): state must be %q or %q", ErrInvalidManifest, i+1, node.Name, StateRunning, StateStopped)
+		}
+		if seen[node.Name] {
+			return nil, fmt.Errorf("%w: node %q is declared more than once", ErrInvalidManifest, node.Name)
+		}
+		seen[node.Name] = true
+	}
+
+	if err := resolveSections(&manifest); err != nil {
+		return nil, err
+	}
+	return &manifest, nil
+}
+
+// resolveSections gives the nodes the settings of the groups, checks and schedules naming them
+func resolveSections(manifest *Manifest) error {
+	nodes := make(map[string]*ManifestNode, len(manifest.Nodes))
+	for i := range manifest.Nodes {
+		nodes[manifest.Nodes[i].Name] = &manifest.Nodes[i]
+	}
+
+	groups := make(map[string][]*ManifestNode, len(manifest.Groups))
+	for i, group := range manifest.Groups {
+		if group.Name == "" || len(group.Name) > 100 {
+			return fmt.Errorf("%w: group %d: name is required and must be at most 100 characters", ErrInvalidManifest, i+1)
+		}
+		if _, dup := groups[group.Name]; dup {
+			return fmt.Errorf("%w: group %q is declared more than once", ErrInvalidManifest, group.Name)
+		}
+		members := make([]*ManifestNode, 0, len(group.Nodes))
+		for _, name := range group.Nodes {
+			node, ok := nodes[name]
+			if !ok {
+				return fmt.Errorf("%w: group %q: node %q is not declared", ErrInvalidManifest, group.Name, name)
+			}
+			if node.group != "" {
+				return fmt.Errorf("%w: node %q is in groups %q and %q", ErrInvalidManifest, name, node.group, group.Name)
+			}
+			node.group = group.Name
+			members = append(members, node)
+		}
+		groups[group.Name] = members
+	}
+
+	// targets returns the nodes a check or schedule applies to
+	targets := func(section string, i int, node, group string) ([]*ManifestNode, error) {
+		if (node == "") == (group == "") {
+			return nil, fmt.Errorf("%w: %s %d: set either node or group", ErrInvalidManifest, section, i+1)
+		}
+		if node != "" {
+			target, ok := nodes[node]
+			if !ok {
+				return nil, fmt.Errorf("%w: %s %d: node %q is not declared", ErrInvalidManifest, section, i+1, node)
+			}
+			return []*ManifestNode{target}, nil
+		}
+		members, ok := groups[group]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s %d: group %q is not declared", ErrInvalidManifest, section, i+1, group)
+		}
+		return members, nil
+	}
+
+	checked := make(map[string]bool)
+	for i, check := range manifest.Checks {
+		interval, err := time.ParseDuration(check.Interval)
+		if err != nil || interval < healthCheckPeriod {
+			return fmt.Errorf("%w: check %d: interval must be a duration of at least %s", ErrInvalidManifest, i+1, healthCheckPeriod)
+		}
+		members, err := targets("check", i, check.Node, check.Group)
+		if err != nil {
+			return err
+		}
+		for _, node := range members {
+			if checked[node.Name] {
+				return fmt.Errorf("%w: node %q has more than one check", ErrInvalidManifest, node.Name)
+			}
+			checked[node.Name] = true
+			node.checkInterval = int(interval / time.Second)
+		}
+	}
+
+	scheduled := make(map[string]bool)
+	for i, schedule := range manifest.Schedules {
+		if schedule.Start == "" && schedule.Stop == "" {
+			return fmt.Errorf("%w: schedule %d: set start, stop or both", ErrInvalidManifest, i+1)
+		}
+		for _, at := range []string{schedule.Start, schedule.Stop} {
+			if at != "" && !isTimeOfDay(at) {
+				return fmt.Errorf("%w: schedule %d: %q is not a time of day in HH:MM", ErrInvalidManifest, i+1, at)
+			}
+		}
+		members, err := targets("schedule", i, schedule.Node, schedule.Group)
+		if err != nil {
+			return err
+		}
+		for _, node := range members {
+			if scheduled[node.Name] {
+				return fmt.Errorf("%w: node %q has more than one schedule", ErrInvalidManifest, node.Name)
+			}
+			scheduled[node.Name] = true
+			node.startAt, node.stopAt = schedule.Start, schedule.Stop
+		}
+	}
+	return nil
+}
+
+// PlanManifest diffs the manifest against the user's nodes.
+// Nodes missing from the manifest are only deleted when prune is set.
+func PlanManifest(userID uint, manifest *Manifest, prune bool) (*Plan, error) {
+	var existing []models.Node
+	if err := config.DB.Where("user_id = ?", userID).Order("id").Find(&existing).Error; err != nil {
+		return nil, fmt.Errorf("database error: %v", err)
+	}
+
+	byName := make(map[string]*models.Node, len(existing))
+	for i := range existing {
+		node := &existing[i]
+		if _, dup := byName[node.Name]; dup {
+			return nil, fmt.Errorf("%w: node name %q is not unique in the database and cannot be managed by a manifest", ErrInvalidManifest, node.Name)
+		}
+		byName[node.Name] = node
+	}
+
+	plan := &Plan{Summary: map[string]int{}}
+	add := func(action PlanAction) {
+		plan.Actions = append(plan.Actions, action)
+		plan.Summary[action.Action]++
+	}
+
+	declared := make(map[string]bool, len(manifest.Nodes))
+	for i := range manifest.Nodes {
+		spec := &manifest.Nodes[i]
+		declared[spec.Name] = true
+
+		current, ok := byName[spec.Name]
+		if !ok {
+			add(PlanAction{Action: ActionCreate, Node: spec.Name, spec: spec})
+			if spec.State == StateRunning {
+				add(PlanAction{Action: ActionStart, Node: spec.Name, spec: spec})
+			}
+			continue
+		}
+
+		running := current.Status == "Running"
+		changes := diffNode(current, spec)
+		addressChanged := current.IP != spec.IP || current.Port != spec.Port
+
+		// A running listener has to be restarted to pick up a new address
+		stop := running && (spec.State == StateStopped || (addressChanged && spec.State != StateStopped))
+		start := (!running && spec.State == StateRunning) || (stop && spec.State != StateStopped)
+
+		if stop {
+			add(PlanAction{Action: ActionStop, Node: current.Name, NodeID: current.ID, current: current})
+		}
+		if len(changes) > 0 {
+			add(PlanAction{Action: ActionUpdate, Node: current.Name, NodeID: current.ID, Changes: changes, spec: spec, version: current.Version, teamID: current.TeamID})
+		}
+		if start {
+			add(PlanAction{Action: ActionStart, Node: current.Name, NodeID: current.ID, spec: spec})
+		}
+	}
+
+	if prune {
+		for i := range existing {
+			node := &existing[i]
+			if declared[node.Name] {
+				continue
+			}
+			if node.Status == "Running" {
+				add(PlanAction{Action: ActionStop, Node: node.Name, NodeID: node.ID, current: node})
+			}
+			add(PlanAction{Action: ActionDelete, Node: node.Name, NodeID: node.ID})
+		}
+	}
+
+	sortPlan(plan)
+	return plan, nil
+}
+
+// ApplyManifest converges the user's nodes on the manifest.
+// Database changes run in one transaction, and listeners are only stopped and started once it
+// has committed, so an apply that fails leaves every node running as it was.
+func ApplyManifest(ctx context.Context, userID uint, manifest *Manifest, prune bool, dryRun bool) (*ApplyResult, error) {
+	plan, err := PlanManifest(userID, manifest, prune)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ApplyResult{Plan: plan}
+	if dryRun || len(plan.Actions) == 0 {
+		return result, nil
+	}
+
+	// Hold the quota lock until the transaction commits so concurrent creates count each other
+	subject := userSubject(userID)
+	unlock := lockQuota(subject)
+	defer unlock()
+
+	// Nodes whose listener is stopped after the commit, and the deleted ones among them
+	stopping := make(map[uint]bool)
+	deleted := make(map[uint]bool)
+	for _, action := range plan.Actions {
+		switch action.Action {
+		case ActionStop:
+			stopping[action.NodeID] = true
+		case ActionDelete:
+			deleted[action.NodeID] = true
+		}
+	}
+
+	created := make(map[string]uint)
+	err = config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, action := range plan.Actions {
+			switch action.Action {
+			case ActionCreate:
+				node := models.Node{
+					UserID:        userID,
+					Name:          action.spec.Name,
+					IP:            action.spec.IP,
+					Port:          action.spec.Port,
+					Location:      action.spec.Location,
+					Group:         action.spec.group,
+					CheckInterval: action.spec.checkInterval,
+					StartAt:       action.spec.startAt,
+					StopAt:        action.spec.stopAt,
+					Status:        "Stopped",
+					HealthStatus:  "Unhealthy",
+					LastChecked:   time.Now(),
+				}
+				if err := checkPortQuota(subject, node.Port); err != nil {
+					return fmt.Errorf("node %s: %w", action.Node, err)
+				}
+				if err := tx.Create(&node).Error; err != nil {
+					return fmt.Errorf("failed to create node %s: %v", action.Node, err)
+				}
+				if err := ReservePort(tx, &node); err != nil {
+					return fmt.Errorf("node %s: %w", action.Node, err)
+				}
+				created[node.Name] = node.ID
+
+			case ActionUpdate:
+				node := models.Node{ID: action.NodeID, UserID: userID, TeamID: action.teamID, Version: action.version, IP: action.spec.IP, Port: action.spec.Port}
+				if action.changes("port") {
+					if err := CheckNodePortQuota(&node, node.Port); err != nil {
+						return fmt.Errorf("node %s: %w", action.Node, err)
+					}
+				}
+				if err := UpdateNodeIfVersion(tx, &node, map[string]interface{}{
+					"ip":             action.spec.IP,
+					"port":           action.spec.Port,
+					"location":       action.spec.Location,
+					"node_group":     action.spec.group,
+					"check_interval": action.spec.checkInterval,
+					"start_at":       action.spec.startAt,
+					"stop_at":        action.spec.stopAt,
+				}); err != nil {
+					return fmt.Errorf("failed to update node %s: %w", action.Node, err)
+				}
+				if err := ReservePort(tx, &node); err != nil {
+					return fmt.Errorf("node %s: %w", action.Node, err)
+				}
+
+			case ActionDelete:
+				if err := tx.Where("id = ? AND user_id = ?", action.NodeID, userID).Delete(&models.Node{}).Error; err != nil {
+					return fmt.Errorf("failed to delete node %s: %v", action.Node, err)
+				}
+				if err := tx.Where("node_id = ?", action.NodeID).Delete(&models.NodeGrant{}).Error; err != nil {
+					return fmt.Errorf("failed to delete grants of node %s: %v", action.Node, err)
+				}
+				// A running node keeps its port reserved until its listener has stopped
+				if stopping[action.NodeID] {
+					continue
+				}
+				if err := ReleasePort(tx, action.NodeID); err != nil {
+					return fmt.Errorf("failed to release port of node %s: %v", action.Node, err)
+				}
+			}
+		}
+		// The created nodes have to fit the user's node quota
+		if plan.Summary[ActionCreate] > 0 {
+			return checkNodeQuota(tx, subject)
+		}
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+	result.Applied = true
+
+	// Announce the committed changes
+	for _, action := range plan.Actions {
+		switch action.Action {
+		case ActionCreate, ActionUpdate:
+			id := action.NodeID
+			if action.Action == ActionCreate {
+				id = created[action.Node]
+			}
+			if node, err := models.GetNodeByID(id); err == nil {
+				setNodeGroup(node.ID, node.Group)
+				eventType := events.NodeUpdated
+				if action.Action == ActionCreate {
+					eventType = events.NodeCreated
+				}
+				events.PublishNode(eventType, node)
+			}
+		case ActionDelete:
+			events.PublishNode(events.NodeDeleted, &models.Node{ID: action.NodeID, UserID: userID, Name: action.Node})
+		}
+	}
+
+	// Stop the listeners of deleted, stopped and re-addressed nodes at the address they had.
+	// A node whose listener failed to stop keeps it, and its port, and is not started a second time.
+	stopFailed := make(map[string]bool)
+	for _, action := range plan.Actions {
+		if action.Action != ActionStop {
+			continue
+		}
+		if err := StopNode(ctx, action.current); err != nil {
+			logging.For("manifest").ErrorContext(ctx, "Manifest apply failed to stop node", "node", action.Node, "error", err)
+			result.Errors = append(result.Errors, fmt.Sprintf("failed to stop node %s: %v", action.Node, err))
+			stopFailed[action.Node] = true
+			continue
+		}
+		if deleted[action.NodeID] {
+			if err := ReleasePort(config.DB.WithContext(ctx), action.NodeID); err != nil {
+				logging.For("manifest").ErrorContext(ctx, "Manifest apply failed to release port", "node", action.Node, "error", err)
+				result.Errors = append(result.Errors, fmt.Sprintf("failed to release port of node %s: %v", action.Node, err))
+			}
+		}
+	}
+
+	// Start listeners on the committed configuration
+	for i := range plan.Actions {
+		action := &plan.Actions[i]
+		if action.Action != ActionStart || stopFailed[action.Node] {
+			continue
+		}
+		if action.NodeID == 0 {
+			action.NodeID = created[action.Node]
+		}
+		node, err := models.GetNodeByID(action.NodeID)
+		if err == nil {
+			err = StartNode(ctx, node)
+		}
+		if err != nil {
+			logging.For("manifest").ErrorContext(ctx, "Manifest apply failed to start node", "node", action.Node, "error", err)
+			result.Errors = append(result.Errors, fmt.Sprintf("failed to start node %s: %v", action.Node, err))
+		}
+	}
+
+	return result, nil
+}
+
+// The groups of nodes are cached for event delivery, which looks up the group of every event's
+// node. Only manifests set groups, and applying one updates the cache. Deleted nodes are kept
+// so that the events announcing their deletion still reach the group.
+var (
+	groupsMu         sync.Mutex
+	groupsGeneration uint64
+	groupsCache      map[uint]string
+)
+
+// NodeGroup returns the group of a node, or "" when it has none. WebSocket clients use it to
+// deliver the events of the groups they subscribed to.
+func NodeGroup(nodeID uint) string {
+	groupsMu.Lock()
+	groups := groupsCache
+	generation := groupsGeneration
+	groupsMu.Unlock()
+	if groups != nil {
+		return groups[nodeID]
+	}
+
+	var nodes []models.Node
+	if err := config.DB.Select("id", "node_group").Where("node_group <> ?", "").Find(&nodes).Error; err != nil {
+		logging.For("manifest").Error("Failed to load node groups", "error", err)
+		return ""
+	}
+	groups = make(map[uint]string, len(nodes))
+	for _, node := range nodes {
+		groups[node.ID] = node.Group
+	}
+
+	// Keep the result only if no group changed while it was loading
+	groupsMu.Lock()
+	if groupsGeneration == generation {
+		groupsCache = groups
+	}
+	groupsMu.Unlock()
+	return groups[nodeID]
+}
+
+// setNodeGroup records the committed group of a node in the cache
+func setNodeGroup(nodeID uint, group string) {
+	groupsMu.Lock()
+	defer groupsMu.Unlock()
+
+	groupsGeneration++
+	if groupsCache == nil {
+		return
+	}
+	if group == "" {
+		delete(groupsCache, nodeID)
+	} else {
+		groupsCache[nodeID] = group
+	}
+}
+
+// changes reports whether the action changes the field
+func (a *PlanAction) changes(field string) bool {
+	for _, change := range a.Changes {
+		if change.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// diffNode lists the attributes of a stored node that differ from its manifest entry
+func diffNode(current *models.Node, spec *ManifestNode) []FieldChange {
+	var changes []FieldChange
+	if current.IP != spec.IP {
+		changes = append(changes, FieldChange{Field: "ip", From: current.IP, To: spec.IP})
+	}
+	if current.Port != spec.Port {
+		changes = append(changes, FieldChange{Field: "port", From: current.Port, To: spec.Port})
+	}
+	if current.Location != spec.Location {
+		changes = append(changes, FieldChange{Field: "location", From: current.Location, To: spec.Location})
+	}
+	if current.Group != spec.group {
+		changes = append(changes, FieldChange{Field: "group", From: current.Group, To: spec.group})
+	}
+	if current.CheckInterval != spec.checkInterval {
+		changes = append(changes, FieldChange{Field: "check_interval", From: current.CheckInterval, To: spec.checkInterval})
+	}
+	if current.StartAt != spec.startAt {
+		changes = append(changes, FieldChange{Field: "start_at", From: current.StartAt, To: spec.startAt})
+	}
+	if current.StopAt != spec.stopAt {
+		changes = append(changes, FieldChange{Field: "stop_at", From: current.StopAt, To: spec.stopAt})
+	}
+	return changes
+}
+
+// isTimeOfDay reports whether the value is a time of day written as HH:MM
+func isTimeOfDay(value string) bool {
+	_, err := time.Parse("15:04", value)
+	return err == nil && len(value) == len("15:04")
+}
+
+// sortPlan orders actions by kind: stops, deletes, creates, updates and then starts
+func sortPlan(plan *Plan) {
+	rank := map[string]int{ActionStop: 0, ActionDelete: 1, ActionCreate: 2, ActionUpdate: 3, ActionStart: 4}
+	sort.SliceStable(plan.Actions, func(i, j int) bool {
+		return rank[plan.Actions[i].Action] < rank[plan.Actions[j].Action]
+	})
+}
+
+// String renders the plan in a human readable form
+func (p *Plan) String() string {
+	if len(p.Actions) == 0 {
+		return "No changes. The fleet matches the manifest.\n"
+	}
+
+	symbols := map[string]string{
+		ActionCreate: "+",
+		ActionUpdate: "~",
+		ActionDelete: "-",
+		ActionStart:  ">",
+		ActionStop:   "x",
+	}
+
+	var b strings.Builder
+	for _, action := range p.Actions {
+		fmt.Fprintf(&b, "%s %-6s %s\n", symbols[action.Action], action.Action, action.Node)
+		for _, change := range action.Changes {
+			fmt.Fprintf(&b, "      %s: %v -> %v\n", change.Field, change.From, change.To)
+		}
+	}
+	fmt.Fprintf(&b, "\nPlan: %d to create, %d to update, %d to delete, %d to start, %d to stop.\n",
+		p.Summary[ActionCreate], p.Summary[ActionUpdate], p.Summary[ActionDelete], p.Summary[ActionStart], p.Summary[ActionStop])
+	return b.String()
+}
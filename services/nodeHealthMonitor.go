@@ -1,33 +1,57 @@
 package services
 
 import (
-	"log"
+	"context"
+	"errors"
 	"node_management_application/config"
 	"node_management_application/models"
 	"time"
 )
 
+// healthCheckPeriod is how often the monitor looks for nodes due for a health check
+const healthCheckPeriod = 10 * time.Second
+
 // MonitorNodeHealth periodically checks the health of all nodes
 func MonitorNodeHealth(shutdown chan struct{}) {
-	ticker := time.NewTicker(10 * time.Second)
+	ticker := time.NewTicker(healthCheckPeriod)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-shutdown:
-			log.Println("Health monitoring service shutting down...")
+			healthLogger.Info("Health monitoring service shutting down")
 			return
 		case <-ticker.C:
-			log.Println("Performing health checks for all nodes...")
+			healthLogger.Debug("Performing health checks for all running nodes")
 			var nodes []models.Node
-			config.DB.Where("status = ?","Running").Find(&nodes)
+			if err := config.DB.Where("status = ?", "Running").Find(&nodes).Error; err != nil {
+				healthLogger.Error("Failed to load running nodes for health checks", "error", err)
+				continue
+			}
 			for _, node := range nodes {
+				if !healthCheckDue(&node) {
+					continue
+				}
 				go func(n models.Node) {
-					if err := PerformHealthCheckConcurrently(&n); err != nil {
-						log.Printf("Health check error for node %s: %v", n.Name, err)
+					err := PerformHealthCheckConcurrently(context.Background(), &n)
+					switch {
+					case err == nil, errors.Is(err, ErrNodeUnhealthy):
+						// Unhealthy nodes are logged and announced by the check itself
+					case errors.Is(err, ErrQuotaExceeded):
+						// Checks over quota wait for the next round
+						healthLogger.Debug("Skipped health check over quota", "node_id", n.ID, "error", err)
+					default:
+						healthLogger.Error("Health check could not be completed", "node_id", n.ID, "error", err)
 					}
 				}(node)
 			}
 		}
 	}
 }
+
+// healthCheckDue reports whether a node's check interval has passed since its last check.
+// Checks are due a little early so that they do not slip a whole round behind the ticker.
+func healthCheckDue(node *models.Node) bool {
+	interval := time.Duration(node.CheckInterval) * time.Second
+	return time.Since(node.LastChecked) >= interval-healthCheckPeriod/2
+}
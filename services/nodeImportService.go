@@ -0,0 +1,288 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"node_management_application/config"
+	"node_management_application/events"
+	"node_management_application/models"
+
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+)
+
+// Supported import/export formats
+const (
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+	FormatCSV  = "csv"
+)
+
+// csvHeader is the column order used when exporting nodes as CSV
+var csvHeader = []string{"name", "ip", "port", "location"}
+
+// NodeRecord is the portable representation of a node used for import and export
+type NodeRecord struct {
+	Name     string `json:"name" yaml:"name"`
+	IP       string `json:"ip" yaml:"ip"`
+	Port     int    `json:"port" yaml:"port"`
+	Location string `json:"location,omitempty" yaml:"location,omitempty"`
+}
+
+// RowError describes why a single record of an import was rejected
+type RowError struct {
+	Row   int    `json:"row"`
+	Name  string `json:"name,omitempty"`
+	Error string `json:"error"`
+}
+
+// ImportResult summarizes the outcome of a bulk import
+type ImportResult struct {
+	DryRun   bool          `json:"dry_run"`
+	Imported int           `json:"imported"`
+	Nodes    []models.Node `json:"nodes,omitempty"`
+	Errors   []RowError    `json:"errors,omitempty"`
+}
+
+// ErrImportRejected is returned when at least one record failed and nothing was imported
+var ErrImportRejected = errors.New("import rejected")
+
+// ErrUnsupportedFormat is returned for an import or export format other than JSON, YAML or CSV
+var ErrUnsupportedFormat = errors.New("unsupported format")
+
+// ErrInvalidDocument is returned when an import document cannot be parsed
+var ErrInvalidDocument = errors.New("invalid import document")
+
+// errDryRunRollback aborts the import transaction once a dry run has been validated
+var errDryRunRollback = errors.New("dry run")
+
+// NormalizeFormat maps a format name or content type to one of the supported formats
+func NormalizeFormat(value string) (string, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if i := strings.Index(value, ";"); i >= 0 {
+		value = strings.TrimSpace(value[:i])
+	}
+
+	switch value {
+	case "", FormatJSON, "application/json":
+		return FormatJSON, nil
+	case FormatYAML, "yml", "application/yaml", "application/x-yaml", "text/yaml", "text/x-yaml":
+		return FormatYAML, nil
+	case FormatCSV, "text/csv", "application/csv":
+		return FormatCSV, nil
+	}
+	return "", fmt.Errorf("%w %q (expected json, yaml or csv)", ErrUnsupportedFormat, value)
+}
+
+// ContentTypeFor returns the response content type for a supported format
+func ContentTypeFor(format string) string {
+	switch format {
+	case FormatYAML:
+		return "application/yaml"
+	case FormatCSV:
+		return "text/csv"
+	default:
+		return "application/json"
+	}
+}
+
+// ParseNodeRecords decodes a JSON array, YAML sequence or CSV document into node records
+func ParseNodeRecords(format string, data []byte) ([]NodeRecord, error) {
+	var records []NodeRecord
+
+	switch format {
+	case FormatJSON:
+		if err := json.Unmarshal(data, &records); err != nil {
+			return nil, fmt.Errorf("%w: invalid JSON document: %v", ErrInvalidDocument, err)
+		}
+	case FormatYAML:
+		if err := yaml.Unmarshal(data, &records); err != nil {
+			return nil, fmt.Errorf("%w: invalid YAML document: %v", ErrInvalidDocument, err)
+		}
+	case FormatCSV:
+		return parseCSVRecords(data)
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnsupportedFormat, format)
+	}
+
+	return records, nil
+}
+
+// parseCSVRecords reads a CSV document whose first line names the columns
+func parseCSVRecords(data []byte) ([]NodeRecord, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid CSV header: %v", ErrInvalidDocument, err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, column := range header {
+		columns[strings.ToLower(strings.TrimSpace(column))] = i
+	}
+	for _, required := range []string{"name", "ip", "port"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("%w: CSV header is missing the %q column", ErrInvalidDocument, required)
+		}
+	}
+
+	field := func(row []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	var records []NodeRecord
+	for line := 1; ; line++ {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid CSV row %d: %v", ErrInvalidDocument, line, err)
+		}
+
+		record := NodeRecord{
+			Name:     field(row, "name"),
+			IP:       field(row, "ip"),
+			Location: field(row, "location"),
+		}
+		if port := field(row, "port"); port != "" {
+			if record.Port, err = strconv.Atoi(port); err != nil {
+				return nil, fmt.Errorf("%w: invalid CSV row %d: port %q is not a number", ErrInvalidDocument, line, port)
+			}
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+// EncodeNodeRecords serializes nodes in the requested export format
+func EncodeNodeRecords(format string, nodes []models.Node) ([]byte, error) {
+	records := make([]NodeRecord, 0, len(nodes))
+	for _, node := range nodes {
+		records = append(records, NodeRecord{
+			Name:     node.Name,
+			IP:       node.IP,
+			Port:     node.Port,
+			Location: node.Location,
+		})
+	}
+
+	switch format {
+	case FormatJSON:
+		return json.MarshalIndent(records, "", "  ")
+	case FormatYAML:
+		return yaml.Marshal(records)
+	case FormatCSV:
+		var buf bytes.Buffer
+		writer := csv.NewWriter(&buf)
+		writer.Write(csvHeader)
+		for _, record := range records {
+			writer.Write([]string{record.Name, record.IP, strconv.Itoa(record.Port), record.Location})
+		}
+		writer.Flush()
+		return buf.Bytes(), writer.Error()
+	}
+	return nil, fmt.Errorf("%w %q", ErrUnsupportedFormat, format)
+}
+
+// ImportNodes validates every record and creates the nodes for the user in a single transaction.
+// When any record is invalid nothing is written and ErrImportRejected is returned alongside the row errors.
+func ImportNodes(userID uint, records []NodeRecord, dryRun bool) (*ImportResult, error) {
+	result := &ImportResult{DryRun: dryRun}
+
+	subject := userSubject(userID)
+	for i, record := range records {
+		if err := ValidateNodeData(record.Name, record.IP, record.Port); err != nil {
+			result.Errors = append(result.Errors, RowError{Row: i + 1, Name: record.Name, Error: err.Error()})
+			continue
+		}
+		if err := checkPortQuota(subject, record.Port); errors.Is(err, ErrQuotaExceeded) {
+			result.Errors = append(result.Errors, RowError{Row: i + 1, Name: record.Name, Error: err.Error()})
+		} else if err != nil {
+			return result, err
+		}
+	}
+	if len(result.Errors) > 0 {
+		return result, ErrImportRejected
+	}
+
+	nodes := make([]models.Node, 0, len(records))
+	for _, record := range records {
+		nodes = append(nodes, models.Node{
+			UserID:       userID,
+			Name:         record.Name,
+			IP:           record.IP,
+			Port:         record.Port,
+			Location:     record.Location,
+			Status:       "Stopped",
+			HealthStatus: "Unhealthy",
+			LastChecked:  time.Now(),
+		})
+	}
+
+	// Hold the quota lock until the transaction commits so concurrent creates count each other
+	unlock := lockQuota(subject)
+	defer unlock()
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		for i := range nodes {
+			if err := tx.Create(&nodes[i]).Error; err != nil {
+				result.Errors = append(result.Errors, RowError{Row: i + 1, Name: nodes[i].Name, Error: "failed to save node to database"})
+				return ErrImportRejected
+			}
+			if err := ReservePort(tx, &nodes[i]); err != nil {
+				message := "failed to reserve port"
+				if errors.Is(err, ErrPortConflict) {
+					message = err.Error()
+				}
+				result.Errors = append(result.Errors, RowError{Row: i + 1, Name: nodes[i].Name, Error: message})
+				return ErrImportRejected
+			}
+		}
+
+		// A dry run is rejected over quota too
+		if err := checkNodeQuota(tx, subject); err != nil {
+			return err
+		}
+
+		// Roll back after the inserts so a dry run exercises the same constraints
+		if dryRun {
+			return errDryRunRollback
+		}
+		return nil
+	})
+	if err != nil && err != errDryRunRollback {
+		return result, err
+	}
+
+	if dryRun {
+		// IDs from the rolled back inserts were never committed
+		for i := range nodes {
+			nodes[i].ID = 0
+		}
+	} else {
+		result.Imported = len(nodes)
+		for i := range nodes {
+			events.PublishNode(events.NodeCreated, &nodes[i])
+		}
+	}
+	result.Nodes = nodes
+	return result, nil
+}
@@ -0,0 +1,236 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"node_management_application/config"
+	"node_management_application/events"
+	"node_management_application/models"
+
+	"gorm.io/gorm"
+)
+
+// NodeSpec is what a client supplies to create a node. AutoPort takes a port from the
+// configured pool, and SubnetID without an IP allocates a free address of that subnet.
+type NodeSpec struct {
+	Name     string
+	IP       string
+	Port     int
+	Location string
+	AutoPort bool
+	SubnetID uint
+}
+
+// NodeUpdate holds the editable fields of a node
+type NodeUpdate struct {
+	Name     string
+	IP       string
+	Port     int
+	Location string
+}
+
+// CreateNode validates a node spec, saves the node for the user and reserves its port
+func CreateNode(ctx context.Context, userID uint, spec NodeSpec) (*models.Node, error) {
+	node := models.Node{Name: spec.Name, IP: spec.IP, Port: spec.Port, Location: spec.Location}
+
+	// Allocate an address from the subnet when none was given, holding the allocation lock
+	// until the node is saved so concurrent creates cannot pick the same address
+	if node.IP == "" && spec.SubnetID != 0 {
+		unlock := lockAddressAllocation()
+		defer unlock()
+
+		ip, err := AllocateAddress(spec.SubnetID)
+		if err != nil {
+			return nil, err
+		}
+		node.IP = ip
+	}
+
+	// The port is validated only when the caller picked it
+	var err error
+	if spec.AutoPort {
+		err = collectValidation(ValidateNodeName(node.Name), ValidateNodeIP(node.IP))
+	} else {
+		err = ValidateNodeData(node.Name, node.IP, node.Port)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Catch an address the node could never bind; allocated addresses are local already
+	if spec.IP != "" {
+		if err := ValidateLocalAddress(node.IP); err != nil {
+			return nil, err
+		}
+	}
+
+	// A port the caller picked has to be one their quota allows
+	subject := userSubject(userID)
+	if !spec.AutoPort {
+		if err := checkPortQuota(subject, node.Port); err != nil {
+			return nil, err
+		}
+	}
+
+	node.UserID = userID
+	node.LastChecked = time.Now()
+	node.Status = "Stopped"
+	node.HealthStatus = "Unhealthy"
+
+	// Hold the quota lock until the transaction commits so concurrent creates count each other
+	unlock := lockQuota(subject)
+	defer unlock()
+
+	// Save the node and reserve its port together
+	err = config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&node).Error; err != nil {
+			return err
+		}
+		if err := checkNodeQuota(tx, subject); err != nil {
+			return err
+		}
+		return AssignPort(tx, &node, spec.AutoPort)
+	})
+	if err != nil {
+		return nil, err
+	}
+	events.PublishNode(events.NodeCreated, &node)
+	return &node, nil
+}
+
+// UpdateNode replaces the editable fields of a node, moving its port reservation when the
+// address changes. ErrVersionConflict means the node was changed since it was loaded.
+//
+// The listener of a running node is bound to its address, so changing the IP or port of a
+// running node fails with ErrNodeRunning unless restart is set. With restart the node is
+// stopped on its old address, saved and started on the new one. If the save fails it is
+// started again on its old address; if only the new start fails the error wraps
+// ErrNodeRestartFailed and node holds the saved fields.
+func UpdateNode(ctx context.Context, node *models.Node, update NodeUpdate, restart bool) error {
+	if err := ValidateNodeData(update.Name, update.IP, update.Port); err != nil {
+		return err
+	}
+
+	columns := map[string]interface{}{}
+	if update.Name != node.Name {
+		columns["name"] = update.Name
+	}
+	if update.IP != node.IP {
+		columns["ip"] = update.IP
+	}
+	if update.Port != node.Port {
+		columns["port"] = update.Port
+	}
+	if update.Location != node.Location {
+		columns["location"] = update.Location
+	}
+	if len(columns) == 0 {
+		return nil
+	}
+
+	addressChanged := node.IP != update.IP || node.Port != update.Port
+	if addressChanged {
+		// Reject an address already reserved by another node before touching anything
+		if err := CheckPortConflict(update.IP, update.Port, node.ID); err != nil {
+			return err
+		}
+	}
+	if update.Port != node.Port {
+		if err := CheckNodePortQuota(node, update.Port); err != nil {
+			return err
+		}
+	}
+
+	restarting := addressChanged && node.Status == "Running"
+	if restarting && !restart {
+		return fmt.Errorf("%w; restart it to change its IP or port", ErrNodeRunning)
+	}
+
+	// Stop on the old address before the new one is saved. From here on the update runs to
+	// the end, so a client giving up cannot leave the node stopped.
+	if restarting {
+		if err := StopNode(ctx, node); err != nil {
+			return err
+		}
+		ctx = context.WithoutCancel(ctx)
+	}
+
+	updated := *node
+	updated.Name = update.Name
+	updated.IP = update.IP
+	updated.Port = update.Port
+	updated.Location = update.Location
+
+	// Save changes and move the port reservation together, unless someone else saved first
+	err := config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := UpdateNodeIfVersion(tx, &updated, columns); err != nil {
+			return err
+		}
+		if addressChanged {
+			return ReservePort(tx, &updated)
+		}
+		return nil
+	})
+	if err != nil {
+		// Bring the node back on its old address if the update did not go through
+		if restarting {
+			if startErr := StartNode(ctx, node); startErr != nil {
+				nodeLogger.ErrorContext(ctx, "Failed to restart node after a rejected update", "node_id", node.ID, "error", startErr)
+			}
+		}
+		return err
+	}
+
+	*node = updated
+	events.PublishNode(events.NodeUpdated, node)
+
+	if restarting {
+		if err := StartNode(ctx, node); err != nil {
+			return fmt.Errorf("%w: %w", ErrNodeRestartFailed, err)
+		}
+		node.Status = "Running"
+	}
+	return nil
+}
+
+// DeleteNode removes a node with its grants and releases its port, unless it was changed since
+// it was loaded
+func DeleteNode(ctx context.Context, node *models.Node) error {
+	err := config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := DeleteNodeIfVersion(tx, node); err != nil {
+			return err
+		}
+		if err := tx.Where("node_id = ?", node.ID).Delete(&models.NodeGrant{}).Error; err != nil {
+			return err
+		}
+		return ReleasePort(tx, node.ID)
+	})
+	if err != nil {
+		return err
+	}
+	events.PublishNode(events.NodeDeleted, node)
+	return nil
+}
+
+// StopRunningNode stops the node's server and records it as stopped.
+// Unlike StopNode it fails with ErrNodeNotRunning when there is no server to stop.
+// It can only be cancelled before the server is touched.
+func StopRunningNode(ctx context.Context, node *models.Node) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := StopNodeService(ctx, node); err != nil {
+		return err
+	}
+
+	if err := config.DB.WithContext(context.WithoutCancel(ctx)).Model(node).Updates(map[string]interface{}{
+		"status":        "Stopped",
+		"health_status": "Unhealthy",
+		"last_checked":  time.Now(),
+	}).Error; err != nil {
+		return fmt.Errorf("failed to update node status: %v", err)
+	}
+	return nil
+}
@@ -2,14 +2,36 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
 	"net"
 	"net/http"
 	"sync"
 	"time"
 
+	"node_management_application/config"
+	"node_management_application/events"
+	"node_management_application/logging"
 	"node_management_application/models"
+	"node_management_application/tracing"
+	"node_management_application/traffic"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var nodeLogger = logging.For("nodes")
+
+var (
+	// ErrPortInUse is returned when a node's address is already bound by another process
+	ErrPortInUse = errors.New("port is already in use")
+	// ErrNodeNotRunning is returned when stopping a node that has no running server
+	ErrNodeNotRunning = errors.New("node is not running")
+	// ErrNodeRunning is returned when moving a running node to another address without restarting it
+	ErrNodeRunning = errors.New("node is running")
+	// ErrNodeRestartFailed is returned with the cause when a node was updated but did not start again
+	ErrNodeRestartFailed = errors.New("node was updated but failed to restart")
 )
 
 // ServerStore to keep track of running node servers
@@ -19,7 +41,16 @@ var serverStore = sync.Map{}
 var ipPortLocks = sync.Map{}
 
 // StartNodeConcurrently starts a node with concurrency control
-func StartNodeConcurrently(node *models.Node) error {
+func StartNodeConcurrently(ctx context.Context, node *models.Node) (err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "StartNodeConcurrently", trace.WithAttributes(tracing.NodeAttributes(node.ID, node.UserID)...))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
 	ipPortKey := fmt.Sprintf("%s:%d", node.IP, node.Port)
 
 	// Acquire lock for the IP:Port
@@ -27,10 +58,18 @@ func StartNodeConcurrently(node *models.Node) error {
 	mutex := lock.(*sync.Mutex)
 	mutex.Lock()
 	defer mutex.Unlock()
+	span.AddEvent("address lock acquired")
+
+	// Hold the quota lock until the server is tracked so concurrent starts count each other
+	unlock := lockQuota(nodeSubject(node))
+	defer unlock()
+	if err := checkRunningQuota(node); err != nil {
+		return err
+	}
 
 	// Check if the port is available
 	if !isPortAvailable(node.IP, node.Port) {
-		return fmt.Errorf("port %d on IP %s is already in use", node.Port, node.IP)
+		return fmt.Errorf("%w: %s:%d", ErrPortInUse, node.IP, node.Port)
 	}
 
 	// Create an HTTP server for the node
@@ -38,33 +77,62 @@ func StartNodeConcurrently(node *models.Node) error {
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprintf(w, "Node %s is running at %s:%d", node.Name, node.IP, node.Port)
 	})
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "ok")
+	})
 
+	// Start the traffic counters over, or continue them, before the first request arrives
+	traffic.Begin(node.ID)
+
+	// The handler continues the trace of each request, such as a health check, inside the node
+	// runtime. Health probes are left out of the node's traffic statistics.
+	traced := otelhttp.NewHandler(mux, fmt.Sprintf("node %d", node.ID))
+	counted := traffic.Middleware(node.ID, traced)
 	server := &http.Server{
-		Addr:    fmt.Sprintf("%s:%d", node.IP, node.Port),
-		Handler: mux,
+		Addr: fmt.Sprintf("%s:%d", node.IP, node.Port),
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/healthz" {
+				traced.ServeHTTP(w, r)
+				return
+			}
+			counted.ServeHTTP(w, r)
+		}),
 	}
 
 	// Track the server in the store
 	serverStore.Store(node.ID, server)
 
 	// Start the server in a goroutine
+	nodeLogger.InfoContext(ctx, "Starting node server", "node_id", node.ID, "address", server.Addr)
 	go func() {
-		log.Printf("Starting node server: %s:%d", node.IP, node.Port)
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Printf("Node server %s:%d stopped with error: %v", node.IP, node.Port, err)
+			nodeLogger.Error("Node server stopped with error", "node_id", node.ID, "address", server.Addr, "error", err)
 			// Clean up on failure
 			serverStore.Delete(node.ID)
+			events.Publish(events.Event{Type: events.NodeCrashed, UserID: node.UserID, NodeID: node.ID, Data: events.CrashedData{Error: err.Error()}})
 		}
 	}()
 
+	started := *node
+	started.Status = "Running"
+	events.PublishNode(events.NodeStarted, &started)
 	return nil
 }
 
 // StopNodeService stops a running node server
-func StopNodeService(node *models.Node) error {
+func StopNodeService(ctx context.Context, node *models.Node) (err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "StopNodeService", trace.WithAttributes(tracing.NodeAttributes(node.ID, node.UserID)...))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
 	value, ok := serverStore.Load(node.ID)
 	if !ok {
-		return fmt.Errorf("no running server found for node ID %d", node.ID)
+		return fmt.Errorf("%w: no running server found for node ID %d", ErrNodeNotRunning, node.ID)
 	}
 
 	server, ok := value.(*http.Server)
@@ -72,17 +140,59 @@ func StopNodeService(node *models.Node) error {
 		return fmt.Errorf("failed to retrieve server instance for node ID %d", node.ID)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	// Once begun, the shutdown runs to completion even if the caller gives up; ctx only carries the span
+	shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
 	defer cancel()
 
-	log.Printf("Stopping node server: %s", server.Addr)
-	if err := server.Shutdown(ctx); err != nil {
+	nodeLogger.InfoContext(ctx, "Stopping node server", "node_id", node.ID, "address", server.Addr)
+	if err := server.Shutdown(shutdownCtx); err != nil {
 		return fmt.Errorf("failed to shutdown server for node ID %d: %v", node.ID, err)
 	}
 
 	// Remove the server from the store and release the IP:Port lock
 	serverStore.Delete(node.ID)
 	ipPortLocks.Delete(fmt.Sprintf("%s:%d", node.IP, node.Port))
+	stopped := *node
+	stopped.Status = "Stopped"
+	events.PublishNode(events.NodeStopped, &stopped)
+	return nil
+}
+
+// StartNode starts the node's server and records it as running. It can only be cancelled
+// before the server is started; after that the start is recorded even if ctx ends.
+func StartNode(ctx context.Context, node *models.Node) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := StartNodeConcurrently(ctx, node); err != nil {
+		return err
+	}
+
+	if err := config.DB.WithContext(context.WithoutCancel(ctx)).Model(node).Update("status", "Running").Error; err != nil {
+		return fmt.Errorf("failed to update node status: %v", err)
+	}
+	return nil
+}
+
+// StopNode stops the node's server, if any, and records it as stopped. Like StartNode it can
+// only be cancelled before the server is touched.
+func StopNode(ctx context.Context, node *models.Node) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if _, running := serverStore.Load(node.ID); running {
+		if err := StopNodeService(ctx, node); err != nil {
+			return err
+		}
+	}
+
+	if err := config.DB.WithContext(context.WithoutCancel(ctx)).Model(node).Updates(map[string]interface{}{
+		"status":        "Stopped",
+		"health_status": "Unhealthy",
+		"last_checked":  time.Now(),
+	}).Error; err != nil {
+		return fmt.Errorf("failed to update node status: %v", err)
+	}
 	return nil
 }
 
@@ -91,7 +201,7 @@ func isPortAvailable(ip string, port int) bool {
 	address := fmt.Sprintf("%s:%d", ip, port)
 	listener, err := net.Listen("tcp", address)
 	if err != nil {
-		log.Printf("Port check failed for %s: %v", address, err)
+		nodeLogger.Debug("Port check failed", "address", address, "error", err)
 		return false // Port is already in use or permission denied
 	}
 	defer listener.Close()
@@ -105,9 +215,9 @@ func StopAllNodes() {
 		defer cancel()
 
 		if err := server.Shutdown(ctx); err != nil {
-			log.Printf("Failed to shutdown server %s: %v", server.Addr, err)
+			nodeLogger.Error("Failed to shut down node server", "node_id", key, "address", server.Addr, "error", err)
 		} else {
-			log.Printf("Server %s stopped successfully", server.Addr)
+			nodeLogger.Info("Node server stopped", "node_id", key, "address", server.Addr)
 		}
 		serverStore.Delete(key)
 		return true
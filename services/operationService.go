@@ -0,0 +1,268 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"node_management_application/config"
+	"node_management_application/events"
+	"node_management_application/logging"
+	"node_management_application/models"
+)
+
+// operationPruneInterval is how many operations are submitted between trims of the history
+const operationPruneInterval = 100
+
+// operationListLimit caps the operations returned by one listing
+const operationListLimit = 500
+
+// ErrOperationFinished is returned when cancelling an operation that already reached a final status
+var ErrOperationFinished = errors.New("operation already finished")
+
+var operationLogger = logging.For("operations")
+
+var (
+	// runningOperations holds the cancel function of every operation running in this process
+	runningOperations sync.Map
+	operationsWG      sync.WaitGroup
+	operationCount    atomic.Uint64
+)
+
+// OperationTask describes the background work of an operation
+type OperationTask struct {
+	UserID uint
+	NodeID uint
+	Type   string
+
+	// Run performs the work, reporting progress as it goes, and returns the result to store.
+	// Its context is cancelled when the operation is cancelled.
+	Run func(ctx context.Context, report func(percent int, message string)) (interface{}, error)
+
+	// Describe turns a failure into the stable error code and message stored with the operation
+	Describe func(error) (code string, message string)
+}
+
+// OperationFilter narrows a listing of operations; zero values match everything
+type OperationFilter struct {
+	NodeID uint
+	Status string
+	Limit  int
+}
+
+// SubmitOperation records a pending operation and runs it in the background. The request
+// context only contributes its values, such as the request ID; cancelling it does not stop the work.
+func SubmitOperation(ctx context.Context, task OperationTask) (*models.Operation, error) {
+	operation := &models.Operation{UserID: task.UserID, NodeID: task.NodeID, Type: task.Type, Status: models.OperationPending}
+	if err := config.DB.WithContext(ctx).Create(operation).Error; err != nil {
+		return nil, err
+	}
+
+	if operationCount.Add(1)%operationPruneInterval == 0 {
+		pruneOperations()
+	}
+
+	runCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	runningOperations.Store(operation.ID, cancel)
+	operationsWG.Add(1)
+
+	snapshot := *operation
+	go func() {
+		defer operationsWG.Done()
+		defer runningOperations.Delete(snapshot.ID)
+		defer cancel()
+		runOperation(runCtx, &snapshot, task)
+	}()
+	return operation, nil
+}
+
+// runOperation carries out an operation and records every step of it
+func runOperation(ctx context.Context, operation *models.Operation, task OperationTask) {
+	now := time.Now()
+	if !updateOperation(operation, map[string]interface{}{"status": models.OperationRunning, "started_at": now}) {
+		return
+	}
+	operation.Status = models.OperationRunning
+	operation.StartedAt = &now
+	events.PublishOperation(events.OperationStarted, operation)
+
+	report := func(percent int, message string) {
+		if percent < operation.Progress || percent > 100 {
+			return
+		}
+		if updateOperation(operation, map[string]interface{}{"progress": percent, "message": message}) {
+			operation.Progress = percent
+			operation.Message = message
+			events.PublishOperation(events.OperationProgress, operation)
+		}
+	}
+
+	var result interface{}
+	err := ctx.Err()
+	if err == nil {
+		result, err = task.Run(ctx, report)
+	}
+
+	// Work that completed despite a late cancellation is reported as done, and work that
+	// failed for another reason as failed
+	finished := time.Now()
+	changes := map[string]interface{}{"finished_at": finished}
+	eventType := events.OperationSucceeded
+	switch {
+	case err == nil:
+		encoded, encodeErr := json.Marshal(result)
+		if encodeErr != nil {
+			operationLogger.ErrorContext(ctx, "Failed to encode operation result", "operation_id", operation.ID, "error", encodeErr)
+		} else if result != nil {
+			operation.Result = encoded
+			changes["result"] = string(encoded)
+		}
+		operation.Status = models.OperationSucceeded
+		operation.Progress = 100
+		changes["progress"] = 100
+	case errors.Is(err, context.Canceled) && ctx.Err() != nil:
+		operation.Status = models.OperationCancelled
+		operation.Error = "operation was cancelled"
+		eventType = events.OperationCancelled
+	default:
+		operation.Status = models.OperationFailed
+		operation.Error = err.Error()
+		if task.Describe != nil {
+			operation.ErrorCode, operation.Error = task.Describe(err)
+		}
+		changes["error_code"] = operation.ErrorCode
+		eventType = events.OperationFailed
+	}
+	changes["status"] = operation.Status
+	changes["error"] = operation.Error
+	operation.FinishedAt = &finished
+
+	if updateOperation(operation, changes) {
+		events.PublishOperation(eventType, operation)
+	}
+	operationLogger.InfoContext(ctx, "Operation finished", "operation_id", operation.ID, "type", operation.Type,
+		"node_id", operation.NodeID, "status", operation.Status, "error", operation.Error)
+}
+
+// updateOperation saves changes to an operation; the work goes on even if the history cannot be written
+func updateOperation(operation *models.Operation, changes map[string]interface{}) bool {
+	if err := config.DB.Model(&models.Operation{}).Where("id = ?", operation.ID).Updates(changes).Error; err != nil {
+		operationLogger.Error("Failed to update operation", "operation_id", operation.ID, "error", err)
+		return false
+	}
+	return true
+}
+
+// GetUserOperation returns an operation of a user; someone else's is reported as not found
+func GetUserOperation(ctx context.Context, userID uint, id uint) (*models.Operation, error) {
+	var operation models.Operation
+	if err := config.DB.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).First(&operation).Error; err != nil {
+		return nil, err
+	}
+	return &operation, nil
+}
+
+// ListUserOperations returns the operations of a user, newest first
+func ListUserOperations(ctx context.Context, userID uint, filter OperationFilter) ([]models.Operation, error) {
+	limit := filter.Limit
+	if limit <= 0 || limit > operationListLimit {
+		limit = operationListLimit
+	}
+
+	query := config.DB.WithContext(ctx).Where("user_id = ?", userID)
+	if filter.NodeID != 0 {
+		query = query.Where("node_id = ?", filter.NodeID)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+
+	var operations []models.Operation
+	if err := query.Order("id DESC").Limit(limit).Find(&operations).Error; err != nil {
+		return nil, err
+	}
+	return operations, nil
+}
+
+// CancelOperation asks a pending or running operation of a user to stop. The operation
+// reaches the cancelled status once its work notices; work that already completed still succeeds.
+func CancelOperation(ctx context.Context, userID uint, id uint) (*models.Operation, error) {
+	operation, err := GetUserOperation(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if operation.Finished() {
+		return nil, ErrOperationFinished
+	}
+
+	if err := config.DB.WithContext(ctx).Model(operation).Update("cancel_requested", true).Error; err != nil {
+		return nil, err
+	}
+
+	if cancel, ok := runningOperations.Load(operation.ID); ok {
+		cancel.(context.CancelFunc)()
+		return operation, nil
+	}
+
+	// Nothing runs it any more, e.g. after a restart; record the cancellation directly unless
+	// the operation finished in the meantime
+	now := time.Now()
+	result := config.DB.WithContext(ctx).Model(&models.Operation{}).
+		Where("id = ? AND status IN ?", operation.ID, []string{models.OperationPending, models.OperationRunning}).
+		Updates(map[string]interface{}{
+			"status": models.OperationCancelled, "error": "operation was cancelled", "finished_at": now,
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return GetUserOperation(ctx, userID, id)
+	}
+
+	operation.Status = models.OperationCancelled
+	operation.Error = "operation was cancelled"
+	operation.FinishedAt = &now
+	events.PublishOperation(events.OperationCancelled, operation)
+	return operation, nil
+}
+
+// RecoverOperations fails the operations a previous run of the server left unfinished
+func RecoverOperations() error {
+	return config.DB.Model(&models.Operation{}).
+		Where("status IN ?", []string{models.OperationPending, models.OperationRunning}).
+		Updates(map[string]interface{}{
+			"status":      models.OperationFailed,
+			"error":       "interrupted by a server restart",
+			"finished_at": time.Now(),
+		}).Error
+}
+
+// CancelOperations cancels the running operations and waits for them to stop, up to the timeout
+func CancelOperations(timeout time.Duration) {
+	runningOperations.Range(func(_, cancel interface{}) bool {
+		cancel.(context.CancelFunc)()
+		return true
+	})
+
+	done := make(chan struct{})
+	go func() {
+		operationsWG.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		operationLogger.Warn("Operations still running at shutdown")
+	}
+}
+
+// pruneOperations deletes finished operations older than the configured retention
+func pruneOperations() {
+	cutoff := time.Now().Add(-config.OperationRetention)
+	if err := config.DB.Where("finished_at IS NOT NULL AND finished_at < ?", cutoff).Delete(&models.Operation{}).Error; err != nil {
+		operationLogger.Warn("Failed to prune operation history", "error", err)
+	}
+}
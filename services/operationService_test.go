@@ -0,0 +1,148 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"node_management_application/config"
+	"node_management_application/models"
+	"node_management_application/services"
+	"node_management_application/testutil"
+)
+
+// useOperations sets up a database for operations and waits for the submitted ones to stop
+// before it goes away
+func useOperations(t *testing.T) {
+	testutil.UseDatabase(t)
+	t.Cleanup(func() { services.CancelOperations(5 * time.Second) })
+}
+
+// waitForOperation polls an operation of user 1 until it reaches a final status. The result
+// is left out: SQLite hands text back as a string, which json.RawMessage cannot scan.
+func waitForOperation(t *testing.T, id uint) *models.Operation {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		operation := &models.Operation{}
+		err := config.DB.Omit("result").Where("id = ? AND user_id = ?", id, 1).First(operation).Error
+		if err != nil {
+			t.Fatal(err)
+		}
+		if operation.Finished() {
+			return operation
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("operation %d is still %s", id, operation.Status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestOperationSucceeds(t *testing.T) {
+	useOperations(t)
+	operation, err := services.SubmitOperation(context.Background(), services.OperationTask{
+		UserID: 1, Type: "test.succeed",
+		Run: func(ctx context.Context, report func(int, string)) (interface{}, error) {
+			report(50, "halfway")
+			return map[string]string{"answer": "42"}, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if operation.Status != models.OperationPending {
+		t.Errorf("submitted operation is %s, want pending", operation.Status)
+	}
+
+	finished := waitForOperation(t, operation.ID)
+	if finished.Status != models.OperationSucceeded || finished.Progress != 100 {
+		t.Errorf("got %s at %d%%, want succeeded at 100%%", finished.Status, finished.Progress)
+	}
+	var results []string
+	config.DB.Model(&models.Operation{}).Where("id = ?", operation.ID).Pluck("result", &results)
+	if len(results) != 1 || results[0] != `{"answer":"42"}` {
+		t.Errorf("stored result %q", results)
+	}
+	if finished.StartedAt == nil || finished.FinishedAt == nil {
+		t.Error("start and finish times are not recorded")
+	}
+}
+
+func TestOperationFails(t *testing.T) {
+	useOperations(t)
+	operation, err := services.SubmitOperation(context.Background(), services.OperationTask{
+		UserID: 1, Type: "test.fail",
+		Run: func(ctx context.Context, report func(int, string)) (interface{}, error) {
+			return nil, errors.New("port is taken")
+		},
+		Describe: func(err error) (string, string) { return "port_in_use", err.Error() },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	finished := waitForOperation(t, operation.ID)
+	if finished.Status != models.OperationFailed || finished.ErrorCode != "port_in_use" || finished.Error != "port is taken" {
+		t.Errorf("got %s with %s: %s", finished.Status, finished.ErrorCode, finished.Error)
+	}
+	if _, err := services.CancelOperation(context.Background(), 1, operation.ID); !errors.Is(err, services.ErrOperationFinished) {
+		t.Errorf("cancelling a finished operation: got %v, want ErrOperationFinished", err)
+	}
+}
+
+func TestCancelRunningOperation(t *testing.T) {
+	useOperations(t)
+	started := make(chan struct{})
+	operation, err := services.SubmitOperation(context.Background(), services.OperationTask{
+		UserID: 1, Type: "test.wait",
+		Run: func(ctx context.Context, report func(int, string)) (interface{}, error) {
+			close(started)
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-started
+
+	if _, err := services.CancelOperation(context.Background(), 1, operation.ID); err != nil {
+		t.Fatal(err)
+	}
+	finished := waitForOperation(t, operation.ID)
+	if finished.Status != models.OperationCancelled || !finished.CancelRequested {
+		t.Errorf("got %s with cancel requested %v, want cancelled", finished.Status, finished.CancelRequested)
+	}
+}
+
+func TestCancelOrphanedOperation(t *testing.T) {
+	useOperations(t)
+	ctx := context.Background()
+
+	// Nothing in this process runs an operation left over from a previous run
+	orphan := models.Operation{ID: 1000, UserID: 1, Type: "test.orphan", Status: models.OperationRunning}
+	if err := config.DB.Create(&orphan).Error; err != nil {
+		t.Fatal(err)
+	}
+	cancelled, err := services.CancelOperation(ctx, 1, orphan.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cancelled.Status != models.OperationCancelled || cancelled.FinishedAt == nil {
+		t.Errorf("got %s, want cancelled", cancelled.Status)
+	}
+	stored, err := services.GetUserOperation(ctx, 1, orphan.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != models.OperationCancelled {
+		t.Errorf("stored operation is %s, want cancelled", stored.Status)
+	}
+
+	// Someone else's operation is not found
+	if _, err := services.CancelOperation(ctx, 2, orphan.ID); err == nil {
+		t.Error("cancelled another user's operation")
+	}
+}
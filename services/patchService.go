@@ -0,0 +1,58 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+)
+
+// Patch media types accepted by PATCH endpoints
+const (
+	MergePatchContentType = "application/merge-patch+json"
+	JSONPatchContentType  = "application/json-patch+json"
+)
+
+// ErrUnsupportedPatchType is returned for PATCH requests with an unknown content type
+var ErrUnsupportedPatchType = errors.New("unsupported patch content type (use application/merge-patch+json or application/json-patch+json)")
+
+// ErrInvalidPatch is returned when a patch cannot be parsed or applied
+var ErrInvalidPatch = errors.New("invalid patch")
+
+// ApplyPatch applies a JSON Merge Patch (RFC 7396) or JSON Patch (RFC 6902) to a resource
+// and decodes the result into target, rejecting fields the resource does not have
+func ApplyPatch(contentType string, resource interface{}, patch []byte, target interface{}) error {
+	original, err := json.Marshal(resource)
+	if err != nil {
+		return err
+	}
+
+	var patched []byte
+	switch contentType {
+	case MergePatchContentType, "application/json", "":
+		patched, err = jsonpatch.MergePatch(original, patch)
+		if err != nil {
+			return fmt.Errorf("%w: invalid merge patch: %v", ErrInvalidPatch, err)
+		}
+	case JSONPatchContentType:
+		operations, err := jsonpatch.DecodePatch(patch)
+		if err != nil {
+			return fmt.Errorf("%w: invalid JSON patch: %v", ErrInvalidPatch, err)
+		}
+		patched, err = operations.Apply(original)
+		if err != nil {
+			return fmt.Errorf("%w: failed to apply JSON patch: %v", ErrInvalidPatch, err)
+		}
+	default:
+		return ErrUnsupportedPatchType
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(patched))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(target); err != nil {
+		return fmt.Errorf("%w: invalid patched document: %v", ErrInvalidPatch, err)
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"node_management_application/config"
+	"node_management_application/logging"
+	"node_management_application/models"
+
+	"gorm.io/gorm"
+)
+
+var portLogger = logging.For("ports")
+
+var (
+	// ErrPortConflict is returned when an IP:port is already reserved by another node
+	ErrPortConflict = errors.New("port is already assigned to another node")
+	// ErrNoFreePort is returned when no configured range has a free port left for an IP
+	ErrNoFreePort = errors.New("no free port available in the configured ranges")
+)
+
+// wildcardIPs bind every local address, so they conflict with any IP on the same port
+var wildcardIPs = []string{"0.0.0.0", "::"}
+
+// ValidatePortRange checks an admin supplied port range
+func ValidatePortRange(r *models.PortRange) error {
+	if r.IP != "" && net.ParseIP(r.IP) == nil {
+		return invalid("ip", "invalid IP address format")
+	}
+	if r.StartPort <= 0 || r.EndPort > 65535 {
+		return invalid("start_port", "ports must be between 1 and 65535")
+	}
+	if r.StartPort > r.EndPort {
+		return invalid("end_port", "start_port must not be greater than end_port")
+	}
+	return nil
+}
+
+// AssignPort reserves the node's IP:port inside tx.
+// With auto set, a free port is picked from the configured ranges and written to the node.
+func AssignPort(tx *gorm.DB, node *models.Node, auto bool) error {
+	if !auto {
+		return ReservePort(tx, node)
+	}
+
+	port, err := allocatePort(tx, node)
+	if err != nil {
+		return err
+	}
+	node.Port = port
+	return tx.Model(node).Update("port", port).Error
+}
+
+// ReservePort records the node's IP:port, replacing any previous reservation of the node
+func ReservePort(tx *gorm.DB, node *models.Node) error {
+	conflict, err := portTaken(tx, node.IP, node.Port, node.ID)
+	if err != nil {
+		return err
+	}
+	if conflict {
+		return fmt.Errorf("%w: %s", ErrPortConflict, net.JoinHostPort(node.IP, fmt.Sprint(node.Port)))
+	}
+
+	if err := tx.Where("node_id = ?", node.ID).Delete(&models.PortReservation{}).Error; err != nil {
+		return err
+	}
+
+	reservation := models.PortReservation{NodeID: node.ID, IP: node.IP, Port: node.Port}
+	if err := tx.Create(&reservation).Error; err != nil {
+		// The unique index catches reservations made concurrently since the check above
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return fmt.Errorf("%w: %s", ErrPortConflict, net.JoinHostPort(node.IP, fmt.Sprint(node.Port)))
+		}
+		return err
+	}
+	return nil
+}
+
+// ReleasePort drops the reservation held by a node
+func ReleasePort(tx *gorm.DB, nodeID uint) error {
+	return tx.Where("node_id = ?", nodeID).Delete(&models.PortReservation{}).Error
+}
+
+// CheckPortConflict reports ErrPortConflict when the IP:port is reserved by a node other than nodeID
+func CheckPortConflict(ip string, port int, nodeID uint) error {
+	conflict, err := portTaken(config.DB, ip, port, nodeID)
+	if err != nil {
+		return err
+	}
+	if conflict {
+		return fmt.Errorf("%w: %s", ErrPortConflict, net.JoinHostPort(ip, fmt.Sprint(port)))
+	}
+	return nil
+}
+
+// SyncPortReservations backfills reservations for nodes created before port tracking existed
+func SyncPortReservations() {
+	var nodes []models.Node
+	if err := config.DB.
+		Where("id NOT IN (?)", config.DB.Model(&models.PortReservation{}).Select("node_id")).
+		Order("id").Find(&nodes).Error; err != nil {
+		portLogger.Error("Failed to load nodes without port reservations", "error", err)
+		return
+	}
+
+	for i := range nodes {
+		if err := ReservePort(config.DB, &nodes[i]); err != nil {
+			portLogger.Warn("Node could not reserve its port", "node_id", nodes[i].ID, "node", nodes[i].Name, "ip", nodes[i].IP, "port", nodes[i].Port, "error", err)
+		}
+	}
+}
+
+// portTaken checks whether another node holds the port on the same or an overlapping address
+func portTaken(tx *gorm.DB, ip string, port int, nodeID uint) (bool, error) {
+	var count int64
+	if err := overlappingReservations(tx, ip, nodeID).Where("port = ?", port).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// overlappingReservations selects reservations of other nodes whose address overlaps ip
+func overlappingReservations(tx *gorm.DB, ip string, nodeID uint) *gorm.DB {
+	query := tx.Model(&models.PortReservation{}).Where("node_id <> ?", nodeID)
+	if !isWildcardIP(ip) {
+		// A wildcard listener overlaps every address, so only specific IPs need filtering
+		query = query.Where("ip = ? OR ip IN ?", ip, wildcardIPs)
+	}
+	return query
+}
+
+// allocatePort walks the ranges configured for the node's IP and reserves the first free port
+func allocatePort(tx *gorm.DB, node *models.Node) (int, error) {
+	var ranges []models.PortRange
+	if err := tx.Where("ip = ? OR ip = ''", node.IP).Order("ip DESC, start_port").Find(&ranges).Error; err != nil {
+		return 0, err
+	}
+
+	var reserved []int
+	if err := overlappingReservations(tx, node.IP, node.ID).Pluck("port", &reserved).Error; err != nil {
+		return 0, err
+	}
+	taken := make(map[int]bool, len(reserved))
+	for _, port := range reserved {
+		taken[port] = true
+	}
+
+	// Only ports the quota of the node's owner or team allows are handed out
+	allowed, err := allowedPorts(nodeSubject(node))
+	if err != nil {
+		return 0, err
+	}
+
+	for _, r := range ranges {
+		for port := r.StartPort; port <= r.EndPort; port++ {
+			// Skip ports held by processes outside this application too
+			if taken[port] || !allowed.contains(port) || !isPortAvailable(node.IP, port) {
+				continue
+			}
+
+			node.Port = port
+			err := ReservePort(tx, node)
+			if errors.Is(err, ErrPortConflict) {
+				continue // Lost a race with a concurrent allocation
+			}
+			if err != nil {
+				return 0, err
+			}
+			return port, nil
+		}
+	}
+
+	return 0, fmt.Errorf("%w for IP %s", ErrNoFreePort, node.IP)
+}
+
+// isWildcardIP reports whether the IP listens on every local address
+func isWildcardIP(ip string) bool {
+	parsed := net.ParseIP(ip)
+	return parsed != nil && parsed.IsUnspecified()
+}
@@ -0,0 +1,501 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"node_management_application/config"
+	"node_management_application/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrQuotaExceeded is matched by every QuotaError
+	ErrQuotaExceeded = errors.New("quota exceeded")
+
+	// ErrQuotaNotFound is returned when removing a quota that was never set
+	ErrQuotaNotFound = errors.New("quota not found")
+)
+
+// Quota limits named by a QuotaError
+const (
+	LimitNodes        = "max_nodes"
+	LimitRunningNodes = "max_running_nodes"
+	LimitHealthChecks = "max_health_checks_per_minute"
+	LimitAllowedPorts = "allowed_ports"
+)
+
+// QuotaError reports the limit an action would exceed and whose quota sets it. RetryAfter is
+// set when the limit frees up by itself, as the health check rate does when the minute passes.
+type QuotaError struct {
+	Limit        string        `json:"limit"`
+	Scope        string        `json:"scope"`
+	SubjectID    uint          `json:"subject_id"`
+	Max          *int          `json:"max,omitempty"`
+	Port         int           `json:"port,omitempty"`
+	AllowedPorts string        `json:"allowed_ports,omitempty"`
+	RetryAfter   time.Duration `json:"-"`
+}
+
+func (e *QuotaError) Error() string {
+	subject := quotaSubject{e.Scope, e.SubjectID}
+	switch e.Limit {
+	case LimitNodes:
+		return fmt.Sprintf("%v: %s may own at most %d nodes", ErrQuotaExceeded, subject, *e.Max)
+	case LimitRunningNodes:
+		return fmt.Sprintf("%v: %s may run at most %d nodes at once", ErrQuotaExceeded, subject, *e.Max)
+	case LimitHealthChecks:
+		return fmt.Sprintf("%v: %s may run at most %d health checks per minute", ErrQuotaExceeded, subject, *e.Max)
+	case LimitAllowedPorts:
+		return fmt.Sprintf("%v: port %d is not among the ports allowed for %s (%s)", ErrQuotaExceeded, e.Port, subject, e.AllowedPorts)
+	}
+	return ErrQuotaExceeded.Error()
+}
+
+// Is makes every QuotaError match ErrQuotaExceeded
+func (e *QuotaError) Is(target error) bool {
+	return target == ErrQuotaExceeded
+}
+
+// QuotaUsage is what a user or team uses next to the quota in effect for it, which is nil
+// when no quota applies
+type QuotaUsage struct {
+	Scope                  string        `json:"scope"`
+	SubjectID              uint          `json:"subject_id"`
+	Name                   string        `json:"name,omitempty"`
+	Quota                  *models.Quota `json:"quota"`
+	Nodes                  int           `json:"nodes"`
+	RunningNodes           int           `json:"running_nodes"`
+	HealthChecksLastMinute int           `json:"health_checks_last_minute"`
+}
+
+// Usage is the quota usage of a user and of each team they are a member of
+type Usage struct {
+	User  QuotaUsage   `json:"user"`
+	Teams []QuotaUsage `json:"teams"`
+}
+
+// quotaSubject is the user or team whose quota a node counts against
+type quotaSubject struct {
+	scope string
+	id    uint
+}
+
+func (s quotaSubject) String() string {
+	return fmt.Sprintf("%s %d", s.scope, s.id)
+}
+
+// nodes selects the nodes that count against the subject
+func (s quotaSubject) nodes(db *gorm.DB) *gorm.DB {
+	if s.scope == models.QuotaScopeTeam {
+		return db.Model(&models.Node{}).Where("team_id = ?", s.id)
+	}
+	return db.Model(&models.Node{}).Where("user_id = ? AND team_id IS NULL", s.id)
+}
+
+// exceeded returns the QuotaError for a limit of the subject
+func (s quotaSubject) exceeded(limit string, allowed *int) *QuotaError {
+	return &QuotaError{Limit: limit, Scope: s.scope, SubjectID: s.id, Max: allowed}
+}
+
+// nodeSubject returns the subject a node counts against: its team, or its owner when it has none
+func nodeSubject(node *models.Node) quotaSubject {
+	if node.TeamID != nil {
+		return quotaSubject{models.QuotaScopeTeam, *node.TeamID}
+	}
+	return quotaSubject{models.QuotaScopeUser, node.UserID}
+}
+
+// userSubject is the subject of the nodes a user owns outside any team
+func userSubject(userID uint) quotaSubject {
+	return quotaSubject{models.QuotaScopeUser, userID}
+}
+
+// ListQuotas returns every quota, the default one first
+func ListQuotas(ctx context.Context) ([]models.Quota, error) {
+	quotas := []models.Quota{}
+	if err := config.DB.WithContext(ctx).Order("scope, subject_id").Find(&quotas).Error; err != nil {
+		return nil, err
+	}
+	return quotas, nil
+}
+
+// SetQuota creates or replaces the quota of a user or team, or the default quota when scope is
+// default. Only the limits and AllowedPorts of the given quota are used.
+func SetQuota(ctx context.Context, scope string, subjectID uint, limits models.Quota) (*models.Quota, error) {
+	if err := validateQuota(&limits); err != nil {
+		return nil, err
+	}
+
+	quota := models.Quota{
+		Scope:                    scope,
+		SubjectID:                subjectID,
+		MaxNodes:                 limits.MaxNodes,
+		MaxRunningNodes:          limits.MaxRunningNodes,
+		MaxHealthChecksPerMinute: limits.MaxHealthChecksPerMinute,
+		AllowedPorts:             limits.AllowedPorts,
+	}
+	err := config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		switch scope {
+		case models.QuotaScopeUser:
+			if err := requireUser(tx, subjectID); err != nil {
+				return err
+			}
+		case models.QuotaScopeTeam:
+			var count int64
+			if err := tx.Model(&models.Team{}).Where("id = ?", subjectID).Count(&count).Error; err != nil {
+				return err
+			}
+			if count == 0 {
+				return fmt.Errorf("%w: %d", ErrTeamNotFound, subjectID)
+			}
+		}
+
+		var existing []models.Quota
+		if err := tx.Where("scope = ? AND subject_id = ?", scope, subjectID).Limit(1).Find(&existing).Error; err != nil {
+			return err
+		}
+		if len(existing) > 0 {
+			quota.ID = existing[0].ID
+		}
+		return tx.Save(&quota).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	invalidateQuotas()
+	return &quota, nil
+}
+
+// DeleteQuota removes the quota of a user or team, who fall back on the default quota, or the
+// default quota itself, which leaves everyone without a quota of their own unlimited
+func DeleteQuota(ctx context.Context, scope string, subjectID uint) error {
+	result := config.DB.WithContext(ctx).Where("scope = ? AND subject_id = ?", scope, subjectID).Delete(&models.Quota{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrQuotaNotFound
+	}
+
+	invalidateQuotas()
+	return nil
+}
+
+// GetUsage returns the quota usage of a user and of the teams they are a member of
+func GetUsage(ctx context.Context, userID uint) (*Usage, error) {
+	teams, err := ListUserTeams(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	db := config.DB.WithContext(ctx)
+
+	user, err := subjectUsage(db, userSubject(userID))
+	if err != nil {
+		return nil, err
+	}
+	usage := &Usage{User: *user, Teams: make([]QuotaUsage, 0, len(teams))}
+	for _, team := range teams {
+		teamUsage, err := subjectUsage(db, quotaSubject{models.QuotaScopeTeam, team.ID})
+		if err != nil {
+			return nil, err
+		}
+		teamUsage.Name = team.Name
+		usage.Teams = append(usage.Teams, *teamUsage)
+	}
+	return usage, nil
+}
+
+// subjectUsage counts what a subject uses
+func subjectUsage(db *gorm.DB, s quotaSubject) (*QuotaUsage, error) {
+	quota, err := quotaFor(s)
+	if err != nil {
+		return nil, err
+	}
+
+	var nodes int64
+	if err := s.nodes(db).Count(&nodes).Error; err != nil {
+		return nil, err
+	}
+	running, err := runningNodes(db, s, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	healthWindowMu.Lock()
+	checks := len(recentHealthChecks(s, time.Now()))
+	healthWindowMu.Unlock()
+
+	return &QuotaUsage{
+		Scope:                  s.scope,
+		SubjectID:              s.id,
+		Quota:                  quota,
+		Nodes:                  int(nodes),
+		RunningNodes:           running,
+		HealthChecksLastMinute: checks,
+	}, nil
+}
+
+// validateQuota checks admin supplied limits
+func validateQuota(quota *models.Quota) error {
+	var errs []error
+	limits := []struct {
+		field string
+		limit *int
+	}{
+		{LimitNodes, quota.MaxNodes},
+		{LimitRunningNodes, quota.MaxRunningNodes},
+		{LimitHealthChecks, quota.MaxHealthChecksPerMinute},
+	}
+	for _, l := range limits {
+		if l.limit != nil && *l.limit < 0 {
+			errs = append(errs, invalid(l.field, "limit must not be negative; leave it out for no limit"))
+		}
+	}
+
+	quota.AllowedPorts = strings.TrimSpace(quota.AllowedPorts)
+	if _, err := parsePortList(quota.AllowedPorts); err != nil {
+		errs = append(errs, invalid(LimitAllowedPorts, err.Error()))
+	}
+	return collectValidation(errs...)
+}
+
+// portList is a set of inclusive port ranges; an empty list allows every port
+type portList [][2]int
+
+// contains reports whether the port is in the list
+func (l portList) contains(port int) bool {
+	if len(l) == 0 {
+		return true
+	}
+	for _, r := range l {
+		if port >= r[0] && port <= r[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// parsePortList parses a comma separated list of ports and port ranges such as "8000-8099,9000"
+func parsePortList(value string) (portList, error) {
+	var list portList
+	if value == "" {
+		return list, nil
+	}
+
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		start, end, isRange := strings.Cut(part, "-")
+		first, err := strconv.Atoi(strings.TrimSpace(start))
+		last := first
+		if err == nil && isRange {
+			last, err = strconv.Atoi(strings.TrimSpace(end))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a port or port range", part)
+		}
+		if first <= 0 || last > 65535 {
+			return nil, fmt.Errorf("ports must be between 1 and 65535")
+		}
+		if first > last {
+			return nil, fmt.Errorf("range %q ends before it starts", part)
+		}
+		list = append(list, [2]int{first, last})
+	}
+	return list, nil
+}
+
+// Quotas are cached since every health check consults them. Changing any quota empties the cache.
+var (
+	quotaMu    sync.Mutex
+	quotaCache map[quotaSubject]models.Quota
+)
+
+// quotaFor returns the quota in effect for a subject: its own, else the default one, else nil
+func quotaFor(s quotaSubject) (*models.Quota, error) {
+	quotaMu.Lock()
+	defer quotaMu.Unlock()
+
+	if quotaCache == nil {
+		var quotas []models.Quota
+		if err := config.DB.Find(&quotas).Error; err != nil {
+			return nil, err
+		}
+		quotaCache = make(map[quotaSubject]models.Quota, len(quotas))
+		for _, quota := range quotas {
+			quotaCache[quotaSubject{quota.Scope, quota.SubjectID}] = quota
+		}
+	}
+
+	if quota, ok := quotaCache[s]; ok {
+		return &quota, nil
+	}
+	if quota, ok := quotaCache[quotaSubject{scope: models.QuotaScopeDefault}]; ok {
+		return &quota, nil
+	}
+	return nil, nil
+}
+
+// invalidateQuotas empties the quota cache after a quota changed
+func invalidateQuotas() {
+	quotaMu.Lock()
+	quotaCache = nil
+	quotaMu.Unlock()
+}
+
+// quotaLocks serializes the quota checks of each subject with the changes they guard, so two
+// concurrent creates or starts cannot both take the last free slot
+var quotaLocks = sync.Map{}
+
+// lockQuota locks the quota of a subject and returns the function that unlocks it
+func lockQuota(s quotaSubject) func() {
+	lock, _ := quotaLocks.LoadOrStore(s, &sync.Mutex{})
+	mutex := lock.(*sync.Mutex)
+	mutex.Lock()
+	return mutex.Unlock
+}
+
+// checkNodeQuota fails when the subject owns more nodes than its quota allows. It runs inside
+// the transaction after the nodes are written, so a rejected change is rolled back.
+func checkNodeQuota(tx *gorm.DB, s quotaSubject) error {
+	quota, err := quotaFor(s)
+	if err != nil || quota == nil || quota.MaxNodes == nil {
+		return err
+	}
+
+	var count int64
+	if err := s.nodes(tx).Count(&count).Error; err != nil {
+		return err
+	}
+	if int(count) > *quota.MaxNodes {
+		return s.exceeded(LimitNodes, quota.MaxNodes)
+	}
+	return nil
+}
+
+// checkPortQuota fails when the port is outside the ports the subject's quota allows
+func checkPortQuota(s quotaSubject, port int) error {
+	quota, err := quotaFor(s)
+	if err != nil || quota == nil {
+		return err
+	}
+	ports, err := parsePortList(quota.AllowedPorts)
+	if err != nil || ports.contains(port) {
+		return err
+	}
+
+	quotaErr := s.exceeded(LimitAllowedPorts, nil)
+	quotaErr.Port = port
+	quotaErr.AllowedPorts = quota.AllowedPorts
+	return quotaErr
+}
+
+// CheckNodePortQuota fails with a QuotaError when the port is outside the ports the quota of
+// the node's owner or team allows
+func CheckNodePortQuota(node *models.Node, port int) error {
+	return checkPortQuota(nodeSubject(node), port)
+}
+
+// allowedPorts returns the ports the subject's quota allows
+func allowedPorts(s quotaSubject) (portList, error) {
+	quota, err := quotaFor(s)
+	if err != nil || quota == nil {
+		return nil, err
+	}
+	return parsePortList(quota.AllowedPorts)
+}
+
+// checkRunningQuota fails when starting the node would run more nodes than its subject's quota
+// allows. The caller holds the subject's quota lock until the node's server is tracked.
+func checkRunningQuota(node *models.Node) error {
+	s := nodeSubject(node)
+	quota, err := quotaFor(s)
+	if err != nil || quota == nil || quota.MaxRunningNodes == nil {
+		return err
+	}
+
+	running, err := runningNodes(config.DB, s, node.ID)
+	if err != nil {
+		return err
+	}
+	if running >= *quota.MaxRunningNodes {
+		return s.exceeded(LimitRunningNodes, quota.MaxRunningNodes)
+	}
+	return nil
+}
+
+// runningNodes counts the subject's nodes that have a running server, leaving out exclude
+func runningNodes(db *gorm.DB, s quotaSubject, exclude uint) (int, error) {
+	var ids []uint
+	serverStore.Range(func(key, _ interface{}) bool {
+		if id := key.(uint); id != exclude {
+			ids = append(ids, id)
+		}
+		return true
+	})
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	var count int64
+	if err := s.nodes(db).Where("id IN ?", ids).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return int(count), nil
+}
+
+// The health checks of each subject over the last minute, oldest first
+var (
+	healthWindowMu sync.Mutex
+	healthWindow   = map[quotaSubject][]time.Time{}
+)
+
+// takeHealthCheck counts a health check of the node against its subject's quota. Over the
+// limit it fails with a QuotaError telling when the next check fits in.
+func takeHealthCheck(node *models.Node) error {
+	s := nodeSubject(node)
+	quota, err := quotaFor(s)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	healthWindowMu.Lock()
+	defer healthWindowMu.Unlock()
+
+	checks := recentHealthChecks(s, now)
+	if quota != nil && quota.MaxHealthChecksPerMinute != nil && len(checks) >= *quota.MaxHealthChecksPerMinute {
+		quotaErr := s.exceeded(LimitHealthChecks, quota.MaxHealthChecksPerMinute)
+		// A limit of zero never frees up
+		if limit := *quota.MaxHealthChecksPerMinute; limit > 0 {
+			quotaErr.RetryAfter = checks[len(checks)-limit].Add(time.Minute).Sub(now)
+		}
+		return quotaErr
+	}
+	healthWindow[s] = append(checks, now)
+	return nil
+}
+
+// recentHealthChecks drops the subject's checks older than a minute and returns the rest.
+// The caller holds healthWindowMu.
+func recentHealthChecks(s quotaSubject, now time.Time) []time.Time {
+	checks := healthWindow[s]
+	expired := 0
+	for expired < len(checks) && now.Sub(checks[expired]) >= time.Minute {
+		expired++
+	}
+	checks = checks[expired:]
+	if len(checks) == 0 {
+		delete(healthWindow, s)
+	} else {
+		healthWindow[s] = checks
+	}
+	return checks
+}
@@ -0,0 +1,107 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"node_management_application/config"
+	"node_management_application/models"
+	"node_management_application/services"
+	"node_management_application/testutil"
+)
+
+// setUserQuota gives the user a quota and removes it again when the test ends, so the quota
+// cache of later tests starts out empty
+func setUserQuota(t *testing.T, userID uint, limits models.Quota) {
+	t.Helper()
+	if _, err := services.SetQuota(context.Background(), models.QuotaScopeUser, userID, limits); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		services.DeleteQuota(context.Background(), models.QuotaScopeUser, userID)
+	})
+}
+
+func TestNodeQuota(t *testing.T) {
+	testutil.UseDatabase(t)
+	ctx := context.Background()
+	ada := testutil.CreateUser(t, "ada", false)
+	limit := 1
+	setUserQuota(t, ada.ID, models.Quota{MaxNodes: &limit})
+
+	if _, err := services.CreateNode(ctx, ada.ID, services.NodeSpec{Name: "alpha", IP: "127.0.0.1", Port: 18201}); err != nil {
+		t.Fatal(err)
+	}
+	_, err := services.CreateNode(ctx, ada.ID, services.NodeSpec{Name: "beta", IP: "127.0.0.1", Port: 18202})
+	var quotaErr *services.QuotaError
+	if !errors.As(err, &quotaErr) || !errors.Is(err, services.ErrQuotaExceeded) {
+		t.Fatalf("got %v, want a QuotaError", err)
+	}
+	if quotaErr.Limit != services.LimitNodes || quotaErr.SubjectID != ada.ID {
+		t.Errorf("got %+v, want the node limit of user %d", quotaErr, ada.ID)
+	}
+	if n := count(t, &models.Node{}, "user_id = ?", ada.ID); n != 1 {
+		t.Errorf("user owns %d nodes, want the rejected one rolled back", n)
+	}
+}
+
+func TestAllowedPortsQuota(t *testing.T) {
+	testutil.UseDatabase(t)
+	ctx := context.Background()
+	ada := testutil.CreateUser(t, "ada", false)
+	setUserQuota(t, ada.ID, models.Quota{AllowedPorts: "18300-18309,18400"})
+
+	for _, port := range []int{18300, 18309, 18400} {
+		if err := services.CheckNodePortQuota(&models.Node{UserID: ada.ID}, port); err != nil {
+			t.Errorf("port %d: %v", port, err)
+		}
+	}
+	err := services.CheckNodePortQuota(&models.Node{UserID: ada.ID}, 18310)
+	var quotaErr *services.QuotaError
+	if !errors.As(err, &quotaErr) || quotaErr.Limit != services.LimitAllowedPorts || quotaErr.Port != 18310 {
+		t.Fatalf("port 18310: got %v, want an allowed ports QuotaError", err)
+	}
+
+	if _, err := services.CreateNode(ctx, ada.ID, services.NodeSpec{Name: "alpha", IP: "127.0.0.1", Port: 18500}); !errors.Is(err, services.ErrQuotaExceeded) {
+		t.Errorf("create on a port outside the quota: got %v, want ErrQuotaExceeded", err)
+	}
+}
+
+func TestInvalidQuotaRejected(t *testing.T) {
+	testutil.UseDatabase(t)
+	ada := testutil.CreateUser(t, "ada", false)
+
+	var validation *services.ValidationError
+	if _, err := services.SetQuota(context.Background(), models.QuotaScopeUser, ada.ID, models.Quota{AllowedPorts: "9000-8000"}); !errors.As(err, &validation) {
+		t.Errorf("got %v, want a ValidationError", err)
+	}
+}
+
+func TestHealthCheckQuota(t *testing.T) {
+	testutil.UseDatabase(t)
+	ctx := context.Background()
+
+	// The window of recent checks outlives the test database, so take an ID no earlier run used
+	ada := models.User{ID: uint(time.Now().UnixNano() % 1e9), Name: "ada", Email: "ada@example.com", Password: "unused"}
+	if err := config.DB.Create(&ada).Error; err != nil {
+		t.Fatal(err)
+	}
+	limit := 1
+	setUserQuota(t, ada.ID, models.Quota{MaxHealthChecksPerMinute: &limit})
+	node := createNode(t, ada.ID, "alpha", 18203)
+
+	// Nothing listens on the node's address, but the check still counts
+	if err := services.PerformHealthCheckConcurrently(ctx, &node); !errors.Is(err, services.ErrNodeUnhealthy) {
+		t.Fatalf("first check: got %v, want ErrNodeUnhealthy", err)
+	}
+	err := services.PerformHealthCheckConcurrently(ctx, &node)
+	var quotaErr *services.QuotaError
+	if !errors.As(err, &quotaErr) || quotaErr.Limit != services.LimitHealthChecks {
+		t.Fatalf("second check: got %v, want a health check QuotaError", err)
+	}
+	if quotaErr.RetryAfter <= 0 {
+		t.Errorf("RetryAfter is %v, want the time until the first check leaves the window", quotaErr.RetryAfter)
+	}
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"node_management_application/config"
+	"node_management_application/logging"
+	"node_management_application/models"
+)
+
+var scheduleLogger = logging.For("schedules")
+
+// RunNodeSchedules starts and stops nodes at the daily times their manifest schedule sets
+func RunNodeSchedules(shutdown chan struct{}) {
+	for {
+		// Wake up at the start of each minute so every HH:MM is seen exactly once
+		now := time.Now().UTC()
+		timer := time.NewTimer(now.Truncate(time.Minute).Add(time.Minute).Sub(now))
+
+		select {
+		case <-shutdown:
+			timer.Stop()
+			scheduleLogger.Info("Node scheduler shutting down")
+			return
+		case tick := <-timer.C:
+			runSchedulesAt(tick.UTC().Format("15:04"))
+		}
+	}
+}
+
+// runSchedulesAt starts the stopped nodes scheduled to start at the time of day and stops the
+// running nodes scheduled to stop at it
+func runSchedulesAt(at string) {
+	ctx := context.Background()
+
+	var starting, stopping []models.Node
+	if err := config.DB.Where("start_at = ? AND status = ?", at, "Stopped").Find(&starting).Error; err != nil {
+		scheduleLogger.Error("Failed to load scheduled nodes", "at", at, "error", err)
+		return
+	}
+	if err := config.DB.Where("stop_at = ? AND status = ?", at, "Running").Find(&stopping).Error; err != nil {
+		scheduleLogger.Error("Failed to load scheduled nodes", "at", at, "error", err)
+		return
+	}
+
+	for i := range starting {
+		node := &starting[i]
+		if err := StartNode(ctx, node); err != nil {
+			scheduleLogger.Error("Scheduled start failed", "node_id", node.ID, "node", node.Name, "error", err)
+			continue
+		}
+		scheduleLogger.Info("Started node on schedule", "node_id", node.ID, "node", node.Name)
+	}
+	for i := range stopping {
+		node := &stopping[i]
+		if err := StopNode(ctx, node); err != nil {
+			scheduleLogger.Error("Scheduled stop failed", "node_id", node.ID, "node", node.Name, "error", err)
+			continue
+		}
+		scheduleLogger.Info("Stopped node on schedule", "node_id", node.ID, "node", node.Name)
+	}
+}
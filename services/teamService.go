@@ -0,0 +1,236 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"node_management_application/config"
+	"node_management_application/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrTeamNotFound is returned for a team that does not exist or that the user is not a member of
+	ErrTeamNotFound = errors.New("team not found")
+
+	// ErrTeamNameTaken is returned when creating a team with the name of another team
+	ErrTeamNameTaken = errors.New("team name is already taken")
+
+	// ErrTeamHasNodes is returned when deleting a team that still owns nodes
+	ErrTeamHasNodes = errors.New("team still owns nodes; transfer them first")
+
+	// ErrNotTeamMember is returned when removing a user that is not a member of the team
+	ErrNotTeamMember = errors.New("the user is not a member of the team")
+
+	// ErrLastTeamAdmin is returned when a change would leave a team without an admin
+	ErrLastTeamAdmin = errors.New("a team needs at least one admin")
+)
+
+// ListUserTeams returns the teams a user is a member of, with their members
+func ListUserTeams(ctx context.Context, userID uint) ([]models.Team, error) {
+	db := config.DB.WithContext(ctx)
+	teams := []models.Team{}
+	err := db.Preload("Members").
+		Where("id IN (?)", db.Session(&gorm.Session{NewDB: true}).Model(&models.TeamMember{}).Select("team_id").Where("user_id = ?", userID)).
+		Order("name").Find(&teams).Error
+	if err != nil {
+		return nil, err
+	}
+	return teams, nil
+}
+
+// GetUserTeam returns a team the user is a member of, with its members
+func GetUserTeam(ctx context.Context, userID uint, id uint) (*models.Team, error) {
+	db := config.DB.WithContext(ctx)
+	role, err := teamRole(db, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if role == "" {
+		return nil, fmt.Errorf("%w: %d", ErrTeamNotFound, id)
+	}
+
+	var team models.Team
+	if err := db.Preload("Members").First(&team, id).Error; err != nil {
+		return nil, err
+	}
+	return &team, nil
+}
+
+// CreateTeam creates a team with its creator as the first admin
+func CreateTeam(ctx context.Context, userID uint, name string) (*models.Team, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, invalid("name", "name is required")
+	}
+
+	team := models.Team{Name: name}
+	err := config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Members").Create(&team).Error; err != nil {
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				return ErrTeamNameTaken
+			}
+			return err
+		}
+		member := models.TeamMember{TeamID: team.ID, UserID: userID, Role: models.TeamRoleAdmin}
+		if err := tx.Create(&member).Error; err != nil {
+			return err
+		}
+		team.Members = []models.TeamMember{member}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &team, nil
+}
+
+// DeleteTeam removes a team and its memberships. Only a team admin may, and only once the
+// team owns no nodes.
+func DeleteTeam(ctx context.Context, userID uint, id uint) error {
+	err := config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := requireTeamAdmin(tx, id, userID); err != nil {
+			return err
+		}
+
+		var nodes int64
+		if err := tx.Model(&models.Node{}).Where("team_id = ?", id).Count(&nodes).Error; err != nil {
+			return err
+		}
+		if nodes > 0 {
+			return ErrTeamHasNodes
+		}
+
+		if err := tx.Where("team_id = ?", id).Delete(&models.TeamMember{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("scope = ? AND subject_id = ?", models.QuotaScopeTeam, id).Delete(&models.Quota{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Team{}, id).Error
+	})
+	if err != nil {
+		return err
+	}
+	invalidateNodeAccess()
+	invalidateQuotas()
+	return nil
+}
+
+// SetTeamMember adds a user to a team or changes their role. Only a team admin may.
+func SetTeamMember(ctx context.Context, userID uint, teamID uint, memberID uint, role string) (*models.TeamMember, error) {
+	if role != models.TeamRoleAdmin && role != models.TeamRoleMember {
+		return nil, invalid("role", "role must be admin or member")
+	}
+
+	member := models.TeamMember{TeamID: teamID, UserID: memberID, Role: role}
+	err := config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := requireTeamAdmin(tx, teamID, userID); err != nil {
+			return err
+		}
+		if err := requireUser(tx, memberID); err != nil {
+			return err
+		}
+
+		var existing []models.TeamMember
+		if err := tx.Where("team_id = ? AND user_id = ?", teamID, memberID).Limit(1).Find(&existing).Error; err != nil {
+			return err
+		}
+		if len(existing) > 0 {
+			if existing[0].Role == models.TeamRoleAdmin && role != models.TeamRoleAdmin {
+				if err := requireOtherAdmin(tx, teamID, memberID); err != nil {
+					return err
+				}
+			}
+			member.ID = existing[0].ID
+			member.CreatedAt = existing[0].CreatedAt
+		}
+		return tx.Save(&member).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	invalidateNodeAccess()
+	return &member, nil
+}
+
+// RemoveTeamMember takes a user out of a team. Team admins may remove anyone and every member
+// may leave, but the last admin stays.
+func RemoveTeamMember(ctx context.Context, userID uint, teamID uint, memberID uint) error {
+	err := config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if userID != memberID {
+			if err := requireTeamAdmin(tx, teamID, userID); err != nil {
+				return err
+			}
+		}
+
+		role, err := teamRole(tx, teamID, memberID)
+		if err != nil {
+			return err
+		}
+		switch role {
+		case "":
+			if userID == memberID {
+				return fmt.Errorf("%w: %d", ErrTeamNotFound, teamID)
+			}
+			return ErrNotTeamMember
+		case models.TeamRoleAdmin:
+			if err := requireOtherAdmin(tx, teamID, memberID); err != nil {
+				return err
+			}
+		}
+		return tx.Where("team_id = ? AND user_id = ?", teamID, memberID).Delete(&models.TeamMember{}).Error
+	})
+	if err != nil {
+		return err
+	}
+	invalidateNodeAccess()
+	return nil
+}
+
+// teamRole returns the role of a user in a team, or "" when they are not a member
+func teamRole(db *gorm.DB, teamID uint, userID uint) (string, error) {
+	var members []models.TeamMember
+	if err := db.Where("team_id = ? AND user_id = ?", teamID, userID).Limit(1).Find(&members).Error; err != nil {
+		return "", err
+	}
+	if len(members) == 0 {
+		return "", nil
+	}
+	return members[0].Role, nil
+}
+
+// requireTeamAdmin fails unless the user is an admin of the team. Non-members are told the
+// team does not exist, and members that it takes an admin.
+func requireTeamAdmin(db *gorm.DB, teamID uint, userID uint) error {
+	role, err := teamRole(db, teamID, userID)
+	if err != nil {
+		return err
+	}
+	switch role {
+	case models.TeamRoleAdmin:
+		return nil
+	case "":
+		return fmt.Errorf("%w: %d", ErrTeamNotFound, teamID)
+	default:
+		return fmt.Errorf("%w: only team admins may manage the team", ErrAccessDenied)
+	}
+}
+
+// requireOtherAdmin fails with ErrLastTeamAdmin unless the team has an admin besides the user
+func requireOtherAdmin(db *gorm.DB, teamID uint, userID uint) error {
+	var admins int64
+	err := db.Model(&models.TeamMember{}).
+		Where("team_id = ? AND user_id <> ? AND role = ?", teamID, userID, models.TeamRoleAdmin).
+		Count(&admins).Error
+	if err != nil {
+		return err
+	}
+	if admins == 0 {
+		return ErrLastTeamAdmin
+	}
+	return nil
+}
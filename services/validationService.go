@@ -3,21 +3,101 @@ package services
 import (
 	"errors"
 	"net"
+	"net/mail"
+	"strings"
 )
 
-// ValidateNodeData validates the node data for required fields and proper formatting
+// FieldError is the validation failure of one request field
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+// ValidationError lists every field of a request that failed validation
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		messages[i] = field.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// invalid builds the validation error of a single field
+func invalid(field string, message string) error {
+	return &ValidationError{Fields: []FieldError{{Field: field, Message: message}}}
+}
+
+// ValidateNodeData validates the node data for required fields and proper formatting.
+// Every invalid field is reported, not just the first.
 func ValidateNodeData(name string, ip string, port int) error {
+	return collectValidation(ValidateNodeName(name), ValidateNodeIP(ip), ValidateNodePort(port))
+}
+
+// collectValidation merges field validation errors; any other error is returned as is
+func collectValidation(errs ...error) error {
+	merged := &ValidationError{}
+	for _, err := range errs {
+		var validation *ValidationError
+		switch {
+		case err == nil:
+		case errors.As(err, &validation):
+			merged.Fields = append(merged.Fields, validation.Fields...)
+		default:
+			return err
+		}
+	}
+	if len(merged.Fields) == 0 {
+		return nil
+	}
+	return merged
+}
+
+// ValidateNodeName checks that a node name is present
+func ValidateNodeName(name string) error {
 	if name == "" {
-		return errors.New("name is required")
+		return invalid("name", "name is required")
 	}
+	return nil
+}
+
+// ValidateNodeIP checks that a node IP is present and well formed
+func ValidateNodeIP(ip string) error {
 	if ip == "" {
-		return errors.New("IP address is required")
+		return invalid("ip", "IP address is required")
 	}
 	if net.ParseIP(ip) == nil {
-		return errors.New("invalid IP address format")
+		return invalid("ip", "invalid IP address format")
 	}
+	return nil
+}
+
+// ValidateNodePort checks that a node port is in the valid TCP range
+func ValidateNodePort(port int) error {
 	if port <= 0 || port > 65535 {
-		return errors.New("port must be between 1 and 65535")
+		return invalid("port", "port must be between 1 and 65535")
+	}
+	return nil
+}
+
+// ValidateUserName checks that a user name is present
+func ValidateUserName(name string) error {
+	if name == "" {
+		return invalid("name", "name is required")
+	}
+	return nil
+}
+
+// ValidateUserEmail checks that a user email is present and well formed
+func ValidateUserEmail(email string) error {
+	if email == "" {
+		return invalid("email", "email is required")
+	}
+	if _, err := mail.ParseAddress(email); err != nil {
+		return invalid("email", "invalid email address format")
 	}
 	return nil
 }
@@ -0,0 +1,41 @@
+package services
+
+import (
+	"errors"
+
+	"node_management_application/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrVersionConflict is returned when a node changed since it was read
+var ErrVersionConflict = errors.New("node was modified by another request")
+
+// UpdateNodeIfVersion writes the columns only if the node still has the version it was read with,
+// and bumps the version on success
+func UpdateNodeIfVersion(tx *gorm.DB, node *models.Node, columns map[string]interface{}) error {
+	columns["version"] = gorm.Expr("version + 1")
+
+	result := tx.Model(&models.Node{}).Where("id = ? AND version = ?", node.ID, node.Version).Updates(columns)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrVersionConflict
+	}
+
+	node.Version++
+	return nil
+}
+
+// DeleteNodeIfVersion deletes the node only if it still has the version it was read with
+func DeleteNodeIfVersion(tx *gorm.DB, node *models.Node) error {
+	result := tx.Where("id = ? AND version = ?", node.ID, node.Version).Delete(&models.Node{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrVersionConflict
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+// Package testutil holds the setup shared by the tests of several packages
+package testutil
+
+import (
+	"path/filepath"
+	"testing"
+
+	"node_management_application/config"
+	"node_management_application/models"
+	"node_management_application/routes"
+
+	"github.com/kataras/iris/v12"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// UseDatabase points the application at a fresh SQLite database until the test ends
+func UseDatabase(t testing.TB) {
+	t.Helper()
+	dsn := filepath.Join(t.TempDir(), "test.db") + "?_busy_timeout=5000&_journal_mode=WAL"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{TranslateError: true})
+	if err != nil {
+		t.Fatalf("open test database: %v", err)
+	}
+	if err := db.AutoMigrate(models.All()...); err != nil {
+		t.Fatalf("migrate test database: %v", err)
+	}
+
+	previous := config.DB
+	config.DB = db
+	t.Cleanup(func() {
+		config.DB = previous
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+}
+
+// NewApp registers every route the server does, in the same order
+func NewApp() *iris.Application {
+	app := iris.New()
+	routes.RegisterMetricsRoute(app)
+	routes.RegisterRoutes(app)
+	routes.RegisterWebSocketRoute(app)
+	routes.RegisterEventStreamRoute(app)
+	routes.RegisterDocsRoutes(app)
+	return app
+}
+
+// CreateUser adds a user with a unique email straight to the database
+func CreateUser(t testing.TB, name string, admin bool) models.User {
+	t.Helper()
+	user := models.User{Name: name, Email: name + "@example.com", Password: "unused", IsAdmin: admin}
+	if err := config.DB.Create(&user).Error; err != nil {
+		t.Fatalf("create user %s: %v", name, err)
+	}
+	return user
+}
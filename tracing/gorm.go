@@ -0,0 +1,88 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// parentContextKey stores the statement context that was replaced by the query span's context
+const parentContextKey = "tracing:parent_context"
+
+// RegisterGORMCallbacks creates a client span for every query. Queries run with
+// db.WithContext(ctx) become children of the span carried by ctx.
+func RegisterGORMCallbacks(db *gorm.DB) error {
+	callback := db.Callback()
+	registrations := []struct {
+		operation string
+		before    error
+		after     error
+	}{
+		{"create", callback.Create().Before("gorm:create").Register("tracing:before_create", startQuerySpan("create")),
+			callback.Create().After("gorm:create").Register("tracing:after_create", endQuerySpan)},
+		{"query", callback.Query().Before("gorm:query").Register("tracing:before_query", startQuerySpan("query")),
+			callback.Query().After("gorm:query").Register("tracing:after_query", endQuerySpan)},
+		{"update", callback.Update().Before("gorm:update").Register("tracing:before_update", startQuerySpan("update")),
+			callback.Update().After("gorm:update").Register("tracing:after_update", endQuerySpan)},
+		{"delete", callback.Delete().Before("gorm:delete").Register("tracing:before_delete", startQuerySpan("delete")),
+			callback.Delete().After("gorm:delete").Register("tracing:after_delete", endQuerySpan)},
+		{"row", callback.Row().Before("gorm:row").Register("tracing:before_row", startQuerySpan("row")),
+			callback.Row().After("gorm:row").Register("tracing:after_row", endQuerySpan)},
+		{"raw", callback.Raw().Before("gorm:raw").Register("tracing:before_raw", startQuerySpan("raw")),
+			callback.Raw().After("gorm:raw").Register("tracing:after_raw", endQuerySpan)},
+	}
+
+	for _, registration := range registrations {
+		if err := errors.Join(registration.before, registration.after); err != nil {
+			return fmt.Errorf("failed to register %s tracing callbacks: %w", registration.operation, err)
+		}
+	}
+	return nil
+}
+
+// startQuerySpan opens the span of one GORM operation
+func startQuerySpan(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		parent := db.Statement.Context
+		if parent == nil {
+			parent = context.Background()
+		}
+
+		spanCtx, _ := Tracer().Start(parent, "gorm."+operation,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				semconv.DBSystemMySQL,
+				semconv.DBOperationName(operation),
+			),
+		)
+		db.InstanceSet(parentContextKey, parent)
+		db.Statement.Context = spanCtx
+	}
+}
+
+// endQuerySpan records the statement and outcome, then restores the caller's context
+func endQuerySpan(db *gorm.DB) {
+	parent, ok := db.InstanceGet(parentContextKey)
+	if !ok {
+		return
+	}
+	span := trace.SpanFromContext(db.Statement.Context)
+	db.Statement.Context = parent.(context.Context)
+
+	span.SetAttributes(
+		semconv.DBQueryText(db.Statement.SQL.String()),
+		semconv.DBCollectionName(db.Statement.Table),
+		attribute.Int64("db.rows_affected", db.Statement.RowsAffected),
+	)
+	if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+		span.RecordError(db.Error)
+		span.SetStatus(codes.Error, db.Error.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,47 @@
+package tracing
+
+import (
+	"fmt"
+
+	"github.com/kataras/iris/v12"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Middleware starts a server span for every request, continuing the caller's trace if any,
+// and hands its context to the handlers through the request
+func Middleware(ctx iris.Context) {
+	request := ctx.Request()
+
+	route := request.URL.Path
+	if current := ctx.GetCurrentRoute(); current != nil {
+		route = current.Path()
+	}
+
+	parent := otel.GetTextMapPropagator().Extract(request.Context(), propagation.HeaderCarrier(request.Header))
+	spanCtx, span := Tracer().Start(parent, fmt.Sprintf("%s %s", request.Method, route),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(request.Method),
+			semconv.HTTPRoute(route),
+			semconv.URLPath(request.URL.Path),
+		),
+	)
+	defer span.End()
+
+	ctx.ResetRequest(request.WithContext(spanCtx))
+	ctx.Next()
+
+	status := ctx.GetStatusCode()
+	span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+	if userID := ctx.Values().GetUintDefault("user_id", 0); userID != 0 {
+		span.SetAttributes(attribute.Int64("user.id", int64(userID)))
+	}
+	if status >= 500 {
+		span.SetStatus(codes.Error, fmt.Sprintf("HTTP %d", status))
+	}
+}
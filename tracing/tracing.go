@@ -0,0 +1,105 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"node_management_application/config"
+	"node_management_application/logging"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// serviceName identifies the application in exported traces
+const serviceName = "node-management"
+
+// instrumentationName names the tracer used by the application's own spans
+const instrumentationName = "node_management_application"
+
+var (
+	provider *sdktrace.TracerProvider
+	output   io.Closer // File written by the "file" exporter
+)
+
+// Init configures the global tracer provider from config.TracingExporter.
+// Trace context propagation is enabled even when no exporter is configured.
+func Init() error {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	exporter, err := newExporter(config.TracingExporter)
+	if err != nil {
+		return err
+	}
+	if exporter == nil {
+		return nil
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(serviceName)))
+	if err != nil {
+		return err
+	}
+
+	provider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	logging.For("tracing").Info("Tracing enabled", "exporter", config.TracingExporter)
+	return nil
+}
+
+// newExporter builds the span exporter by name; "none" disables tracing
+func newExporter(name string) (sdktrace.SpanExporter, error) {
+	switch name {
+	case "none", "":
+		return nil, nil
+	case "otlp":
+		return otlptracehttp.New(context.Background())
+	case "stdout":
+		return stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case "file":
+		file, err := os.OpenFile(config.TracingFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open trace file: %v", err)
+		}
+		output = file
+		return stdouttrace.New(stdouttrace.WithWriter(file))
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q", name)
+	}
+}
+
+// Shutdown flushes pending spans and closes the exporter
+func Shutdown(ctx context.Context) error {
+	if provider == nil {
+		return nil
+	}
+	err := provider.Shutdown(ctx)
+	if output != nil {
+		output.Close()
+	}
+	return err
+}
+
+// Tracer returns the tracer for the application's spans
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// NodeAttributes identify the node and its owner on a span
+func NodeAttributes(nodeID, userID uint) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.Int64("node.id", int64(nodeID)),
+		attribute.Int64("user.id", int64(userID)),
+	}
+}
@@ -0,0 +1,89 @@
+package traffic
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Middleware records the traffic of every request served by a node's server
+func Middleware(nodeID uint, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		// Server requests always carry a body, possibly empty
+		body := &countingReader{ReadCloser: r.Body}
+		r.Body = body
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(recorder, r)
+
+		// Count the declared length when the handler did not read the whole body
+		bytesIn := body.n
+		if r.ContentLength > 0 && uint64(r.ContentLength) > bytesIn {
+			bytesIn = uint64(r.ContentLength)
+		}
+		record(nodeID, recorder.status, bytesIn, recorder.n, time.Since(start))
+	})
+}
+
+// countingReader counts the request body bytes read by the handler
+type countingReader struct {
+	io.ReadCloser
+	n uint64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n += uint64(n)
+	return n, err
+}
+
+// responseRecorder captures the status code and counts the response body bytes
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	n           uint64
+}
+
+func (w *responseRecorder) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseRecorder) Write(p []byte) (int, error) {
+	w.wroteHeader = true
+	n, err := w.ResponseWriter.Write(p)
+	w.n += uint64(n)
+	return n, err
+}
+
+// Flush sends buffered data to the client, so streaming handlers keep working behind the recorder
+func (w *responseRecorder) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		w.wroteHeader = true
+		flusher.Flush()
+	}
+}
+
+// Hijack hands the connection to the handler, e.g. for a WebSocket upgrade; bytes written to a
+// hijacked connection are not counted
+func (w *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+	return hijacker.Hijack()
+}
+
+// Unwrap returns the wrapped writer for http.ResponseController
+func (w *responseRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,268 @@
+package traffic
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"node_management_application/config"
+	"node_management_application/events"
+	"node_management_application/logging"
+	"node_management_application/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var logger = logging.For("traffic")
+
+// LatencyBuckets are the upper bounds, in seconds, of the request latency histogram
+var LatencyBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+
+// Bucket is one cumulative bucket of the latency histogram
+type Bucket struct {
+	LE    float64 `json:"le"`
+	Count uint64  `json:"count"`
+}
+
+// Latency summarizes the request latencies of a node
+type Latency struct {
+	Buckets    []Bucket `json:"buckets"`
+	Count      uint64   `json:"count"`
+	SumSeconds float64  `json:"sum_seconds"`
+}
+
+// Stats is a snapshot of a node server's traffic
+type Stats struct {
+	NodeID      uint              `json:"node_id"`
+	Mode        string            `json:"mode"`
+	Since       time.Time         `json:"since"`
+	Requests    uint64            `json:"requests"`
+	StatusCodes map[string]uint64 `json:"status_codes"`
+	BytesIn     uint64            `json:"bytes_in"`
+	BytesOut    uint64            `json:"bytes_out"`
+	Latency     Latency           `json:"latency"`
+}
+
+// counters accumulates the traffic of one node
+type counters struct {
+	mu          sync.Mutex
+	since       time.Time
+	requests    uint64
+	statusCodes map[int]uint64
+	bytesIn     uint64
+	bytesOut    uint64
+	buckets     []uint64 // per bucket, the last entry counts requests above every bound
+	latencySum  float64
+}
+
+// store maps node IDs to their counters
+var store = sync.Map{}
+
+func newCounters() *counters {
+	return &counters{
+		since:       time.Now(),
+		statusCodes: make(map[int]uint64),
+		buckets:     make([]uint64, len(LatencyBuckets)+1),
+	}
+}
+
+// persistent reports whether counters survive node and application restarts
+func persistent() bool {
+	return config.NodeStatsMode == "persistent"
+}
+
+// Begin prepares the counters of a node that is starting.
+// In reset mode they start from zero; in persistent mode they continue from the stored totals.
+func Begin(nodeID uint) {
+	if !persistent() {
+		store.Store(nodeID, newCounters())
+		return
+	}
+	countersFor(nodeID)
+}
+
+// countersFor returns the live counters of a node, loading the stored totals in persistent mode
+func countersFor(nodeID uint) *counters {
+	if value, ok := store.Load(nodeID); ok {
+		return value.(*counters)
+	}
+	value, _ := store.LoadOrStore(nodeID, storedCounters(nodeID))
+	return value.(*counters)
+}
+
+// storedCounters returns new counters holding the stored totals of a node in persistent mode
+func storedCounters(nodeID uint) *counters {
+	loaded := newCounters()
+	if persistent() {
+		if err := load(nodeID, loaded); err != nil {
+			logger.Error("Failed to load traffic stats", "node_id", nodeID, "error", err)
+		}
+	}
+	return loaded
+}
+
+// record adds one served request to the node's counters
+func record(nodeID uint, status int, bytesIn, bytesOut uint64, duration time.Duration) {
+	c := countersFor(nodeID)
+	seconds := duration.Seconds()
+	bucket := sort.SearchFloat64s(LatencyBuckets, seconds)
+
+	c.mu.Lock()
+	c.requests++
+	c.statusCodes[status]++
+	c.bytesIn += bytesIn
+	c.bytesOut += bytesOut
+	c.buckets[bucket]++
+	c.latencySum += seconds
+	c.mu.Unlock()
+}
+
+// Get returns the traffic of a node; a node that never served a request reports zeros.
+// Reading does not give the node live counters, so it stays out of All until it serves.
+func Get(nodeID uint) Stats {
+	if value, ok := store.Load(nodeID); ok {
+		return value.(*counters).snapshot(nodeID)
+	}
+	return storedCounters(nodeID).snapshot(nodeID)
+}
+
+// All returns the traffic of every node with live counters
+func All() []Stats {
+	var all []Stats
+	store.Range(func(key, value interface{}) bool {
+		all = append(all, value.(*counters).snapshot(key.(uint)))
+		return true
+	})
+	return all
+}
+
+func (c *counters) snapshot(nodeID uint) Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := Stats{
+		NodeID:      nodeID,
+		Mode:        config.NodeStatsMode,
+		Since:       c.since,
+		Requests:    c.requests,
+		StatusCodes: make(map[string]uint64, len(c.statusCodes)),
+		BytesIn:     c.bytesIn,
+		BytesOut:    c.bytesOut,
+		Latency: Latency{
+			Buckets:    make([]Bucket, len(LatencyBuckets)),
+			Count:      c.requests,
+			SumSeconds: c.latencySum,
+		},
+	}
+	for code, count := range c.statusCodes {
+		stats.StatusCodes[strconv.Itoa(code)] = count
+	}
+
+	var cumulative uint64
+	for i, bound := range LatencyBuckets {
+		cumulative += c.buckets[i]
+		stats.Latency.Buckets[i] = Bucket{LE: bound, Count: cumulative}
+	}
+	return stats
+}
+
+// HandleEvent saves the totals of stopped nodes and forgets deleted ones.
+// It is registered as an event bus subscriber.
+func HandleEvent(event events.Event) {
+	switch event.Type {
+	case events.NodeStopped, events.NodeCrashed:
+		Flush(event.NodeID)
+	case events.NodeDeleted:
+		store.Delete(event.NodeID)
+		if err := config.DB.Delete(&models.NodeTraffic{}, event.NodeID).Error; err != nil {
+			logger.Error("Failed to delete traffic stats", "node_id", event.NodeID, "error", err)
+		}
+	}
+}
+
+// Flush saves a node's totals in persistent mode
+func Flush(nodeID uint) {
+	if !persistent() {
+		return
+	}
+	value, ok := store.Load(nodeID)
+	if !ok {
+		return
+	}
+	if err := save(nodeID, value.(*counters)); err != nil {
+		logger.Error("Failed to save traffic stats", "node_id", nodeID, "error", err)
+	}
+}
+
+// FlushAll saves the totals of every node in persistent mode, e.g. before shutdown
+func FlushAll() {
+	store.Range(func(key, value interface{}) bool {
+		Flush(key.(uint))
+		return true
+	})
+}
+
+// load fills the counters from the stored totals of a node, if any
+func load(nodeID uint, c *counters) error {
+	var row models.NodeTraffic
+	if err := config.DB.First(&row, "node_id = ?", nodeID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	c.since = row.Since
+	c.requests = row.Requests
+	c.bytesIn = row.BytesIn
+	c.bytesOut = row.BytesOut
+	c.latencySum = row.LatencySum
+
+	statusCodes := map[string]uint64{}
+	if err := json.Unmarshal([]byte(row.StatusCodes), &statusCodes); err != nil {
+		return err
+	}
+	for code, count := range statusCodes {
+		if status, err := strconv.Atoi(code); err == nil {
+			c.statusCodes[status] = count
+		}
+	}
+
+	var buckets []uint64
+	if err := json.Unmarshal([]byte(row.LatencyBuckets), &buckets); err != nil {
+		return err
+	}
+	// Bucket bounds may have changed since the totals were saved; keep only compatible counts
+	if len(buckets) == len(c.buckets) {
+		copy(c.buckets, buckets)
+	}
+	return nil
+}
+
+// save stores the current totals of a node
+func save(nodeID uint, c *counters) error {
+	c.mu.Lock()
+	statusCodes := make(map[string]uint64, len(c.statusCodes))
+	for code, count := range c.statusCodes {
+		statusCodes[strconv.Itoa(code)] = count
+	}
+	codesJSON, _ := json.Marshal(statusCodes)
+	bucketsJSON, _ := json.Marshal(c.buckets)
+	row := models.NodeTraffic{
+		NodeID:         nodeID,
+		Requests:       c.requests,
+		BytesIn:        c.bytesIn,
+		BytesOut:       c.bytesOut,
+		StatusCodes:    string(codesJSON),
+		LatencyBuckets: string(bucketsJSON),
+		LatencySum:     c.latencySum,
+		Since:          c.since,
+	}
+	c.mu.Unlock()
+
+	return config.DB.Clauses(clause.OnConflict{UpdateAll: true}).Create(&row).Error
+}
@@ -0,0 +1,14 @@
+package utils
+
+import "github.com/kataras/iris/v12"
+
+// APIVersion returns the major API version the request was routed through
+func APIVersion(ctx iris.Context) int {
+	return ctx.Values().GetIntDefault("api_version", 1)
+}
+
+// APIPath returns the path of a resource under the prefix the request was served from,
+// e.g. /api/v1/operations/3, so links stay within the API version the client uses
+func APIPath(ctx iris.Context, path string) string {
+	return ctx.Values().GetString("api_prefix") + path
+}
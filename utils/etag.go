@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/kataras/iris/v12"
+)
+
+// ETag builds the entity tag of a versioned resource
+func ETag(id uint, version uint) string {
+	return fmt.Sprintf("\"%d-%d\"", id, version)
+}
+
+// IfMatch reports whether the request's If-Match header allows modifying a resource with the given ETag.
+// A missing header always matches; otherwise a 412 response is written on mismatch.
+func IfMatch(ctx iris.Context, etag string) bool {
+	header := ctx.GetHeader("If-Match")
+	if header == "" || matchesETag(header, etag) {
+		return true
+	}
+
+	PreconditionFailedResponse(ctx)
+	return false
+}
+
+// IfNoneMatch reports whether the client already holds the current representation
+func IfNoneMatch(ctx iris.Context, etag string) bool {
+	header := ctx.GetHeader("If-None-Match")
+	return header != "" && matchesETag(header, etag)
+}
+
+// PreconditionFailedResponse sends the standard response for a stale or mismatched version
+func PreconditionFailedResponse(ctx iris.Context) {
+	ProblemResponse(ctx, http.StatusPreconditionFailed, CodeVersionConflict,
+		"the resource was modified by another request; fetch it again and retry")
+}
+
+// matchesETag compares a comma separated If-Match/If-None-Match list with an ETag
+func matchesETag(header string, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -2,6 +2,7 @@ package utils
 
 import (
 	"net"
+	"strconv"
 	"time"
 )
 
@@ -12,7 +13,7 @@ func IsValidIP(ip string) bool {
 
 // Check if a node is reachable
 func IsNodeReachable(ip string, port int) bool {
-    conn, err := net.DialTimeout("tcp", net.JoinHostPort(ip, string(port)), 3*time.Second)
+    conn, err := net.DialTimeout("tcp", net.JoinHostPort(ip, strconv.Itoa(port)), 3*time.Second)
     if err != nil {
         return false
     }
@@ -0,0 +1,117 @@
+package utils
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/kataras/iris/v12"
+)
+
+// ProblemContentType is the media type of RFC 7807 error responses
+const ProblemContentType = "application/problem+json"
+
+// problemTypeBase prefixes the code to build a problem's type URI
+const problemTypeBase = "/problems/"
+
+// Stable error codes clients can branch on; they never change once published
+const (
+	CodeInvalidRequest       = "invalid_request"
+	CodeValidationFailed     = "validation_failed"
+	CodeUnauthorized         = "unauthorized"
+	CodeInvalidCredentials   = "invalid_credentials"
+	CodeForbidden            = "forbidden"
+	CodeNotFound             = "not_found"
+	CodeConflict             = "conflict"
+	CodeEmailTaken           = "email_taken"
+	CodePortConflict         = "port_conflict"
+	CodePortInUse            = "port_in_use"
+	CodeNoFreePort           = "no_free_port"
+	CodeSubnetExhausted      = "subnet_exhausted"
+	CodeNodeRunning          = "node_running"
+	CodeNodeNotRunning       = "node_not_running"
+	CodeVersionConflict      = "version_conflict"
+	CodeUnsupportedMediaType = "unsupported_media_type"
+	CodeImportRejected       = "import_rejected"
+	CodeResyncRequired       = "resync_required"
+	CodeIdempotencyKeyReused = "idempotency_key_reused"
+	CodeIdempotencyKeyInUse  = "idempotency_key_in_use"
+	CodeOperationFinished    = "operation_finished"
+	CodeTeamHasNodes         = "team_has_nodes"
+	CodeLastTeamAdmin        = "last_team_admin"
+	CodeUserHasNodes         = "user_has_nodes"
+	CodeQuotaExceeded        = "quota_exceeded"
+	CodeInternal             = "internal_error"
+)
+
+// FieldError describes why one field of the request was rejected
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Problem is an RFC 7807 problem details document.
+// Extensions are added as top-level members next to the standard ones.
+type Problem struct {
+	Type          string                 `json:"type"`
+	Title         string                 `json:"title"`
+	Status        int                    `json:"status"`
+	Detail        string                 `json:"detail,omitempty"`
+	Instance      string                 `json:"instance,omitempty"`
+	Code          string                 `json:"code"`
+	CorrelationID string                 `json:"correlation_id,omitempty"`
+	Errors        []FieldError           `json:"errors,omitempty"`
+	Extensions    map[string]interface{} `json:"-"`
+}
+
+// MarshalJSON flattens the extension members into the document
+func (p Problem) MarshalJSON() ([]byte, error) {
+	type plain Problem
+	data, err := json.Marshal(plain(p))
+	if err != nil || len(p.Extensions) == 0 {
+		return data, err
+	}
+
+	members := make(map[string]interface{}, len(p.Extensions)+8)
+	for key, value := range p.Extensions {
+		members[key] = value
+	}
+	var standard map[string]interface{}
+	if err := json.Unmarshal(data, &standard); err != nil {
+		return nil, err
+	}
+	for key, value := range standard {
+		members[key] = value
+	}
+	return json.Marshal(members)
+}
+
+// NewProblem builds a problem with the type and title derived from the code and status
+func NewProblem(status int, code string, detail string) Problem {
+	return Problem{
+		Type:   problemTypeBase + code,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// WriteProblem sends a problem, tagging it with the request path and the request ID for correlation
+func WriteProblem(ctx iris.Context, problem Problem) {
+	problem.Instance = ctx.Path()
+	problem.CorrelationID = ctx.Values().GetString("request_id")
+
+	ctx.StatusCode(problem.Status)
+	ctx.ContentType(ProblemContentType)
+	data, err := json.Marshal(problem)
+	if err != nil {
+		ctx.WriteString(`{"type":"` + problemTypeBase + CodeInternal + `","status":500,"code":"` + CodeInternal + `"}`)
+		return
+	}
+	ctx.Write(data)
+}
+
+// ProblemResponse sends a problem built from a status, code and detail
+func ProblemResponse(ctx iris.Context, status int, code string, detail string) {
+	WriteProblem(ctx, NewProblem(status, code, detail))
+}
@@ -6,11 +6,25 @@ import (
 	"github.com/kataras/iris/v12"
 )
 
-// ValidationErrorResponse sends a standardized validation error response
-func ValidationErrorResponse(ctx iris.Context, err error) {
-	ctx.StatusCode(http.StatusBadRequest)
-	ctx.JSON(iris.Map{
-		"error":  "Validation failed",
-		"detail": err.Error(),
-	})
+// ValidationErrorResponse sends a standardized validation error response.
+// Field-level details are included when known.
+func ValidationErrorResponse(ctx iris.Context, err error, fields ...FieldError) {
+	problem := NewProblem(http.StatusBadRequest, CodeValidationFailed, err.Error())
+	problem.Errors = fields
+	WriteProblem(ctx, problem)
+}
+
+// InvalidRequestResponse sends the response for a body or parameter that could not be parsed
+func InvalidRequestResponse(ctx iris.Context, detail string) {
+	ProblemResponse(ctx, http.StatusBadRequest, CodeInvalidRequest, detail)
+}
+
+// NotFoundResponse sends the response for a missing or inaccessible resource
+func NotFoundResponse(ctx iris.Context, detail string) {
+	ProblemResponse(ctx, http.StatusNotFound, CodeNotFound, detail)
+}
+
+// InternalErrorResponse sends a generic server error; the cause is logged by the caller, never exposed
+func InternalErrorResponse(ctx iris.Context, detail string) {
+	ProblemResponse(ctx, http.StatusInternalServerError, CodeInternal, detail)
 }
@@ -0,0 +1,93 @@
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"node_management_application/config"
+	"node_management_application/events"
+	"node_management_application/logging"
+)
+
+var logger = logging.For("webhooks")
+
+// SignatureHeader carries the hex HMAC-SHA256 of the body, keyed with WEBHOOK_SECRET
+const SignatureHeader = "X-Webhook-Signature"
+
+// client posts events; its timeout bounds each delivery attempt
+var client = &http.Client{Timeout: 5 * time.Second}
+
+// retryDelays are the waits before each retry of a failed delivery
+var retryDelays = []time.Duration{time.Second, 5 * time.Second, 30 * time.Second}
+
+// Subscribe registers an event bus subscriber for each configured webhook URL. Every endpoint
+// has its own queue and goroutine, so a slow or failing one only holds back its own events.
+func Subscribe() {
+	types := make(map[events.Type]bool, len(config.WebhookEvents))
+	for _, eventType := range config.WebhookEvents {
+		types[events.Type(eventType)] = true
+	}
+
+	for _, url := range config.WebhookURLs {
+		logger.Info("Delivering events to webhook", "url", url)
+		events.Subscribe("webhook "+url, deliverer(url, types))
+	}
+}
+
+// deliverer posts each event of the selected types to the URL, retrying failed deliveries
+func deliverer(url string, types map[events.Type]bool) events.Handler {
+	return func(event events.Event) {
+		if len(types) > 0 && !types[event.Type] {
+			return
+		}
+
+		body, err := json.Marshal(event)
+		if err != nil {
+			logger.Error("Failed to encode event for webhook", "url", url, "event_id", event.ID, "error", err)
+			return
+		}
+
+		for attempt := 0; ; attempt++ {
+			err := post(url, body)
+			if err == nil {
+				return
+			}
+			if attempt == len(retryDelays) {
+				logger.Error("Giving up on webhook delivery", "url", url, "event_id", event.ID, "type", event.Type, "error", err)
+				return
+			}
+			logger.Warn("Webhook delivery failed; retrying", "url", url, "event_id", event.ID, "attempt", attempt+1, "error", err)
+			time.Sleep(retryDelays[attempt])
+		}
+	}
+}
+
+// post sends one event; any status other than 2xx counts as a failure
+func post(url string, body []byte) error {
+	request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if config.WebhookSecret != "" {
+		mac := hmac.New(sha256.New, []byte(config.WebhookSecret))
+		mac.Write(body)
+		request.Header.Set(SignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("endpoint answered %s", response.Status)
+	}
+	return nil
+}
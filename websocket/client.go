@@ -0,0 +1,288 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"node_management_application/config"
+	"node_management_application/events"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	writeWait      = 10 * time.Second    // Time allowed to write a message
+	pongWait       = 60 * time.Second    // Time allowed between pongs before the client is considered dead
+	pingPeriod     = (pongWait * 9) / 10 // Pings are sent before the pong deadline expires
+	maxMessageSize = 4096                // Largest message accepted from a client
+)
+
+// Client is an authenticated WebSocket connection and the events it asked for
+type Client struct {
+	conn   *websocket.Conn
+	userID uint
+
+	queue     chan interface{} // Messages waiting for the writer goroutine
+	done      chan struct{}    // Closed when the client goes away
+	closeOnce sync.Once
+
+	mu        sync.Mutex      // Guards the subscription and replay state below
+	explicit  bool            // Set by the first node or group subscription; until then every visible node
+	nodes     map[uint]bool   // Subscribed node IDs
+	groups    map[string]bool // Subscribed node groups
+	events    map[events.Type]bool
+	mutedIDs  map[uint]bool  // Unsubscribed nodes, left out even when subscribed to every node or their group
+	replaying bool           // Live events are held back while missed ones are replayed
+	pending   []events.Event // Live events received during a replay
+}
+
+// ClientMessage is a subscription request sent by a client
+type ClientMessage struct {
+	Action string        `json:"action"` // "subscribe", "unsubscribe" or "resume"
+	Since  uint64        `json:"since,omitempty"`
+	Nodes  []uint        `json:"nodes,omitempty"`
+	Groups []string      `json:"groups,omitempty"`
+	Events []events.Type `json:"events,omitempty"`
+}
+
+// newClient prepares a client with an empty subscription, which means every visible event
+func newClient(conn *websocket.Conn, userID uint) *Client {
+	return &Client{
+		conn:     conn,
+		userID:   userID,
+		queue:    make(chan interface{}, config.WebSocketSendQueue),
+		done:     make(chan struct{}),
+		nodes:    make(map[uint]bool),
+		groups:   make(map[string]bool),
+		events:   make(map[events.Type]bool),
+		mutedIDs: make(map[uint]bool),
+	}
+}
+
+// ReadMessages handles subscription messages and pongs until the connection fails
+func (c *Client) ReadMessages() {
+	c.conn.SetReadLimit(maxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				timeoutDisconnect.Add(1)
+				logger.Info("WebSocket client missed its pong deadline", "user_id", c.userID)
+			} else if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				logger.Warn("Error reading WebSocket message", "user_id", c.userID, "error", err)
+			}
+			return
+		}
+		c.HandleMessage(data)
+	}
+}
+
+// writePump is the only goroutine writing to the connection: it drains the queue and sends pings
+func (c *Client) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case <-c.done:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+			return
+		case message := <-c.queue:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteJSON(message); err != nil {
+				logger.Warn("Failed to send message to WebSocket client", "user_id", c.userID, "error", err)
+				c.close()
+				return
+			}
+			messagesSent.Add(1)
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				c.close()
+				return
+			}
+		}
+	}
+}
+
+// close stops the writer; the read loop then fails and the handler removes the client
+func (c *Client) close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		// Unblock a reader waiting on a connection the writer may never touch again
+		c.conn.SetReadDeadline(time.Now())
+	})
+}
+
+// HandleMessage applies a subscribe/unsubscribe/resume message and acknowledges it
+func (c *Client) HandleMessage(data []byte) {
+	var msg ClientMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		c.send(map[string]interface{}{"type": "error", "error": "invalid message"})
+		return
+	}
+	if msg.Action == "resume" {
+		c.Resume(msg.Since)
+		return
+	}
+
+	c.mu.Lock()
+	switch msg.Action {
+	case "subscribe":
+		if len(msg.Nodes) > 0 || len(msg.Groups) > 0 {
+			c.explicit = true
+		}
+		for _, id := range msg.Nodes {
+			c.nodes[id] = true
+			delete(c.mutedIDs, id)
+		}
+		for _, group := range msg.Groups {
+			c.groups[group] = true
+		}
+		for _, event := range msg.Events {
+			c.events[event] = true
+		}
+	case "unsubscribe":
+		for _, id := range msg.Nodes {
+			delete(c.nodes, id)
+			c.mutedIDs[id] = true
+		}
+		for _, group := range msg.Groups {
+			delete(c.groups, group)
+		}
+		for _, event := range msg.Events {
+			delete(c.events, event)
+		}
+	default:
+		c.mu.Unlock()
+		c.send(map[string]interface{}{"type": "error", "error": "unknown action"})
+		return
+	}
+	ack := map[string]interface{}{
+		"type":      msg.Action + "d",
+		"all_nodes": !c.explicit,
+		"nodes":     keys(c.nodes),
+		"groups":    keys(c.groups),
+		"events":    keys(c.events),
+	}
+	c.mu.Unlock()
+
+	c.send(ack)
+}
+
+// Resume replays the events published after since and then resumes live delivery.
+// If they were already pruned from the log the client is told to resync instead.
+// Live events are held back first and those the replay already sent are skipped after it.
+func (c *Client) Resume(since uint64) {
+	c.mu.Lock()
+	c.replaying = true
+	c.mu.Unlock()
+
+	last := since
+	missed, err := events.Since(since, c.userID)
+	switch {
+	case err == events.ErrResyncRequired:
+		last = events.LatestID()
+		c.send(map[string]interface{}{"type": "resync_required", "latest_id": last})
+	case err != nil:
+		logger.Error("Failed to replay events for WebSocket client", "user_id", c.userID, "error", err)
+		c.send(map[string]interface{}{"type": "error", "error": "failed to replay events"})
+	default:
+		for _, event := range missed {
+			if c.wants(event) {
+				c.send(event)
+			}
+			last = event.ID
+		}
+		c.send(map[string]interface{}{"type": "replay_complete", "last_id": last})
+	}
+
+	// Flush live events that arrived during the replay, skipping ones already replayed
+	for {
+		c.mu.Lock()
+		pending := c.pending
+		c.pending = nil
+		if len(pending) == 0 {
+			c.replaying = false
+			c.mu.Unlock()
+			return
+		}
+		c.mu.Unlock()
+
+		for _, event := range pending {
+			if event.ID > last {
+				c.send(event)
+			}
+		}
+	}
+}
+
+// deliver queues an event the client subscribed to, or holds it back during a replay.
+// A full queue drops the event or disconnects the client, depending on the configured policy.
+func (c *Client) deliver(event events.Event) {
+	if !c.wants(event) {
+		return
+	}
+
+	c.mu.Lock()
+	if c.replaying {
+		c.pending = append(c.pending, event)
+		c.mu.Unlock()
+		return
+	}
+	c.mu.Unlock()
+
+	select {
+	case c.queue <- event:
+	case <-c.done:
+	default:
+		if config.WebSocketSlowClientPolicy == "disconnect" {
+			slowDisconnects.Add(1)
+			logger.Warn("Disconnecting slow WebSocket client", "user_id", c.userID)
+			c.close()
+			return
+		}
+		messagesDropped.Add(1)
+	}
+}
+
+// send queues a reply or replayed event, waiting for room since only this client is affected
+func (c *Client) send(message interface{}) {
+	select {
+	case c.queue <- message:
+	case <-c.done:
+	}
+}
+
+// wants reports whether the client should receive an event
+func (c *Client) wants(event events.Event) bool {
+	if !events.Visible(event, c.userID) {
+		return false
+	}
+	group := events.NodeGroup(event.NodeID)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if event.NodeID != 0 {
+		if c.mutedIDs[event.NodeID] {
+			return false
+		}
+		if c.explicit && !c.nodes[event.NodeID] && (group == "" || !c.groups[group]) {
+			return false
+		}
+	}
+	return len(c.events) == 0 || c.events[event.Type]
+}
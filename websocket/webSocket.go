@@ -1,52 +1,110 @@
 package websocket
 
 import (
-	"log"
 	"sync"
+	"sync/atomic"
+
+	"node_management_application/events"
+	"node_management_application/logging"
 
 	"github.com/gorilla/websocket"
 )
 
+var logger = logging.For("websocket")
+
+var (
+	clients    = make(map[*Client]bool) // Connected WebSocket clients
+	clientsMux = sync.RWMutex{}         // Mutex for thread-safe operations
+)
+
+// Connection counters reported by GetStats
 var (
-	clients    = make(map[*websocket.Conn]bool) // Connected WebSocket clients
-	clientsMux = sync.Mutex{}                   // Mutex for thread-safe operations
+	totalConnections  atomic.Uint64
+	messagesSent      atomic.Uint64
+	messagesDropped   atomic.Uint64
+	slowDisconnects   atomic.Uint64
+	timeoutDisconnect atomic.Uint64
 )
 
-// AddClient adds a new WebSocket client
-func AddClient(conn *websocket.Conn) {
+// CountDropped counts an event dropped for a slow Server-Sent Events client, so slow streaming
+// clients of either kind show up in the same drop count
+func CountDropped() {
+	messagesDropped.Add(1)
+}
+
+// Stats is a snapshot of the WebSocket hub's connection metrics
+type Stats struct {
+	ConnectedClients   int    `json:"connected_clients"`
+	TotalConnections   uint64 `json:"total_connections"`
+	MessagesSent       uint64 `json:"messages_sent"`
+	MessagesDropped    uint64 `json:"messages_dropped"`
+	SlowDisconnects    uint64 `json:"slow_client_disconnects"`
+	TimeoutDisconnects uint64 `json:"timeout_disconnects"`
+}
+
+// AddClient registers a WebSocket connection for an authenticated user and starts its writer.
+// A client that will Resume holds live events back from the moment it is registered, so events
+// published before the replay reads the log are not delivered both live and replayed.
+func AddClient(conn *websocket.Conn, userID uint, resuming bool) *Client {
+	client := newClient(conn, userID)
+	client.replaying = resuming
+
 	clientsMux.Lock()
-	clients[conn] = true
+	clients[client] = true
 	clientsMux.Unlock()
-	log.Println("New WebSocket client added")
+	totalConnections.Add(1)
+
+	go client.writePump()
+	logger.Info("WebSocket client added", "user_id", userID)
+	return client
 }
 
-// RemoveClient removes a WebSocket client
-func RemoveClient(conn *websocket.Conn) {
+// RemoveClient removes a WebSocket client and closes its connection
+func RemoveClient(client *Client) {
 	clientsMux.Lock()
-	delete(clients, conn)
+	delete(clients, client)
 	clientsMux.Unlock()
-	conn.Close()
-	log.Println("WebSocket client removed")
+	client.close()
+	logger.Info("WebSocket client removed", "user_id", client.userID)
 }
 
-// BroadcastHealthStatus sends a health update to all connected WebSocket clients
-func BroadcastHealthStatus(nodeID uint, healthStatus string) {
-    log.Printf("Broadcasting health status: node_id=%d, health_status=%s", nodeID, healthStatus)
+// BroadcastEvent queues an event for the WebSocket clients of its user that subscribed to it.
+// It never writes to a connection itself, so a slow client cannot stall the publisher.
+// It is registered as an event bus subscriber.
+func BroadcastEvent(event events.Event) {
+	clientsMux.RLock()
+	snapshot := make([]*Client, 0, len(clients))
+	for client := range clients {
+		snapshot = append(snapshot, client)
+	}
+	clientsMux.RUnlock()
 
-    message := map[string]interface{}{
-		"node_id":       nodeID,
-		"health_status": healthStatus,
+	for _, client := range snapshot {
+		client.deliver(event)
 	}
+}
 
-	clientsMux.Lock()
-	defer clientsMux.Unlock()
+// GetStats returns the current connection metrics
+func GetStats() Stats {
+	clientsMux.RLock()
+	connected := len(clients)
+	clientsMux.RUnlock()
 
-	for client := range clients {
-		err := client.WriteJSON(message)
-		if err != nil {
-			log.Printf("Failed to send message to WebSocket client: %v", err)
-			client.Close()
-			delete(clients, client)
-		}
+	return Stats{
+		ConnectedClients:   connected,
+		TotalConnections:   totalConnections.Load(),
+		MessagesSent:       messagesSent.Load(),
+		MessagesDropped:    messagesDropped.Load(),
+		SlowDisconnects:    slowDisconnects.Load(),
+		TimeoutDisconnects: timeoutDisconnect.Load(),
+	}
+}
+
+// keys lists the members of a set
+func keys[K comparable](set map[K]bool) []K {
+	list := make([]K, 0, len(set))
+	for key := range set {
+		list = append(list, key)
 	}
+	return list
 }